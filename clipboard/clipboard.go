@@ -0,0 +1,12 @@
+// Package clipboard copies images and text to, and pastes them from, the system clipboard
+// in Linux (X11 and Wayland), Windows and MacOS. It also keeps an in-app history of the
+// images copied, see History.
+package clipboard
+
+import (
+	"errors"
+)
+
+// ErrNotAvailable is returned when pasting, if the clipboard content is not available in any
+// of the formats accepted -- e.g. pasting an image when text was copied.
+var ErrNotAvailable = errors.New("clipboard content not available in the requested format")
@@ -5,6 +5,7 @@ package clipboard
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	"image/png"
 
@@ -22,6 +23,7 @@ func CopyImage(img image.Image) error {
 	}
 
 	clipboard.Write(clipboard.FmtImage, buff.Bytes())
+	addToHistory(img, nil)
 	return nil
 }
 
@@ -29,3 +31,35 @@ func CopyText(text string) error {
 	clipboard.Write(clipboard.FmtText, []byte(text))
 	return nil
 }
+
+// PasteImage returns the image in the clipboard. It returns ErrNotAvailable if the
+// clipboard doesn't hold an image.
+func PasteImage() (image.Image, error) {
+	data := clipboard.Read(clipboard.FmtImage)
+	if len(data) == 0 {
+		return nil, ErrNotAvailable
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode clipboard image: %w", err)
+	}
+	return img, nil
+}
+
+// PasteText returns the text in the clipboard. It returns ErrNotAvailable if the
+// clipboard doesn't hold text.
+func PasteText() (string, error) {
+	data := clipboard.Read(clipboard.FmtText)
+	if data == nil {
+		return "", ErrNotAvailable
+	}
+	return string(data), nil
+}
+
+// Persist is a no-op: the pasteboard keeps the content after GoShot exits.
+func Persist() error {
+	return nil
+}
+
+// RunHelperIfRequested is a no-op: there is no clipboard helper process in this platform.
+func RunHelperIfRequested() {}
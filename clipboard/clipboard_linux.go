@@ -0,0 +1,249 @@
+//go:build linux
+// +build linux
+
+package clipboard
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"image"
+	_ "image/gif" // Decoding of pasted GIF images.
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Clipboard handling in Linux: it selects at runtime between Wayland (clipboard_wayland.go)
+// and X11 (clipboard_x11.go).
+
+// BackendEnv is the environment variable that can be set to "wayland" or "x11" to force
+// the clipboard backend. By default, Wayland is used if WAYLAND_DISPLAY is set and the
+// compositor supports the data control protocol, otherwise X11 is used.
+const BackendEnv = "GOSHOT_CLIPBOARD"
+
+var (
+	backendOnce sync.Once
+	useWayland  bool
+)
+
+// selectBackend decides whether to use Wayland or X11. Should be called only once.
+func selectBackend() {
+	switch os.Getenv(BackendEnv) {
+	case "x11":
+		glog.V(1).Infof("Clipboard: X11 forced by $%s", BackendEnv)
+		return
+	case "wayland":
+		glog.V(1).Infof("Clipboard: Wayland forced by $%s", BackendEnv)
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") == "" {
+			return
+		}
+	}
+	waylandOnce.Do(initWayland)
+	if waylandFailure != nil {
+		glog.Warningf("Clipboard: falling back to X11: %v", waylandFailure)
+		return
+	}
+	useWayland = true
+}
+
+func CopyImage(img image.Image) error {
+	backendOnce.Do(selectBackend)
+	// Content is served (and encoded) later, so we keep a copy that won't change. The same copy
+	// is kept in the history.
+	clone := img
+	if !isInHistory(img) {
+		clone = cloneImage(img)
+	}
+	removeTempImage() // Created for the content being replaced.
+	var err error
+	if useWayland {
+		err = waylandCopyImage(clone)
+	} else {
+		err = x11CopyImage(clone)
+	}
+	if err == nil {
+		addToHistory(img, clone)
+	}
+	return err
+}
+
+func CopyText(text string) error {
+	backendOnce.Do(selectBackend)
+	removeTempImage() // Created for the content being replaced.
+	if useWayland {
+		return waylandCopyText(text)
+	}
+	return x11CopyText(text)
+}
+
+// PasteTimeout is how long to wait for the clipboard owner to send its content, when pasting.
+var PasteTimeout = 5 * time.Second
+
+// pasteImageTargets are the image formats accepted when pasting, the preferred one first.
+var pasteImageTargets = []string{ImageTarget, JPEGTarget, BMPTarget, "image/gif"}
+
+// paste returns the clipboard content in the first of the `preferred` targets offered.
+func paste(preferred []string) (data []byte, target string, err error) {
+	backendOnce.Do(selectBackend)
+	if useWayland {
+		return waylandPaste(preferred)
+	}
+	return x11Paste(preferred)
+}
+
+// PasteImage returns the image in the clipboard. It returns ErrNotAvailable if the
+// clipboard doesn't hold an image.
+func PasteImage() (image.Image, error) {
+	data, target, err := paste(pasteImageTargets)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode clipboard image (%q): %w", target, err)
+	}
+	return img, nil
+}
+
+// PasteText returns the text in the clipboard. It returns ErrNotAvailable if the
+// clipboard doesn't hold text.
+func PasteText() (string, error) {
+	data, _, err := paste(textTargets)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Persist makes the current clipboard content, if GoShot still owns it, available after
+// GoShot exits. It should be called just before exiting.
+//
+// In X11 the content is handed over to the clipboard manager, if there is one. Otherwise,
+// and in Wayland, a detached helper process (GoShot itself, see RunHelperIfRequested) is
+// started that keeps serving the content until another client takes over the clipboard.
+func Persist() error {
+	backendOnce.Do(selectBackend)
+	if useWayland {
+		return waylandPersist()
+	}
+	return x11Persist()
+}
+
+// HelperEnv is the environment variable set for the clipboard helper process, with the
+// format (target) of the content it reads from its standard input.
+const HelperEnv = "GOSHOT_CLIPBOARD_HELPER"
+
+// helperReady is printed by the helper process, once it owns the clipboard.
+const helperReady = "ready"
+
+// HelperStartTimeout is how long to wait for the helper process to take over the clipboard.
+var HelperStartTimeout = 5 * time.Second
+
+// ownershipLost is notified when another client takes over the clipboard.
+var ownershipLost = make(chan struct{}, 1)
+
+func notifyOwnershipLost() {
+	removeTempImage()
+	select {
+	case ownershipLost <- struct{}{}:
+	default:
+	}
+}
+
+// startHelper starts a detached helper process to serve the content, and waits for it
+// to take over the clipboard.
+func startHelper(offered content) error {
+	target := offered.targets()[0]
+	data, err := offered.data(target)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find GoShot executable to start clipboard helper: %w", err)
+	}
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), HelperEnv+"="+target)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true} // Detach from our session.
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start clipboard helper: %w", err)
+	}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start clipboard helper: %w", err)
+	}
+	glog.V(1).Infof("Started clipboard helper process (pid=%d)", cmd.Process.Pid)
+
+	ready := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		if err == nil && strings.TrimSpace(line) != helperReady {
+			err = fmt.Errorf("unexpected output %q", line)
+		}
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+		if err != nil {
+			err = fmt.Errorf("clipboard helper failed to start: %w", err)
+		}
+	case <-time.After(HelperStartTimeout):
+		err = errors.New("clipboard helper didn't start in time")
+	}
+	_ = cmd.Process.Release()
+	return err
+}
+
+// RunHelperIfRequested runs the clipboard helper (see Persist) and exits, if this process was
+// started as one. Otherwise, it returns immediately. It should be called at the start of the
+// program, before anything else.
+func RunHelperIfRequested() {
+	target := os.Getenv(HelperEnv)
+	if target == "" {
+		return
+	}
+	_ = os.Unsetenv(HelperEnv)
+	if err := runHelper(target); err != nil {
+		glog.Errorf("Clipboard helper: %v", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runHelper reads the content from the standard input, takes over the clipboard, and then
+// serves it until another client takes over.
+func runHelper(target string) error {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read clipboard content: %w", err)
+	}
+	if target == ImageTarget {
+		var img image.Image
+		img, err = png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to decode clipboard image: %w", err)
+		}
+		err = CopyImage(img)
+	} else {
+		err = CopyText(string(data))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(helperReady)
+	_ = os.Stdout.Close()
+	glog.V(1).Infof("Clipboard helper serving %q content (%d bytes)", target, len(data))
+	<-ownershipLost
+	glog.V(1).Infof("Clipboard helper: clipboard taken over by another client, exiting.")
+	return nil
+}
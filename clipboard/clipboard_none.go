@@ -17,3 +17,19 @@ func CopyImage(img image.Image) error {
 func CopyText(text string) error {
 	return errors.New("Clipboard text copy not implemented in this platform, sorry.")
 }
+
+func PasteImage() (image.Image, error) {
+	return nil, errors.New("Clipboard image paste not implemented in this platform, sorry.")
+}
+
+func PasteText() (string, error) {
+	return "", errors.New("Clipboard text paste not implemented in this platform, sorry.")
+}
+
+// Persist is a no-op: not implemented in this platform.
+func Persist() error {
+	return nil
+}
+
+// RunHelperIfRequested is a no-op: there is no clipboard helper process in this platform.
+func RunHelperIfRequested() {}
@@ -0,0 +1,597 @@
+//go:build linux
+// +build linux
+
+package clipboard
+
+// Clipboard handling in Linux/Wayland.
+//
+// It implements a minimal client of the Wayland wire protocol, in pure Go, enough to use the
+// data control protocols: "ext-data-control-v1" (preferred) or "wlr-data-control-unstable-v1".
+// These allow a client without any surface (window) to set the clipboard selection.
+//
+// See https://wayland.freedesktop.org/docs/html/ch04.html for the wire format.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"image"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Wayland interfaces used and the maximum version we know how to handle.
+const (
+	wlSeatInterface           = "wl_seat"
+	extDataControlInterface   = "ext_data_control_manager_v1"
+	zwlrDataControlInterface  = "zwlr_data_control_manager_v1"
+	wlSeatMaxVersion          = 1
+	extDataControlMaxVersion  = 1
+	zwlrDataControlMaxVersion = 2
+)
+
+// Requests and events opcodes. The "ext" and "zwlr" data control protocols share the same opcodes.
+const (
+	wlDisplayID                = 1
+	wlDisplaySync              = 0
+	wlDisplayGetRegistry       = 1
+	wlDisplayErrorEvent        = 0
+	wlDisplayDeleteIDEvent     = 1
+	wlRegistryBind             = 0
+	wlRegistryGlobalEvent      = 0
+	wlCallbackDoneEvent        = 0
+	dataControlCreateSource    = 0
+	dataControlGetDevice       = 1
+	dataDeviceSetSelection     = 0
+	dataDeviceDataOfferEvent   = 0
+	dataDeviceSelectionEvent   = 1
+	dataDeviceFinishedEvent    = 2
+	dataDevicePrimaryEvent     = 3
+	dataSourceOffer            = 0
+	dataSourceDestroy          = 1
+	dataSourceSendEvent        = 0
+	dataSourceCancelledEvent   = 1
+	dataOfferReceive           = 0
+	dataOfferDestroy           = 1
+	dataOfferOfferEvent        = 0
+	wlHeaderSize               = 8
+	wlMaxMessageSize           = 4096
+	wlMaxFileDescriptorsPerMsg = 28
+)
+
+// wlEndian is the byte order of the wire protocol: it's the host byte order, little-endian
+// in all platforms supported.
+var wlEndian = binary.LittleEndian
+
+var (
+	waylandOnce    sync.Once
+	waylandFailure error
+	wl             *waylandClient
+)
+
+// waylandClient holds the connection to the compositor and the state of the clipboard.
+type waylandClient struct {
+	conn *net.UnixConn
+
+	// writeMu serializes requests, mu protects the remaining fields.
+	writeMu, mu sync.Mutex
+	nextID      uint32
+
+	// Buffered incoming data and file descriptors.
+	in  []byte
+	fds []int
+
+	// Globals and objects.
+	globals           map[string]wlGlobal
+	seat, manager     uint32
+	managerInterface  string
+	device            uint32
+	pendingCallbackID uint32
+
+	// sources maps each data source we created to its content. Only the last one
+	// owns the selection. Cancelled sources are kept, with nil content, until the compositor
+	// confirms their deletion: it may still send events to them until then.
+	sources       map[uint32]content
+	currentSource uint32
+
+	// offers made by other clients: maps offer id to the list of mime types offered.
+	offers         map[uint32][]string
+	selectionOffer uint32
+}
+
+type wlGlobal struct {
+	name, version uint32
+}
+
+// initWayland connects to the Wayland compositor and checks that it supports the data control
+// protocol. It should be called only once.
+func initWayland() {
+	glog.V(2).Infof("initWayland()")
+	wl, waylandFailure = newWaylandClient()
+	if waylandFailure != nil {
+		glog.V(1).Infof("Wayland clipboard not available: %v", waylandFailure)
+		return
+	}
+	go wl.eventLoop()
+}
+
+// waylandSocketPath returns the path of the compositor's socket.
+func waylandSocketPath() (string, error) {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" {
+		display = "wayland-0"
+	}
+	if filepath.IsAbs(display) {
+		return display, nil
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR not set, can't find Wayland socket")
+	}
+	return filepath.Join(runtimeDir, display), nil
+}
+
+func newWaylandClient() (*waylandClient, error) {
+	socketPath, err := waylandSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Wayland compositor at %q: %w", socketPath, err)
+	}
+	c := &waylandClient{
+		conn:    conn,
+		nextID:  wlDisplayID + 1,
+		globals: make(map[string]wlGlobal),
+		sources: make(map[uint32]content),
+		offers:  make(map[uint32][]string),
+	}
+
+	// Enumerate globals: get registry and wait for the sync callback, at which point all
+	// globals will have been announced.
+	registry := c.newID()
+	if err = c.request(wlDisplayID, wlDisplayGetRegistry, nil, registry); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if err = c.roundTrip(registry); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+
+	seat, found := c.globals[wlSeatInterface]
+	if !found {
+		c.conn.Close()
+		return nil, errors.New("Wayland compositor has no seat")
+	}
+	manager, found := c.globals[extDataControlInterface]
+	c.managerInterface = extDataControlInterface
+	maxVersion := uint32(extDataControlMaxVersion)
+	if !found {
+		manager, found = c.globals[zwlrDataControlInterface]
+		c.managerInterface = zwlrDataControlInterface
+		maxVersion = zwlrDataControlMaxVersion
+	}
+	if !found {
+		c.conn.Close()
+		return nil, fmt.Errorf("Wayland compositor supports neither %q nor %q protocols",
+			extDataControlInterface, zwlrDataControlInterface)
+	}
+	glog.V(2).Infof("- using %s", c.managerInterface)
+
+	c.seat = c.bind(registry, seat, wlSeatInterface, wlSeatMaxVersion)
+	c.manager = c.bind(registry, manager, c.managerInterface, maxVersion)
+	c.device = c.newID()
+	if err = c.request(c.manager, dataControlGetDevice, nil, c.device, c.seat); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// bind binds the global to a new object id, with the highest version supported by both sides.
+func (c *waylandClient) bind(registry uint32, global wlGlobal, iface string, maxVersion uint32) uint32 {
+	version := global.version
+	if version > maxVersion {
+		version = maxVersion
+	}
+	id := c.newID()
+	if err := c.request(registry, wlRegistryBind, nil, global.name, iface, version, id); err != nil {
+		glog.Errorf("Failed to bind to %s: %v", iface, err)
+	}
+	return id
+}
+
+// roundTrip sends a wl_display.sync and processes events until it's done. It's only used during
+// initialization, before the event loop is started. `registry` is the id of the registry, whose
+// events are processed.
+func (c *waylandClient) roundTrip(registry uint32) error {
+	c.pendingCallbackID = c.newID()
+	if err := c.request(wlDisplayID, wlDisplaySync, nil, c.pendingCallbackID); err != nil {
+		return err
+	}
+	for c.pendingCallbackID != 0 {
+		sender, opcode, body, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		switch {
+		case sender == wlDisplayID:
+			if err = c.handleDisplayEvent(opcode, body); err != nil {
+				return err
+			}
+		case sender == registry && opcode == wlRegistryGlobalEvent:
+			args := wlArgs{data: body}
+			name, iface, version := args.uint(), args.string(), args.uint()
+			c.globals[iface] = wlGlobal{name: name, version: version}
+		case sender == c.pendingCallbackID && opcode == wlCallbackDoneEvent:
+			c.pendingCallbackID = 0
+		}
+	}
+	return nil
+}
+
+func (c *waylandClient) newID() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+// request sends a request to the compositor. Arguments can be uint32 (for uint, object and new_id
+// types), int32 or string. `fds` are file descriptors to pass along.
+func (c *waylandClient) request(object uint32, opcode uint16, fds []int, args ...interface{}) error {
+	msg, err := encodeMessage(object, opcode, args...)
+	if err != nil {
+		return err
+	}
+	var oob []byte
+	if len(fds) > 0 {
+		oob = syscall.UnixRights(fds...)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, _, err = c.conn.WriteMsgUnix(msg, oob, nil)
+	if err != nil {
+		return fmt.Errorf("failed to send Wayland request: %w", err)
+	}
+	return nil
+}
+
+// encodeMessage encodes a message in the wire format, see request for the arguments accepted.
+func encodeMessage(object uint32, opcode uint16, args ...interface{}) ([]byte, error) {
+	msg := make([]byte, wlHeaderSize, 64)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint32:
+			msg = appendUint32(msg, v)
+		case int32:
+			msg = appendUint32(msg, uint32(v))
+		case string:
+			msg = appendUint32(msg, uint32(len(v)+1))
+			msg = append(msg, v...)
+			msg = append(msg, 0)
+			for len(msg)%4 != 0 {
+				msg = append(msg, 0)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported Wayland argument type %T", arg)
+		}
+	}
+	wlEndian.PutUint32(msg[0:], object)
+	wlEndian.PutUint32(msg[4:], uint32(len(msg))<<16|uint32(opcode))
+	return msg, nil
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var encoded [4]byte
+	wlEndian.PutUint32(encoded[:], v)
+	return append(buf, encoded[:]...)
+}
+
+// readMessage reads the next event from the compositor, along with any file descriptors sent.
+func (c *waylandClient) readMessage() (sender uint32, opcode uint16, body []byte, err error) {
+	for {
+		if len(c.in) >= wlHeaderSize {
+			sizeAndOpcode := wlEndian.Uint32(c.in[4:])
+			size := int(sizeAndOpcode >> 16)
+			if size < wlHeaderSize {
+				return 0, 0, nil, fmt.Errorf("invalid Wayland message size %d", size)
+			}
+			if len(c.in) >= size {
+				sender = wlEndian.Uint32(c.in)
+				opcode = uint16(sizeAndOpcode & 0xFFFF)
+				body = make([]byte, size-wlHeaderSize)
+				copy(body, c.in[wlHeaderSize:size])
+				c.in = c.in[size:]
+				return
+			}
+		}
+		if err = c.fill(); err != nil {
+			return
+		}
+	}
+}
+
+// fill reads more data from the connection.
+func (c *waylandClient) fill() error {
+	buf := make([]byte, wlMaxMessageSize)
+	oob := make([]byte, syscall.CmsgSpace(wlMaxFileDescriptorsPerMsg*4))
+	n, oobn, _, _, err := c.conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return fmt.Errorf("failed to read from Wayland compositor: %w", err)
+	}
+	c.in = append(c.in, buf[:n]...)
+	if oobn > 0 {
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			return fmt.Errorf("failed to parse Wayland control message: %w", err)
+		}
+		for _, msg := range msgs {
+			fds, err := syscall.ParseUnixRights(&msg)
+			if err == nil {
+				c.fds = append(c.fds, fds...)
+			}
+		}
+	}
+	return nil
+}
+
+// popFD returns the next file descriptor received.
+func (c *waylandClient) popFD() (int, error) {
+	if len(c.fds) == 0 {
+		return -1, errors.New("expected file descriptor from Wayland compositor, got none")
+	}
+	fd := c.fds[0]
+	c.fds = c.fds[1:]
+	return fd, nil
+}
+
+// wlArgs decodes the arguments of an event.
+type wlArgs struct {
+	data []byte
+}
+
+func (a *wlArgs) uint() uint32 {
+	if len(a.data) < 4 {
+		return 0
+	}
+	v := wlEndian.Uint32(a.data)
+	a.data = a.data[4:]
+	return v
+}
+
+func (a *wlArgs) string() string {
+	size := int(a.uint())
+	padded := (size + 3) &^ 3
+	if size == 0 || padded > len(a.data) {
+		return ""
+	}
+	s := string(a.data[:size-1]) // Drop the terminating NUL.
+	a.data = a.data[padded:]
+	return s
+}
+
+func (c *waylandClient) handleDisplayEvent(opcode uint16, body []byte) error {
+	args := wlArgs{data: body}
+	switch opcode {
+	case wlDisplayErrorEvent:
+		object, code, message := args.uint(), args.uint(), args.string()
+		return fmt.Errorf("Wayland protocol error on object %d, code %d: %s", object, code, message)
+	case wlDisplayDeleteIDEvent:
+		// We don't reuse ids, only cancelled sources are waiting for it.
+		id := args.uint()
+		c.mu.Lock()
+		if offered, found := c.sources[id]; found && offered == nil {
+			delete(c.sources, id)
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// eventLoop processes the events from the compositor, until the connection is closed or fails.
+func (c *waylandClient) eventLoop() {
+	for {
+		sender, opcode, body, err := c.readMessage()
+		if err != nil {
+			glog.Errorf("Wayland clipboard: %v", err)
+			return
+		}
+		if sender == wlDisplayID {
+			if err = c.handleDisplayEvent(opcode, body); err != nil {
+				glog.Errorf("Wayland clipboard: %v", err)
+				return
+			}
+			continue
+		}
+		c.handleEvent(sender, opcode, body)
+	}
+}
+
+func (c *waylandClient) handleEvent(sender uint32, opcode uint16, body []byte) {
+	args := wlArgs{data: body}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if offered, found := c.sources[sender]; found {
+		switch opcode {
+		case dataSourceSendEvent:
+			// The file descriptor is always taken, so the next events get theirs.
+			mimeType := args.string()
+			fd, err := c.popFD()
+			if err != nil {
+				glog.Errorf("Wayland clipboard: %v", err)
+				return
+			}
+			if offered == nil {
+				glog.V(2).Infof("Wayland clipboard: send %q to cancelled source %d ignored", mimeType, sender)
+				_ = syscall.Close(fd)
+				return
+			}
+			glog.V(2).Infof("Wayland clipboard: send %q", mimeType)
+			go sendToFD(fd, offered, mimeType)
+		case dataSourceCancelledEvent:
+			if offered == nil {
+				return // Already cancelled.
+			}
+			glog.V(2).Infof("Wayland clipboard: source %d cancelled", sender)
+			c.sources[sender] = nil // Deleted when the compositor confirms it, see handleDisplayEvent.
+			if c.currentSource == sender {
+				c.currentSource = 0
+				notifyOwnershipLost()
+			}
+			go func() { _ = c.request(sender, dataSourceDestroy, nil) }()
+		}
+		return
+	}
+
+	if _, found := c.offers[sender]; found {
+		if opcode == dataOfferOfferEvent {
+			c.offers[sender] = append(c.offers[sender], args.string())
+		}
+		return
+	}
+
+	if sender == c.device {
+		switch opcode {
+		case dataDeviceDataOfferEvent:
+			c.offers[args.uint()] = nil
+		case dataDeviceSelectionEvent:
+			c.selectionOffer = args.uint()
+			c.destroyUnusedOffers()
+		case dataDevicePrimaryEvent:
+			c.destroyUnusedOffers()
+		case dataDeviceFinishedEvent:
+			glog.Warningf("Wayland clipboard: data device no longer valid")
+			c.device = 0
+		}
+	}
+}
+
+// destroyUnusedOffers releases all offers except the current selection's. It must be called with
+// c.mu locked.
+func (c *waylandClient) destroyUnusedOffers() {
+	for id := range c.offers {
+		if id == c.selectionOffer {
+			continue
+		}
+		delete(c.offers, id)
+		offer := id
+		go func() { _ = c.request(offer, dataOfferDestroy, nil) }()
+	}
+}
+
+// sendToFD writes the content in the requested mime type to the file descriptor, and closes it.
+func sendToFD(fd int, offered content, mimeType string) {
+	f := os.NewFile(uintptr(fd), "wayland-clipboard")
+	defer func() { _ = f.Close() }()
+	data, err := offered.data(mimeType)
+	if err != nil {
+		glog.Errorf("Wayland clipboard: failed to encode content as %q: %v", mimeType, err)
+		return
+	}
+	if _, err = f.Write(data); err != nil {
+		glog.Errorf("Wayland clipboard: failed to send content as %q: %v", mimeType, err)
+	}
+}
+
+// setSelection creates a new data source offering the content, and sets it as the clipboard selection.
+func (c *waylandClient) setSelection(offered content) error {
+	c.mu.Lock()
+	device := c.device
+	c.mu.Unlock()
+	if device == 0 {
+		return errors.New("Wayland data device no longer available")
+	}
+
+	source := c.newID()
+	c.mu.Lock()
+	c.sources[source] = offered
+	c.currentSource = source
+	c.mu.Unlock()
+	if err := c.request(c.manager, dataControlCreateSource, nil, source); err != nil {
+		return err
+	}
+	for _, mimeType := range offered.targets() {
+		if err := c.request(source, dataSourceOffer, nil, mimeType); err != nil {
+			return err
+		}
+	}
+	return c.request(device, dataDeviceSetSelection, nil, source)
+}
+
+// waylandPersist starts a helper process to keep serving the clipboard content after GoShot exits,
+// if we still own the selection: with the data control protocols the selection is cleared when the
+// client that set it disconnects.
+func waylandPersist() error {
+	wl.mu.Lock()
+	offered := wl.sources[wl.currentSource]
+	wl.mu.Unlock()
+	if offered == nil {
+		return nil
+	}
+	return startHelper(offered)
+}
+
+// waylandPaste returns the clipboard content in the first of the `preferred` formats offered.
+func waylandPaste(preferred []string) (data []byte, mimeType string, err error) {
+	return wl.paste(preferred)
+}
+
+// paste implements waylandPaste.
+func (c *waylandClient) paste(preferred []string) (data []byte, mimeType string, err error) {
+	c.mu.Lock()
+	if offered := c.sources[c.currentSource]; offered != nil {
+		// We own the selection: no need to go through the compositor.
+		c.mu.Unlock()
+		mimeType = chooseTarget(preferred, offered.targets())
+		if mimeType == "" {
+			return nil, "", ErrNotAvailable
+		}
+		data, err = offered.data(mimeType)
+		return data, mimeType, err
+	}
+	offer := c.selectionOffer
+	mimeType = chooseTarget(preferred, c.offers[offer])
+	c.mu.Unlock()
+	if offer == 0 || mimeType == "" {
+		return nil, "", ErrNotAvailable
+	}
+
+	// Content is sent by the selection owner through a pipe.
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create pipe to receive clipboard content: %w", err)
+	}
+	defer func() { _ = r.Close() }()
+	err = c.request(offer, dataOfferReceive, []int{int(w.Fd())}, mimeType)
+	_ = w.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	_ = r.SetReadDeadline(time.Now().Add(PasteTimeout))
+	data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to receive clipboard content as %q: %w", mimeType, err)
+	}
+	return data, mimeType, nil
+}
+
+func waylandCopyImage(img image.Image) error {
+	glog.V(2).Infof("waylandCopyImage(bounds=%+v)", img.Bounds())
+	return wl.setSelection(newImageContent(img))
+}
+
+func waylandCopyText(text string) error {
+	glog.V(2).Infof("waylandCopyText(%d bytes)", len(text))
+	return wl.setSelection(newTextContent(text))
+}
@@ -0,0 +1,297 @@
+//go:build linux
+// +build linux
+
+package clipboard
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestWaylandClient returns a client connected to one end of a socket pair, and the other end,
+// that plays the compositor.
+func newTestWaylandClient(t *testing.T) (*waylandClient, *net.UnixConn) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("Failed to create socket pair: %v", err)
+	}
+	conns := make([]*net.UnixConn, 2)
+	for ii, fd := range fds {
+		f := os.NewFile(uintptr(fd), "wayland-test")
+		conn, err := net.FileConn(f)
+		_ = f.Close() // FileConn duplicates the file descriptor.
+		if err != nil {
+			t.Fatalf("Failed to create connection from socket: %v", err)
+		}
+		conns[ii] = conn.(*net.UnixConn)
+	}
+	t.Cleanup(func() {
+		_ = conns[0].Close()
+		_ = conns[1].Close()
+	})
+	c := &waylandClient{
+		conn:    conns[0],
+		nextID:  wlDisplayID + 1,
+		globals: make(map[string]wlGlobal),
+		sources: make(map[uint32]content),
+		offers:  make(map[uint32][]string),
+	}
+	return c, conns[1]
+}
+
+func mustEncode(t *testing.T, object uint32, opcode uint16, args ...interface{}) []byte {
+	msg, err := encodeMessage(object, opcode, args...)
+	if err != nil {
+		t.Fatalf("encodeMessage() failed: %v", err)
+	}
+	return msg
+}
+
+func TestWaylandRequest(t *testing.T) {
+	c, peer := newTestWaylandClient(t)
+	if err := c.request(7, 3, nil, uint32(42), int32(-1), "abc", "abcd", ""); err != nil {
+		t.Fatalf("request() failed: %v", err)
+	}
+	want := []byte{
+		7, 0, 0, 0, // Object.
+		3, 0, 44, 0, // Opcode, and size in the upper 16 bits.
+		42, 0, 0, 0,
+		0xff, 0xff, 0xff, 0xff,
+		4, 0, 0, 0, 'a', 'b', 'c', 0, // Size includes the NUL, no padding needed.
+		5, 0, 0, 0, 'a', 'b', 'c', 'd', 0, 0, 0, 0, // Padded to 4 bytes.
+		1, 0, 0, 0, 0, 0, 0, 0,
+	}
+	got := make([]byte, len(want))
+	_ = peer.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := peer.Read(got); err != nil {
+		t.Fatalf("Failed to read request: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("request() sent\n%v, wanted\n%v", got, want)
+	}
+
+	args := wlArgs{data: got[wlHeaderSize:]}
+	if v := args.uint(); v != 42 {
+		t.Errorf("uint() = %d, wanted 42", v)
+	}
+	if v := int32(args.uint()); v != -1 {
+		t.Errorf("int = %d, wanted -1", v)
+	}
+	for _, s := range []string{"abc", "abcd", ""} {
+		if v := args.string(); v != s {
+			t.Errorf("string() = %q, wanted %q", v, s)
+		}
+	}
+	if len(args.data) != 0 {
+		t.Errorf("%d bytes left after decoding all arguments", len(args.data))
+	}
+
+	if err := c.request(7, 3, nil, 1.5); err == nil {
+		t.Errorf("request() with a float argument should fail")
+	}
+}
+
+func TestWaylandArgsString(t *testing.T) {
+	const sentinel = 0xdeadbeef
+	testCases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "null", data: []byte{0, 0, 0, 0}},
+		{name: "empty", data: []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "1 char", data: []byte{2, 0, 0, 0, 'a', 0, 0, 0}, want: "a"},
+		{name: "3 chars", data: []byte{4, 0, 0, 0, 'a', 'b', 'c', 0}, want: "abc"},
+		{name: "4 chars", data: []byte{5, 0, 0, 0, 'a', 'b', 'c', 'd', 0, 0, 0, 0}, want: "abcd"},
+	}
+	for _, tc := range testCases {
+		data := appendUint32(append([]byte(nil), tc.data...), sentinel)
+		args := wlArgs{data: data}
+		if got := args.string(); got != tc.want {
+			t.Errorf("%s: string() = %q, wanted %q", tc.name, got, tc.want)
+		}
+		if got := args.uint(); got != sentinel {
+			t.Errorf("%s: the padding wasn't skipped, next uint() = %#x", tc.name, got)
+		}
+	}
+
+	// Truncated: the size is larger than the data left.
+	args := wlArgs{data: []byte{9, 0, 0, 0, 'a', 'b', 'c', 'd'}}
+	if got := args.string(); got != "" {
+		t.Errorf("truncated: string() = %q, wanted empty", got)
+	}
+}
+
+func TestWaylandReadMessage(t *testing.T) {
+	c, peer := newTestWaylandClient(t)
+	first := mustEncode(t, 3, 1, "first message", uint32(1))
+	second := mustEncode(t, 4, 0, uint32(2))
+	third := mustEncode(t, 5, 2)
+	withFD := mustEncode(t, 6, dataSourceSendEvent, "text/plain")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	errs := make(chan error, 1)
+	go func() {
+		// The first message is split in several reads, the next two arrive in one read.
+		pieces := [][]byte{first[:3], first[3:10], first[10:], append(append([]byte(nil), second...), third...)}
+		for _, piece := range pieces {
+			if _, err := peer.Write(piece); err != nil {
+				errs <- err
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		_, _, err := peer.WriteMsgUnix(withFD, syscall.UnixRights(int(w.Fd())), nil)
+		_ = w.Close()
+		errs <- err
+	}()
+
+	for _, want := range [][]byte{first, second, third, withFD} {
+		sender, opcode, body, err := c.readMessage()
+		if err != nil {
+			t.Fatalf("readMessage() failed: %v", err)
+		}
+		if sender != wlEndian.Uint32(want) || uint32(opcode) != wlEndian.Uint32(want[4:])&0xffff ||
+			!bytes.Equal(body, want[wlHeaderSize:]) {
+			t.Errorf("readMessage() = (%d, %d, %v), wanted message %v", sender, opcode, body, want)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Failed to write messages: %v", err)
+	}
+	if len(c.in) != 0 {
+		t.Errorf("%d bytes left after reading all messages", len(c.in))
+	}
+
+	// The file descriptor received is a copy of the write end of the pipe.
+	fd, err := c.popFD()
+	if err != nil {
+		t.Fatalf("popFD() failed: %v", err)
+	}
+	f := os.NewFile(uintptr(fd), "received")
+	if _, err := f.Write([]byte("through the pipe")); err != nil {
+		t.Fatalf("Failed to write to file descriptor received: %v", err)
+	}
+	_ = f.Close()
+	if got, _ := ioutil.ReadAll(r); string(got) != "through the pipe" {
+		t.Errorf("Read %q from pipe, wanted %q", got, "through the pipe")
+	}
+	if _, err := c.popFD(); err == nil {
+		t.Errorf("popFD() with no file descriptors left should fail")
+	}
+}
+
+func TestWaylandReadMessageInvalidSize(t *testing.T) {
+	c, peer := newTestWaylandClient(t)
+	go func() { _, _ = peer.Write([]byte{3, 0, 0, 0, 1, 0, 4, 0}) }()
+	if _, _, _, err := c.readMessage(); err == nil {
+		t.Errorf("readMessage() of a message smaller than its header should fail")
+	}
+}
+
+// TestWaylandSendToCancelledSource checks that the file descriptor of a "send" event is consumed
+// (and closed) also when its source was cancelled, so the next events get theirs.
+func TestWaylandSendToCancelledSource(t *testing.T) {
+	c, _ := newTestWaylandClient(t)
+	const cancelled, live = 5, 6
+	c.sources[cancelled] = newTextContent("old")
+	c.sources[live] = newTextContent("new")
+	c.handleEvent(cancelled, dataSourceCancelledEvent, nil)
+
+	readers := make([]*os.File, 2)
+	for ii := range readers {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("Failed to create pipe: %v", err)
+		}
+		fd, err := syscall.Dup(int(w.Fd()))
+		_ = w.Close()
+		if err != nil {
+			t.Fatalf("Failed to duplicate file descriptor: %v", err)
+		}
+		readers[ii] = r
+		c.fds = append(c.fds, fd)
+	}
+	defer func() {
+		for _, r := range readers {
+			_ = r.Close()
+		}
+	}()
+
+	body := mustEncode(t, 0, 0, "text/plain")[wlHeaderSize:]
+	c.handleEvent(cancelled, dataSourceSendEvent, body)
+	if len(c.fds) != 1 {
+		t.Fatalf("%d file descriptors queued after a send to a cancelled source, wanted 1", len(c.fds))
+	}
+	c.handleEvent(live, dataSourceSendEvent, body)
+	if len(c.fds) != 0 {
+		t.Fatalf("%d file descriptors queued after all sends, wanted 0", len(c.fds))
+	}
+	for ii, want := range []string{"", "new"} {
+		_ = readers[ii].SetReadDeadline(time.Now().Add(time.Second))
+		got, err := ioutil.ReadAll(readers[ii])
+		if err != nil || string(got) != want {
+			t.Errorf("Pipe %d: read (%q, %v), wanted %q and the pipe closed", ii, got, err, want)
+		}
+	}
+
+	// The cancelled source is forgotten once the compositor confirms its deletion.
+	if err := c.handleDisplayEvent(wlDisplayDeleteIDEvent, appendUint32(nil, cancelled)); err != nil {
+		t.Fatalf("handleDisplayEvent(delete_id) failed: %v", err)
+	}
+	if _, found := c.sources[cancelled]; found {
+		t.Errorf("Cancelled source still known after its deletion")
+	}
+	if _, found := c.sources[live]; !found {
+		t.Errorf("Live source forgotten after the deletion of another one")
+	}
+}
+
+// TestWaylandCompositor copies text with one client and pastes it with another, through the
+// compositor. It only runs with WAYLAND_DISPLAY set, and it replaces the clipboard content: use a
+// headless compositor, e.g.:
+//
+//	weston --backend=headless-backend.so --socket=goshot-test &
+//	WAYLAND_DISPLAY=goshot-test go test ./clipboard -run Wayland
+//
+// It's skipped if the compositor doesn't support the data control protocols.
+func TestWaylandCompositor(t *testing.T) {
+	if os.Getenv("WAYLAND_DISPLAY") == "" {
+		t.Skip("WAYLAND_DISPLAY not set")
+	}
+	clients := make([]*waylandClient, 2)
+	for ii := range clients {
+		c, err := newWaylandClient()
+		if err != nil {
+			t.Skipf("Wayland clipboard not available: %v", err)
+		}
+		defer func() { _ = c.conn.Close() }()
+		go c.eventLoop()
+		clients[ii] = c
+	}
+
+	const text = "GoShot Wayland clipboard test"
+	if err := clients[0].setSelection(newTextContent(text)); err != nil {
+		t.Fatalf("setSelection() failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _, err := clients[1].paste(textTargets)
+		if err == nil && string(data) == text {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("paste() = (%q, %v), wanted %q", data, err, text)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"golang.org/x/image/bmp"
 	"image"
 	"image/png"
 	"strings"
@@ -28,13 +29,28 @@ import (
 )
 
 var (
-	user32                      = syscall.MustLoadDLL("user32.dll")
-	procRegisterClipboardFormat = user32.MustFindProc("RegisterClipboardFormatA")
+	user32                         = syscall.MustLoadDLL("user32.dll")
+	procRegisterClipboardFormat    = user32.MustFindProc("RegisterClipboardFormatA")
+	procGetClipboardData           = user32.MustFindProc("GetClipboardData")
+	procIsClipboardFormatAvailable = user32.MustFindProc("IsClipboardFormatAvailable")
+
+	kernel32         = syscall.MustLoadDLL("kernel32.dll")
+	procGlobalLock   = kernel32.MustFindProc("GlobalLock")
+	procGlobalUnlock = kernel32.MustFindProc("GlobalUnlock")
+	procGlobalSize   = kernel32.MustFindProc("GlobalSize")
 )
 
 const (
 	LCS_WINDOWS_COLOR_SPACE = 0x57696E20
 
+	// Standard clipboard formats used when pasting.
+	cfDIB         = 8
+	cfUnicodeText = 13
+
+	// Size of the BITMAPFILEHEADER, prepended to CF_DIB data to decode it as a BMP file.
+	bitmapFileHeaderSize = 14
+	biBitFields          = 3
+
 	clipboardOpenMaxTime = 1 * time.Second
 )
 
@@ -93,6 +109,9 @@ func CopyImage(img image.Image) error {
 	})
 	dibV5Data = 0
 	pngData = 0
+	if err == nil {
+		addToHistory(img, nil)
+	}
 	return err
 }
 
@@ -322,3 +341,109 @@ func imageToHMLEncode(img image.Image) string {
 			suffix,
 		}, "")
 }
+
+// PasteImage returns the image in the clipboard. It returns ErrNotAvailable if the
+// clipboard doesn't hold an image.
+//
+// It reads the "PNG" registered format if available, otherwise CF_DIB.
+func PasteImage() (image.Image, error) {
+	glog.V(2).Infof("PasteImage()")
+	if data, err := safeGetClipboardData(registerClipboardFormat("PNG")); err == nil {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode clipboard PNG image: %w", err)
+		}
+		return img, nil
+	} else if err != ErrNotAvailable {
+		return nil, err
+	}
+
+	dib, err := safeGetClipboardData(cfDIB)
+	if err != nil {
+		return nil, err
+	}
+	img, err := bmp.Decode(bytes.NewReader(dibToBMP(dib)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode clipboard bitmap: %w", err)
+	}
+	return img, nil
+}
+
+// PasteText returns the text in the clipboard. It returns ErrNotAvailable if the
+// clipboard doesn't hold text.
+func PasteText() (string, error) {
+	glog.V(2).Infof("PasteText()")
+	data, err := safeGetClipboardData(cfUnicodeText)
+	if err != nil {
+		return "", err
+	}
+	text := make([]uint16, len(data)/2)
+	for ii := range text {
+		text[ii] = uint16(data[2*ii]) | uint16(data[2*ii+1])<<8
+	}
+	return syscall.UTF16ToString(text), nil
+}
+
+// safeGetClipboardData returns a copy of the clipboard data in the given format, or
+// ErrNotAvailable if the clipboard has no data in that format.
+func safeGetClipboardData(format uint32) ([]byte, error) {
+	// See safeSetClipboardData on why we need to lock the OS thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if r, _, _ := procIsClipboardFormatAvailable.Call(uintptr(format)); r == 0 {
+		return nil, ErrNotAvailable
+	}
+	err := waitOpenClipboard()
+	if err != nil {
+		return nil, err
+	}
+	defer win.CloseClipboard()
+
+	handle, _, _ := procGetClipboardData.Call(uintptr(format))
+	if handle == 0 {
+		return nil, ErrNotAvailable
+	}
+	size, _, _ := procGlobalSize.Call(handle)
+	ptr, _, _ := procGlobalLock.Call(handle)
+	if ptr == 0 {
+		return nil, errors.New("failed GlobalLock() of clipboard data")
+	}
+	defer procGlobalUnlock.Call(handle)
+	return C.GoBytes(unsafe.Pointer(ptr), C.int(size)), nil
+}
+
+// dibToBMP prepends a BITMAPFILEHEADER to the CF_DIB data, so it can be decoded as a BMP file.
+func dibToBMP(dib []byte) []byte {
+	if len(dib) < 40 {
+		return dib
+	}
+	le := func(b []byte) uint32 { return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24 }
+	headerSize := le(dib[0:])
+	bitCount := uint32(dib[14]) | uint32(dib[15])<<8
+	compression := le(dib[16:])
+	colorsUsed := le(dib[32:])
+	offset := bitmapFileHeaderSize + headerSize
+	if compression == biBitFields && headerSize == 40 {
+		offset += 12 // Color masks follow the header.
+	}
+	if colorsUsed == 0 && bitCount <= 8 {
+		colorsUsed = 1 << bitCount
+	}
+	offset += 4 * colorsUsed
+
+	bmpData := make([]byte, bitmapFileHeaderSize, bitmapFileHeaderSize+len(dib))
+	bmpData[0], bmpData[1] = 'B', 'M'
+	put := func(b []byte, v uint32) { b[0], b[1], b[2], b[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24) }
+	put(bmpData[2:], uint32(bitmapFileHeaderSize+len(dib)))
+	put(bmpData[10:], offset)
+	return append(bmpData, dib...)
+}
+
+// Persist is a no-op: the system keeps the clipboard content after GoShot exits.
+func Persist() error {
+	return nil
+}
+
+// RunHelperIfRequested is a no-op: there is no clipboard helper process in this platform.
+func RunHelperIfRequested() {}
@@ -6,10 +6,11 @@ package clipboard
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"github.com/golang/glog"
 	"image"
-	"image/png"
 	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -29,69 +30,125 @@ Window macro_DefaultRootWindow(Display *dpy) { return DefaultRootWindow(dpy); }
 Atom macro_XA_CLIPBOARD(Display *dpy) { return XA_CLIPBOARD(dpy); }
 long macro_XMaxRequestSize(Display *dpy) { return XMaxRequestSize(dpy); }
 long macro_XExtendedMaxRequestSize(Display *dpy) { return XExtendedMaxRequestSize(dpy); }
-Atom macro_XA_UTF8_STRING(Display *dpy) { return XA_UTF8_STRING(dpy); }
 */
 import "C"
 
 var clipboardOnce sync.Once
 
-const (
-	ImageTarget = "image/png"
-)
-
 var (
 	failure error
 
 	// X11 globals
-	display                              *C.Display
-	window                               C.Window
-	atomClipboardSelection               C.Atom
-	atomPNGTarget, atomIncr, atomTargets C.Atom
-	targetPartSize                       int
-
-	atomTextTargets []C.Atom
-	textTargets     = []string{"text/plain;charset=utf-8", "text/plain"}
+	display                *C.Display
+	window                 C.Window
+	atomClipboardSelection C.Atom
+	atomIncr, atomTargets  C.Atom
+	atomMultiple           C.Atom
+	targetPartSize         int
+	atomsByName            = make(map[string]C.Atom)
+	atomsMu                sync.Mutex
 
 	// clipboard global properties
 	hasClipboardOwnership bool
-	currentContent        []byte
-	currentContentTarget  C.Atom
+	currentContent        content
+
+	// saveTargetsDone receives the result of the request to the clipboard manager to
+	// save our content.
+	saveTargetsDone = make(chan bool, 1)
+
+	// Paste requests: only one at a time, serialized by pasteMu. The content is received
+	// in the pasteProperty of our window, and the result is sent to pasteResults.
+	// pasteIncr holds the content received so far during an incremental transfer,
+	// and it is only accessed from the event loop.
+	pasteMu       sync.Mutex
+	pasteProperty C.Atom
+	pasteResults  = make(chan pasteResult, 1)
+	pasteIncr     *bytes.Buffer
 )
 
-func CopyImage(img image.Image) error {
-	var contentBuffer bytes.Buffer
-	png.Encode(&contentBuffer, img)
-	content := contentBuffer.Bytes()
+type pasteResult struct {
+	data []byte
+	err  error
+}
 
-	glog.V(2).Infof("CopyImage(%d bytes)", len(content))
-	clipboardOnce.Do(func() { initX11() })
-	if failure != nil {
-		glog.Errorf("clipboard.CopyImage: %s", failure)
-		return failure
-	}
+// SaveTargetsTimeout is how long to wait for the clipboard manager to save the clipboard
+// content, when GoShot exits.
+var SaveTargetsTimeout = 5 * time.Second
 
-	C.XSetSelectionOwner(display, atomClipboardSelection, window, C.CurrentTime)
-	currentContent = content
-	currentContentTarget = atomPNGTarget
-	hasClipboardOwnership = true
-	return nil
+func x11CopyImage(img image.Image) error {
+	glog.V(2).Infof("CopyImage(bounds=%+v)", img.Bounds())
+	return x11SetSelection(newImageContent(img))
 }
 
-func CopyText(text string) error {
+func x11CopyText(text string) error {
 	glog.V(2).Infof("CopyText(%d bytes)", len(text))
+	return x11SetSelection(newTextContent(text))
+}
+
+// x11SetSelection takes ownership of the clipboard selection, offering the given content.
+func x11SetSelection(offered content) error {
 	clipboardOnce.Do(func() { initX11() })
 	if failure != nil {
-		glog.Errorf("clipboard.CopyText: %s", failure)
+		glog.Errorf("clipboard: %s", failure)
 		return failure
 	}
 
 	C.XSetSelectionOwner(display, atomClipboardSelection, window, C.CurrentTime)
-	currentContent = []byte(text)
-	currentContentTarget = atomTextTargets[0]
+	currentContent = offered
 	hasClipboardOwnership = true
 	return nil
 }
 
+// x11Persist hands the clipboard content over to the clipboard manager, following the
+// ICCCM (`CLIPBOARD_MANAGER` selection and `SAVE_TARGETS` target), so it is still available
+// after GoShot exits. If there is no clipboard manager, or if it fails, a helper process
+// is started to keep serving the content.
+func x11Persist() error {
+	if failure != nil || !hasClipboardOwnership || currentContent == nil {
+		return nil
+	}
+	offered := currentContent
+	atomManager := getAtomFromName("CLIPBOARD_MANAGER")
+	if C.XGetSelectionOwner(display, atomManager) == C.None {
+		glog.V(1).Infof("No X11 clipboard manager, starting clipboard helper.")
+		return startHelper(offered)
+	}
+
+	// Drain result of any previous request.
+	select {
+	case <-saveTargetsDone:
+	default:
+	}
+
+	// List the targets we want saved in a property of our window.
+	var saveTargets []C.Atom
+	for _, tgt := range offered.saveTargets() {
+		saveTargets = append(saveTargets, getAtomFromName(tgt))
+	}
+	property := getAtomFromName("GOSHOT_SAVE_TARGETS")
+	C.XChangeProperty(display, window, property, C.XA_ATOM,
+		/* format: 32 bits */ 32 /* mode */, C.PropModeReplace,
+		(*C.uchar)(unsafe.Pointer(&saveTargets[0])), C.int(len(saveTargets)))
+	C.XConvertSelection(display, atomManager, getAtomFromName("SAVE_TARGETS"), property, window, C.CurrentTime)
+	C.XFlush(display)
+
+	select {
+	case saved := <-saveTargetsDone:
+		if saved {
+			glog.V(1).Infof("Clipboard content saved by the X11 clipboard manager.")
+			return nil
+		}
+		glog.Warningf("X11 clipboard manager failed to save the clipboard content.")
+	case <-time.After(SaveTargetsTimeout):
+		glog.Warningf("X11 clipboard manager didn't save the clipboard content within %s.", SaveTargetsTimeout)
+	}
+	if !hasClipboardOwnership {
+		// Someone else took over the clipboard in the meantime.
+		return nil
+	}
+	return startHelper(offered)
+}
+
 // initX11 will start a hidden window to receive selection (clipboard)
 // events and act on them. Should be called only once.
 func initX11() {
@@ -102,16 +159,10 @@ func initX11() {
 		return
 	}
 	atomClipboardSelection = C.macro_XA_CLIPBOARD(display)
-
-	atomPNGTarget = getAtomFromName(ImageTarget)
-	atomTextTargets = make([]C.Atom, 0, len(textTargets)+1)
-	atomTextTargets = append(atomTextTargets, C.macro_XA_UTF8_STRING(display))
-	for _, tgt := range textTargets {
-		atomTextTargets = append(atomTextTargets, getAtomFromName(tgt))
-	}
 	atomIncr = getAtomFromName("INCR")
 	atomTargets = getAtomFromName("TARGETS")
-	glog.V(2).Infof("- Atom for \"image/png\" target=%+v", atomPNGTarget)
+	atomMultiple = getAtomFromName("MULTIPLE")
+	pasteProperty = getAtomFromName("GOSHOT_PASTE")
 
 	xExtendedMaxRequestSize := C.macro_XExtendedMaxRequestSize(display)
 	xMaxRequestSize := C.macro_XMaxRequestSize(display)
@@ -147,12 +198,30 @@ func x11EventLoop() {
 			}
 
 		case PropertyNotifyEventType:
+			if (*C.XPropertyEvent)(unsafe.Pointer(xev)).window == window {
+				// Changes to our own window's properties: parts of incremental paste transfers,
+				// or SAVE_TARGETS.
+				handlePastePart(xev)
+				continue
+			}
 			handleSelectionRequest(xev)
 
+		case SelectionNotifyEventType:
+			selEv := (*C.XSelectionEvent)(unsafe.Pointer(xev))
+			if selEv.target == getAtomFromName("SAVE_TARGETS") {
+				select {
+				case saveTargetsDone <- selEv.property != C.None:
+				default:
+				}
+			} else {
+				handlePasteNotify(xev)
+			}
+
 		case SelectionClearEventType:
 			glog.V(2).Infof("We lost clipboard ownership")
 			hasClipboardOwnership = false // No longer owner, but has to continue serving ongoing transfer.
 			currentContent = nil
+			notifyOwnershipLost()
 
 		default:
 			glog.Infof("Unhandled event type %d")
@@ -177,7 +246,7 @@ func handleSelectionRequest(xev *C.XEvent) {
 		if !found {
 			r = &RequestHandler{
 				win:     selEv.requestor,
-				content: currentContent,
+				offered: currentContent,
 			}
 			liveRequests[r.win] = r
 		}
@@ -186,7 +255,6 @@ func handleSelectionRequest(xev *C.XEvent) {
 			glog.V(2).Infof("- selection: %q", getNameFromAtom(selEv.selection))
 			glog.V(2).Infof("- target: %q", getNameFromAtom(selEv.target))
 			glog.V(2).Infof("- property: %q", getNameFromAtom(selEv.property))
-			r.position = 0
 			r.selection = selEv.selection
 			r.target = selEv.target
 			r.property = selEv.property
@@ -198,24 +266,20 @@ func handleSelectionRequest(xev *C.XEvent) {
 				return // No response.
 			}
 
-			textContainsFn := func(a C.Atom) bool {
-				for _, listA := range atomTextTargets {
-					if listA == a {
-						return true
-					}
-				}
-				return false
+			if r.offered == nil {
+				glog.Warningf("- Clipboard request, but we have no content.")
+				delete(liveRequests, r.win)
+				return // No response.
 			}
-
 			if selEv.target == atomTargets {
 				finished = r.ReportTargets()
-			} else if selEv.target == currentContentTarget {
-				finished = r.SendContent()
-			} else if currentContentTarget == atomTextTargets[0] && textContainsFn(selEv.target) {
+			} else if selEv.target == atomMultiple {
+				finished = r.SendMultiple()
+			} else if r.offers(selEv.target) {
 				finished = r.SendContent()
 			} else {
-				glog.Warningf("- Unknown clipboard request of type %q, serving target %q",
-					getNameFromAtom(selEv.target), getNameFromAtom(currentContentTarget))
+				glog.Warningf("- Unknown clipboard request of type %q, serving targets %v",
+					getNameFromAtom(selEv.target), r.offered.targets())
 				delete(liveRequests, r.win)
 				return // No response.
 			}
@@ -246,7 +310,7 @@ func handleSelectionRequest(xev *C.XEvent) {
 			glog.Warningf("Ignoring property state %d, we only care about delete", propEv.state)
 			return
 		}
-		finished = r.SendContentPart()
+		finished = r.SendContentPart(propEv.atom)
 	}
 
 	if finished {
@@ -258,20 +322,67 @@ var liveRequests = make(map[C.Window]*RequestHandler)
 
 type RequestHandler struct {
 	win                         C.Window
-	content                     []byte // Copy reference since, content may change while still serving previous one.
-	position                    int
+	offered                     content // Copy reference since, content may change while still serving previous one.
 	state                       requestState
 	selection, target, property C.Atom
 	time                        C.Time
+
+	// transfers are the incremental (INCR) transfers in progress, by the requestor property
+	// they are sent to: a MULTIPLE request may have more than one.
+	transfers map[C.Atom]*incrTransfer
+}
+
+// incrTransfer is the content of one target being sent incrementally, in parts of at most
+// targetPartSize bytes.
+type incrTransfer struct {
+	target   C.Atom
+	content  []byte
+	position int
+}
+
+// nextPart returns the next part of the content, of at most `partSize` bytes. An empty
+// part, sent after all the content, signals the end of the transfer.
+func (t *incrTransfer) nextPart(partSize int) []byte {
+	amount := len(t.content) - t.position
+	if amount > partSize {
+		amount = partSize
+	}
+	part := t.content[t.position : t.position+amount]
+	t.position += amount
+	return part
+}
+
+// startIncr starts the incremental transfer of `data` in the requestor `property`: it sets the
+// property to the INCR type, and the parts are sent by SendContentPart as the requestor deletes it.
+func (r *RequestHandler) startIncr(target, property C.Atom, data []byte) {
+	glog.V(2).Infof("startIncr(%q): %d bytes to send in parts.", getNameFromAtom(target), len(data))
+	size := C.long(len(data)) // Lower bound of the size, as a 32 bits value.
+	C.XChangeProperty(display, r.win, property, atomIncr,
+		/* format: 32 bits */ 32 /* mode */, C.PropModeReplace, (*C.uchar)(unsafe.Pointer(&size)), 1)
+	// Need to follow requestor property changes.
+	C.XSelectInput(display, r.win, C.PropertyChangeMask)
+	if r.transfers == nil {
+		r.transfers = make(map[C.Atom]*incrTransfer)
+	}
+	r.transfers[property] = &incrTransfer{target: target, content: data}
+	r.state = Incremental
+}
+
+// offers returns whether the requested target is offered.
+func (r *RequestHandler) offers(target C.Atom) bool {
+	for _, tgt := range r.offered.targets() {
+		if getAtomFromName(tgt) == target {
+			return true
+		}
+	}
+	return false
 }
 
 // ReportTargets reports available targets and whether request was finished.
 func (r *RequestHandler) ReportTargets() bool {
-	var data = []C.Atom{atomTargets}
-	if currentContentTarget != atomTextTargets[0] {
-		data = append(data, currentContentTarget)
-	} else {
-		data = append(data, atomTextTargets...)
+	var data = []C.Atom{atomTargets, atomMultiple}
+	for _, tgt := range r.offered.targets() {
+		data = append(data, getAtomFromName(tgt))
 	}
 	C.XChangeProperty(display, r.win, r.property, C.XA_ATOM,
 		/* format: 32 bits */ 32 /* mode */, C.PropModeReplace,
@@ -279,49 +390,263 @@ func (r *RequestHandler) ReportTargets() bool {
 	return true
 }
 
+// SendContent encodes the content in the requested target and sends it, if small enough.
+// Otherwise, it starts an incremental (INCR) transfer. It returns whether the request was finished.
 func (r *RequestHandler) SendContent() bool {
-	if len(r.content) <= targetPartSize {
-		glog.V(2).Infof("SendContent(): %d bytes at once.", len(r.content))
+	data, err := r.offered.data(getNameFromAtom(r.target))
+	if err != nil {
+		glog.Errorf("SendContent(): %v", err)
+		// Refuse the conversion.
+		r.property = C.None
+		return true
+	}
+	if len(data) <= targetPartSize {
+		glog.V(2).Infof("SendContent(): %d bytes at once.", len(data))
 		// Send all at once.
+		var dataPtr *C.uchar
+		if len(data) > 0 {
+			dataPtr = (*C.uchar)(unsafe.Pointer(&data[0]))
+		}
 		C.XChangeProperty(display, r.win, r.property, r.target,
-			/* format: byte */ 8 /* mode */, C.PropModeReplace,
-			(*C.uchar)(unsafe.Pointer(&r.content[0])), C.int(len(r.content)))
+			/* format: byte */ 8 /* mode */, C.PropModeReplace, dataPtr, C.int(len(data)))
 		return true
 	}
 
 	// Send in parts.
-	glog.V(2).Infof("SendContent(): send back request to send in parts.")
-	C.XChangeProperty(display, r.win, r.property, atomIncr,
-		/* format: 32 bits */ 32 /* mode */, C.PropModeReplace, nil, 0)
-	// Need to follow requestor property changes.
-	C.XSelectInput(display, r.win, C.PropertyChangeMask)
-	r.state = Incremental
+	r.startIncr(r.target, r.property, data)
 	return false
 }
 
-func (r *RequestHandler) SendContentPart() bool {
-	missing := len(r.content) - r.position
-	amount := missing
-	if amount > targetPartSize {
-		amount = targetPartSize
-	}
-	glog.V(2).Infof("SendContentPart(): %d bytes missing, sending %d.", missing, amount)
-	if amount > 0 {
-		C.XChangeProperty(display, r.win, r.property, atomPNGTarget,
-			/* byte */ 8, C.PropModeReplace,
-			(*C.uchar)(unsafe.Pointer(&r.content[r.position])), C.int(amount))
-	} else {
-		// Signals end of transfer.
-		C.XChangeProperty(display, r.win, r.property, atomPNGTarget,
-			/* byte */ 8, C.PropModeReplace, nil, 0)
+// SendMultiple serves a MULTIPLE request, used by clipboard managers: the requestor property
+// holds a list of (target, property) pairs, and each target is converted to its property.
+// Conversions that fail are refused by replacing the property in the pair with None. Targets too
+// large to be sent at once are sent incrementally (INCR), each in its own property. It returns
+// whether the request was finished.
+func (r *RequestHandler) SendMultiple() bool {
+	if r.property == C.None {
+		glog.Warningf("SendMultiple(): requested without a property, refusing.")
+		return true
+	}
+	var actualType C.Atom
+	var actualFormat C.int
+	var numItems, bytesAfter C.ulong
+	var prop *C.uchar
+	if C.XGetWindowProperty(display, r.win, r.property, 0, C.long(targetPartSize), C.False,
+		C.AnyPropertyType, &actualType, &actualFormat, &numItems, &bytesAfter, &prop) != C.Success || prop == nil {
+		glog.Warningf("SendMultiple(): failed to read list of targets, refusing.")
+		r.property = C.None
+		return true
+	}
+	defer C.XFree(unsafe.Pointer(prop))
+	if actualFormat != 32 || numItems%2 != 0 {
+		glog.Warningf("SendMultiple(): invalid list of targets (format=%d, %d items), refusing.",
+			actualFormat, numItems)
+		r.property = C.None
+		return true
+	}
+
+	// Properties of format 32 are returned as an array of longs, the size of C.Atom.
+	pairs := make([]C.Atom, int(numItems))
+	copy(pairs, (*[1 << 20]C.Atom)(unsafe.Pointer(prop))[:numItems:numItems])
+	for ii := 0; ii < len(pairs); ii += 2 {
+		target, property := pairs[ii], pairs[ii+1]
+		if property == C.None || !r.offers(target) {
+			pairs[ii+1] = C.None
+			continue
+		}
+		data, err := r.offered.data(getNameFromAtom(target))
+		if err != nil {
+			glog.Warningf("SendMultiple(): refusing target %q: %v", getNameFromAtom(target), err)
+			pairs[ii+1] = C.None
+			continue
+		}
+		if len(data) > targetPartSize {
+			r.startIncr(target, property, data)
+			continue
+		}
+		var dataPtr *C.uchar
+		if len(data) > 0 {
+			dataPtr = (*C.uchar)(unsafe.Pointer(&data[0]))
+		}
+		C.XChangeProperty(display, r.win, property, target,
+			/* format: byte */ 8 /* mode */, C.PropModeReplace, dataPtr, C.int(len(data)))
+	}
+	if len(pairs) > 0 {
+		C.XChangeProperty(display, r.win, r.property, getAtomFromName("ATOM_PAIR"),
+			/* format: 32 bits */ 32 /* mode */, C.PropModeReplace,
+			(*C.uchar)(unsafe.Pointer(&pairs[0])), C.int(len(pairs)))
+	}
+	return len(r.transfers) == 0
+}
+
+// SendContentPart sends the next part of the incremental transfer to the requestor `property`,
+// after the requestor deleted it. It returns whether all the transfers of the request are finished.
+func (r *RequestHandler) SendContentPart(property C.Atom) bool {
+	t, found := r.transfers[property]
+	if !found {
+		// E.g. the requestor deleting the list of targets of a MULTIPLE request.
+		glog.V(2).Infof("SendContentPart(): ignoring deletion of property %q.", getNameFromAtom(property))
+		return len(r.transfers) == 0
+	}
+	part := t.nextPart(targetPartSize)
+	glog.V(2).Infof("SendContentPart(%q): %d bytes missing, sending %d.",
+		getNameFromAtom(t.target), len(t.content)-t.position+len(part), len(part))
+	var partPtr *C.uchar
+	if len(part) > 0 {
+		partPtr = (*C.uchar)(unsafe.Pointer(&part[0]))
+	}
+	// An empty part signals the end of the transfer.
+	C.XChangeProperty(display, r.win, property, t.target,
+		/* byte */ 8, C.PropModeReplace, partPtr, C.int(len(part)))
+	C.XFlush(display)
+	if len(part) == 0 {
+		glog.V(2).Infof("- transfer of %q finished", getNameFromAtom(t.target))
+		delete(r.transfers, property)
+	}
+	if len(r.transfers) > 0 {
+		return false
+	}
+	r.state = Initial
+	return true
+}
+
+// x11Paste returns the clipboard content in the first of the `preferred` targets offered.
+func x11Paste(preferred []string) (data []byte, target string, err error) {
+	clipboardOnce.Do(func() { initX11() })
+	if failure != nil {
+		return nil, "", failure
+	}
+	if offered := currentContent; hasClipboardOwnership && offered != nil {
+		// We own the selection: no need to go through the X server.
+		target = chooseTarget(preferred, offered.targets())
+		if target == "" {
+			return nil, "", ErrNotAvailable
+		}
+		data, err = offered.data(target)
+		return data, target, err
+	}
+
+	pasteMu.Lock()
+	defer pasteMu.Unlock()
+	data, err = x11Convert(atomTargets)
+	if err != nil {
+		return nil, "", err
+	}
+	var targets []string
+	atomSize := int(unsafe.Sizeof(C.Atom(0)))
+	for ii := 0; ii+atomSize <= len(data); ii += atomSize {
+		targets = append(targets, getNameFromAtom(*(*C.Atom)(unsafe.Pointer(&data[ii]))))
+	}
+	glog.V(2).Infof("x11Paste(): targets offered %v", targets)
+	target = chooseTarget(preferred, targets)
+	if target == "" {
+		return nil, "", ErrNotAvailable
+	}
+	data, err = x11Convert(getAtomFromName(target))
+	return data, target, err
+}
+
+// x11Convert asks the clipboard owner to convert the selection to the given target, and waits
+// for the result. It must be called with pasteMu locked.
+func x11Convert(target C.Atom) ([]byte, error) {
+	// Drain result of any previous request that timed out.
+	select {
+	case <-pasteResults:
+	default:
 	}
+	C.XConvertSelection(display, atomClipboardSelection, target, pasteProperty, window, C.CurrentTime)
 	C.XFlush(display)
-	r.position += amount
-	if amount == 0 {
-		glog.V(2).Info("- transfer finished")
-		r.state = Initial
+	select {
+	case result := <-pasteResults:
+		return result.data, result.err
+	case <-time.After(PasteTimeout):
+		return nil, fmt.Errorf("timed out waiting for clipboard content as %q", getNameFromAtom(target))
+	}
+}
+
+// handlePasteNotify handles the response of the clipboard owner to a paste request: the content
+// is either in the paste property, or it is the start of an incremental transfer.
+func handlePasteNotify(xev *C.XEvent) {
+	selEv := (*C.XSelectionEvent)(unsafe.Pointer(xev))
+	if selEv.property == C.None {
+		sendPasteResult(nil, fmt.Errorf("clipboard owner refused to convert content to %q",
+			getNameFromAtom(selEv.target)))
+		return
+	}
+	if selEv.property != pasteProperty {
+		glog.Warningf("Ignoring selection notification for property %q", getNameFromAtom(selEv.property))
+		return
+	}
+	// Reading deletes the property, which, for incremental transfers, signals the owner to start sending.
+	data, propType, err := readProperty(window, pasteProperty)
+	if err != nil {
+		sendPasteResult(nil, err)
+		return
+	}
+	if propType == atomIncr {
+		glog.V(2).Infof("handlePasteNotify(): incremental transfer started.")
+		pasteIncr = &bytes.Buffer{}
+		return
+	}
+	pasteIncr = nil
+	sendPasteResult(data, nil)
+}
+
+// handlePastePart handles the parts of an incremental paste transfer: a zero length part ends the transfer.
+func handlePastePart(xev *C.XEvent) {
+	propEv := (*C.XPropertyEvent)(unsafe.Pointer(xev))
+	if pasteIncr == nil || propEv.atom != pasteProperty || propEv.state != C.PropertyNewValue {
+		return
+	}
+	data, _, err := readProperty(window, pasteProperty)
+	if err != nil {
+		pasteIncr = nil
+		sendPasteResult(nil, err)
+		return
+	}
+	if len(data) > 0 {
+		pasteIncr.Write(data)
+		return
+	}
+	glog.V(2).Infof("handlePastePart(): incremental transfer finished with %d bytes.", pasteIncr.Len())
+	sendPasteResult(pasteIncr.Bytes(), nil)
+	pasteIncr = nil
+}
+
+func sendPasteResult(data []byte, err error) {
+	select {
+	case pasteResults <- pasteResult{data: data, err: err}:
+	default:
+		glog.Warningf("Dropping clipboard content no one is waiting for.")
+	}
+}
+
+// readProperty reads and deletes the property of the window. Items of properties of format 32
+// are returned with the size of a C.long, as Xlib does.
+func readProperty(win C.Window, property C.Atom) (data []byte, propType C.Atom, err error) {
+	var offset C.long
+	for {
+		var actualFormat C.int
+		var numItems, bytesAfter C.ulong
+		var prop *C.uchar
+		if C.XGetWindowProperty(display, win, property, offset, C.long(targetPartSize), C.True,
+			C.AnyPropertyType, &propType, &actualFormat, &numItems, &bytesAfter, &prop) != C.Success {
+			return nil, 0, fmt.Errorf("failed to read property %q", getNameFromAtom(property))
+		}
+		itemSize := int(actualFormat) / 8
+		if actualFormat == 32 {
+			itemSize = int(unsafe.Sizeof(C.long(0)))
+		}
+		if prop != nil {
+			data = append(data, C.GoBytes(unsafe.Pointer(prop), C.int(int(numItems)*itemSize))...)
+			C.XFree(unsafe.Pointer(prop))
+		}
+		if bytesAfter == 0 {
+			return data, propType, nil
+		}
+		// Offset is given in 32-bit units.
+		offset += C.long(int(numItems) * int(actualFormat) / 32)
 	}
-	return amount == 0
 }
 
 type requestState int
@@ -358,10 +683,18 @@ func getWindowName(win C.Window) string {
 	return "window with no name"
 }
 
+// getAtomFromName returns the atom for the given name, creating it if needed. Atoms are cached.
 func getAtomFromName(name string) C.Atom {
+	atomsMu.Lock()
+	defer atomsMu.Unlock()
+	if atom, found := atomsByName[name]; found {
+		return atom
+	}
 	c := C.CString(name)
 	defer C.free(unsafe.Pointer(c))
-	return C.XInternAtom(display, c, C.False)
+	atom := C.XInternAtom(display, c, C.False)
+	atomsByName[name] = atom
+	return atom
 }
 
 func getNameFromAtom(atom C.Atom) string {
@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package clipboard
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// TestIncrTransferLargeImage checks that a PNG larger than the size of an X11 request is sent
+// in parts that add up to the whole content, followed by the empty part that ends the transfer.
+func TestIncrTransferLargeImage(t *testing.T) {
+	// Random pixels don't compress, so the PNG is larger than a request.
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	rng := rand.New(rand.NewSource(1))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 0xff})
+		}
+	}
+	data, err := newImageContent(img).data(ImageTarget)
+	if err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	const partSize = 64 * 1024 // Typical size for servers without BIG-REQUESTS.
+	if len(data) <= partSize {
+		t.Fatalf("PNG has %d bytes, it should be larger than the request size %d", len(data), partSize)
+	}
+
+	transfer := &incrTransfer{content: data}
+	var received bytes.Buffer
+	numParts := 0
+	for {
+		part := transfer.nextPart(partSize)
+		numParts++
+		if len(part) > partSize {
+			t.Fatalf("Part %d has %d bytes, more than the request size %d", numParts, len(part), partSize)
+		}
+		if len(part) == 0 {
+			break
+		}
+		received.Write(part)
+		if numParts > len(data)/partSize+1 {
+			t.Fatalf("Transfer didn't finish after %d parts", numParts)
+		}
+	}
+	if want := (len(data)+partSize-1)/partSize + 1; numParts != want {
+		t.Errorf("Transfer took %d parts (including the final empty one), wanted %d", numParts, want)
+	}
+	if !bytes.Equal(received.Bytes(), data) {
+		t.Fatalf("Received %d bytes that differ from the %d bytes sent", received.Len(), len(data))
+	}
+	decoded, err := png.Decode(&received)
+	if err != nil {
+		t.Fatalf("Failed to decode the PNG received: %v", err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Errorf("Decoded image has bounds %v, wanted %v", decoded.Bounds(), img.Bounds())
+	}
+}
@@ -0,0 +1,194 @@
+//go:build linux
+// +build linux
+
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang/glog"
+	"golang.org/x/image/bmp"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// content is what is offered in the clipboard, in one or more formats: targets in X11
+// parlance, mime types in Wayland.
+type content interface {
+	// targets returns the formats offered, the preferred one first.
+	targets() []string
+
+	// data returns the content encoded in the given target format.
+	data(target string) ([]byte, error)
+
+	// saveTargets returns the formats worth keeping by a clipboard manager, after GoShot exits.
+	saveTargets() []string
+}
+
+const (
+	ImageTarget   = "image/png"
+	JPEGTarget    = "image/jpeg"
+	BMPTarget     = "image/bmp"
+	HTMLTarget    = "text/html"
+	URIListTarget = "text/uri-list"
+)
+
+// imageTargets are the formats offered for images, the preferred one first.
+var imageTargets = []string{ImageTarget, JPEGTarget, BMPTarget, HTMLTarget, URIListTarget}
+
+// JPEGQuality used when an image is requested as JPEG.
+var JPEGQuality = 90
+
+// textTargets are the formats offered for text.
+var textTargets = []string{"text/plain;charset=utf-8", "UTF8_STRING", "text/plain", "STRING", "TEXT"}
+
+// chooseTarget returns the first of the `preferred` targets that is `offered`, or "" if none is.
+func chooseTarget(preferred, offered []string) string {
+	for _, want := range preferred {
+		for _, tgt := range offered {
+			if tgt == want {
+				return tgt
+			}
+		}
+	}
+	return ""
+}
+
+// textContent offers text in all textTargets.
+type textContent string
+
+func newTextContent(text string) content {
+	return textContent(text)
+}
+
+func (t textContent) targets() []string { return textTargets }
+
+func (t textContent) saveTargets() []string { return textTargets }
+
+func (t textContent) data(target string) ([]byte, error) {
+	for _, tgt := range textTargets {
+		if tgt == target {
+			return []byte(t), nil
+		}
+	}
+	return nil, fmt.Errorf("text not available as %q", target)
+}
+
+// imageContent offers an image in all imageTargets. Each format is encoded
+// only when first requested, and then cached.
+type imageContent struct {
+	img image.Image
+
+	mu      sync.Mutex
+	encoded map[string][]byte
+}
+
+func newImageContent(img image.Image) content {
+	return &imageContent{img: img, encoded: make(map[string][]byte)}
+}
+
+func (c *imageContent) targets() []string { return imageTargets }
+
+// saveTargets only includes PNG: the other formats can be large, and are derived from it.
+func (c *imageContent) saveTargets() []string { return []string{ImageTarget} }
+
+func (c *imageContent) data(target string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dataLocked(target)
+}
+
+// dataLocked implements data, it must be called with c.mu locked.
+func (c *imageContent) dataLocked(target string) ([]byte, error) {
+	if encoded, found := c.encoded[target]; found {
+		return encoded, nil
+	}
+	glog.V(2).Infof("clipboard: encoding image as %q", target)
+	var buf bytes.Buffer
+	switch target {
+	case ImageTarget:
+		if err := png.Encode(&buf, c.img); err != nil {
+			return nil, fmt.Errorf("failed to encode image as PNG: %w", err)
+		}
+	case JPEGTarget:
+		if err := jpeg.Encode(&buf, c.img, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode image as JPEG: %w", err)
+		}
+	case BMPTarget:
+		if err := bmp.Encode(&buf, c.img); err != nil {
+			return nil, fmt.Errorf("failed to encode image as BMP: %w", err)
+		}
+	case HTMLTarget:
+		pngData, err := c.dataLocked(ImageTarget)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`<img src="data:image/png;base64,`)
+		buf.WriteString(base64.StdEncoding.EncodeToString(pngData))
+		buf.WriteString(`"/>`)
+	case URIListTarget:
+		pngData, err := c.dataLocked(ImageTarget)
+		if err != nil {
+			return nil, err
+		}
+		fileName, err := writeTempImage(pngData)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString((&url.URL{Scheme: "file", Path: fileName}).String())
+		buf.WriteString("\r\n")
+	default:
+		return nil, fmt.Errorf("image not available as %q", target)
+	}
+	c.encoded[target] = buf.Bytes()
+	return c.encoded[target], nil
+}
+
+// lastTempImage is the last temporary file created by writeTempImage: it is removed when
+// the clipboard content is replaced or GoShot loses the clipboard ownership, see removeTempImage.
+var (
+	lastTempImageMu sync.Mutex
+	lastTempImage   string
+)
+
+// writeTempImage writes the PNG content to a temporary file, and returns its path.
+func writeTempImage(pngData []byte) (string, error) {
+	f, err := ioutil.TempFile("", "goshot-clipboard-*.png")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file for clipboard image: %w", err)
+	}
+	_, err = f.Write(pngData)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temporary file %q for clipboard image: %w", f.Name(), err)
+	}
+
+	removeTempImage()
+	lastTempImageMu.Lock()
+	defer lastTempImageMu.Unlock()
+	lastTempImage = f.Name()
+	return f.Name(), nil
+}
+
+// removeTempImage removes the temporary file created by writeTempImage, if any. It is called
+// when the content it was created for is no longer offered.
+func removeTempImage() {
+	lastTempImageMu.Lock()
+	defer lastTempImageMu.Unlock()
+	if lastTempImage == "" {
+		return
+	}
+	if err := os.Remove(lastTempImage); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("Failed to remove temporary clipboard image %q: %v", lastTempImage, err)
+	}
+	lastTempImage = ""
+}
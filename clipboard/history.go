@@ -0,0 +1,94 @@
+package clipboard
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+)
+
+// HistoryEntry is an image copied to the clipboard by GoShot.
+type HistoryEntry struct {
+	// Time when the image was copied.
+	Time time.Time
+
+	// Image copied: a copy is kept, so it's not affected by later changes to the image copied.
+	Image image.Image
+}
+
+// HistorySize is the maximum number of images kept in the clipboard history: older ones are
+// dropped. Set to 0 to disable the history.
+var HistorySize = 10
+
+var (
+	historyMu sync.Mutex
+	history   []HistoryEntry
+)
+
+// History returns the images copied to the clipboard, most recent first.
+func History() []HistoryEntry {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	entries := make([]HistoryEntry, len(history))
+	copy(entries, history)
+	return entries
+}
+
+// ClearHistory drops all the images in the clipboard history.
+func ClearHistory() {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history = nil
+}
+
+// addToHistory includes a copy of the image in the history. If the image is the one of
+// an entry of the history (e.g. copied again from the history), that entry is moved to
+// the front instead. If `clone` is not nil, it is the copy of the image to use, that the
+// caller won't change, and no other copy is made.
+func addToHistory(img, clone image.Image) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if HistorySize <= 0 {
+		history = nil
+		return
+	}
+	entry := HistoryEntry{Time: time.Now()}
+	for ii, e := range history {
+		if e.Image == img {
+			entry.Image = img
+			history = append(history[:ii], history[ii+1:]...)
+			break
+		}
+	}
+	if entry.Image == nil {
+		if clone == nil {
+			clone = cloneImage(img)
+		}
+		entry.Image = clone
+	}
+	history = append([]HistoryEntry{entry}, history...)
+	if len(history) > HistorySize {
+		history = history[:HistorySize]
+	}
+}
+
+// isInHistory returns whether the image is the one of an entry of the history: these are
+// never changed, so they don't need to be copied.
+func isInHistory(img image.Image) bool {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for _, e := range history {
+		if e.Image == img {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneImage returns a copy of the image.
+func cloneImage(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	clone := image.NewRGBA(bounds)
+	draw.Draw(clone, bounds, img, bounds.Min, draw.Src)
+	return clone
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/janpfeifer/goshot/ipc"
+	"github.com/janpfeifer/goshot/systray"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runCtl implements the `goshot ctl <command> [args...]` subcommand: it sends the command to
+// GoShot running in the system tray, prints its reply and exits.
+func runCtl(args []string) {
+	ctlFlags := flag.NewFlagSet("ctl", flag.ExitOnError)
+	// By default wait a bit longer than the longest command, "share", so its error is reported.
+	timeout := ctlFlags.Duration("timeout", systray.ShareTimeout+30*time.Second,
+		"Time to wait for the command to complete, e.g. sharing in Google Drive can take a while.")
+	ctlFlags.Usage = func() {
+		out := ctlFlags.Output()
+		_, _ = fmt.Fprintf(out, "Usage: %s ctl [flags] <command> [args...]\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(out, "Sends the command to GoShot running in the system tray (--systray). Commands:\n\n")
+		for _, name := range systray.CommandNames() {
+			cmd := systray.Commands[name]
+			_, _ = fmt.Fprintf(out, "  %s\n\t%s\n", cmd.Usage, cmd.Description)
+		}
+		_, _ = fmt.Fprintf(out, "\nFlags:\n")
+		ctlFlags.PrintDefaults()
+	}
+	_ = ctlFlags.Parse(args)
+	if ctlFlags.NArg() == 0 {
+		ctlFlags.Usage()
+		os.Exit(2)
+	}
+	command, cmdArgs := ctlFlags.Arg(0), ctlFlags.Args()[1:]
+	if command == "open" {
+		// The running GoShot may have a different working directory.
+		for ii, arg := range cmdArgs {
+			if abs, err := filepath.Abs(arg); err == nil {
+				cmdArgs[ii] = abs
+			}
+		}
+	}
+
+	ipc.Timeout = *timeout
+	msg, err := ipc.Send(command, cmdArgs...)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "goshot ctl: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(msg)
+}
@@ -122,3 +122,10 @@ func (c *Arrow) at(x, y int, under color.Color) color.Color {
 func (c *Arrow) Apply(image image.Image) image.Image {
 	return &filterImage{image, c.at}
 }
+
+// Transform moves the arrow along with the image, when it's rotated, flipped, resized, etc.
+// `mapPoint` maps the points of the image, and `scale` is the factor applied to the sizes.
+func (c *Arrow) Transform(mapPoint func(image.Point) image.Point, scale float64) {
+	c.Thickness *= scale
+	c.SetPoints(mapPoint(c.From), mapPoint(c.To))
+}
@@ -13,6 +13,9 @@ type Circle struct {
 	// Color of the circle to be drawn.
 	Color color.Color
 
+	// Fill color of the inside of the circle, drawn over the image. Nil or transparent for none.
+	Fill color.Color
+
 	// Thickness of the circle to be drawn.
 	Thickness float64
 
@@ -59,14 +62,46 @@ func (c *Circle) at(x, y int, under color.Color) color.Color {
 	iDy := (float64(y) - c.Center.Y()) / c.innerRadius.Y()
 	iDist := iDx*iDx + iDy*iDy
 
-	if oDist > 1 || iDist < 1 {
+	if oDist > 1 {
 		return under
 	}
+	if iDist < 1 {
+		if c.Fill == nil {
+			return under
+		}
+		return blendOver(under, c.Fill)
+	}
 
 	return c.Color
 }
 
+// blendOver returns the color `over` drawn over `under`, according to its alpha.
+func blendOver(under, over color.Color) color.Color {
+	const M = 1<<16 - 1
+	overR, overG, overB, overA := over.RGBA() // Alpha-premultiplied.
+	if overA == 0 {
+		return under
+	}
+	underR, underG, underB, underA := under.RGBA()
+	blend := func(underChan, overChan uint32) uint16 {
+		return uint16(overChan + underChan*(M-overA)/M)
+	}
+	return color.RGBA64{
+		R: blend(underR, overR),
+		G: blend(underG, overG),
+		B: blend(underB, overB),
+		A: blend(underA, overA),
+	}
+}
+
 // Apply implements the ImageFilter interface.
 func (c *Circle) Apply(image image.Image) image.Image {
 	return &filterImage{image, c.at}
 }
+
+// Transform moves the circle along with the image, when it's rotated, flipped, resized, etc.
+// `mapPoint` maps the points of the image, and `scale` is the factor applied to the sizes.
+func (c *Circle) Transform(mapPoint func(image.Point) image.Point, scale float64) {
+	c.Thickness *= scale
+	c.SetDim(image.Rectangle{Min: mapPoint(c.Dim.Min), Max: mapPoint(c.Dim.Max)}.Canon())
+}
@@ -0,0 +1,109 @@
+package filters
+
+import (
+	"github.com/go-gl/mathgl/mgl64"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// DimensionLine is a line between two points with ticks on its ends, labeled with its length in
+// pixels, as in technical drawings.
+type DimensionLine struct {
+	// From, To are the end points of the line.
+	From, To image.Point
+
+	// Color of the line and the label, and Background of the label.
+	Color, Background color.Color
+
+	// Thickness of the line, and font size of the label.
+	Thickness, FontSize float64
+
+	// Font of the label, one of FontNames, the default (the first one) if empty or unknown.
+	Font string
+
+	// Rectangle enclosing the line, its ticks and the label.
+	rect image.Rectangle
+
+	rebaseMatrix mgl64.Mat3
+	length       float64
+	label        *Text
+}
+
+// dimensionTickFactor is the length of the ticks on the ends, as a multiple of the thickness.
+const dimensionTickFactor = 8.0
+
+// NewDimensionLine creates a new DimensionLine filter, between the points `from` and `to`.
+func NewDimensionLine(from, to image.Point, color, background color.Color, thickness, fontSize float64, font string) *DimensionLine {
+	d := &DimensionLine{Color: color, Background: background, Thickness: thickness, FontSize: fontSize, Font: font}
+	d.SetPoints(from, to)
+	return d
+}
+
+// SetPoints sets the end points of the line, and updates the label with its length. It also
+// renders the label again with the current Color, Background, FontSize and Font.
+func (d *DimensionLine) SetPoints(from, to image.Point) {
+	if from == to {
+		to.X += 1 // So that the line is always at least 1 in size.
+	}
+	d.From, d.To = from, to
+	tickExtraPixels := int(dimensionTickFactor*d.Thickness/2 + d.Thickness + 0.99)
+	d.rect = image.Rectangle{Min: from, Max: to}.Canon().Inset(-tickExtraPixels)
+
+	// Matrix that rotates and translates a point to the coordinates of the line: the origin at
+	// From, and the X axis towards To.
+	delta := to.Sub(from)
+	d.length = math.Hypot(float64(delta.X), float64(delta.Y))
+	angle := math.Atan2(float64(delta.Y), float64(delta.X))
+	glog.V(2).Infof("DimensionLine.SetPoints(from=%v, to=%v): length=%.1f, angle=%5.1f",
+		from, to, d.length, mgl64.RadToDeg(angle))
+	d.rebaseMatrix = mgl64.HomogRotate2D(-angle).Mul3(
+		mgl64.Translate2D(float64(-from.X), float64(-from.Y)))
+
+	d.label = &Text{Center: from.Add(to).Div(2), Color: d.Color, Background: d.Background, Font: d.Font, Size: d.FontSize}
+	d.label.SetText(d.Label())
+	// The label may be wider than the line, e.g. when it's short or vertical.
+	d.rect = d.rect.Union(d.label.rect)
+}
+
+// Label returns the text of the label: the length of the line in pixels.
+func (d *DimensionLine) Label() string {
+	return FormatLength(d.length) + " px"
+}
+
+// FormatLength formats a length in pixels, with one decimal if it's not an integer.
+func FormatLength(length float64) string {
+	return strconv.FormatFloat(math.Round(length*10)/10, 'f', -1, 64)
+}
+
+// at is the function given to the filterImage object.
+func (d *DimensionLine) at(x, y int, under color.Color) color.Color {
+	if x > d.rect.Max.X || x < d.rect.Min.X || y > d.rect.Max.Y || y < d.rect.Min.Y {
+		return under
+	}
+	p := d.rebaseMatrix.Mul3x1(mgl64.Vec3{float64(x), float64(y), 1.0})
+	halfThickness := d.Thickness / 2
+	onLine := p.X() >= 0 && p.X() <= d.length && math.Abs(p.Y()) < halfThickness
+	onTick := (math.Abs(p.X()) < halfThickness || math.Abs(p.X()-d.length) < halfThickness) &&
+		math.Abs(p.Y()) < dimensionTickFactor*d.Thickness/2
+	if onLine || onTick {
+		under = d.Color
+	}
+	return d.label.at(x, y, under)
+}
+
+// Apply implements the ImageFilter interface.
+func (d *DimensionLine) Apply(image image.Image) image.Image {
+	return &filterImage{image, d.at}
+}
+
+// Transform moves the line along with the image, when it's rotated, flipped, resized, etc. The
+// label is updated with the new length. `mapPoint` maps the points of the image, and `scale` is
+// the factor applied to the sizes.
+func (d *DimensionLine) Transform(mapPoint func(image.Point) image.Point, scale float64) {
+	d.Thickness *= scale
+	d.FontSize *= scale
+	d.SetPoints(mapPoint(d.From), mapPoint(d.To))
+}
@@ -0,0 +1,57 @@
+package filters
+
+import (
+	"image"
+	"image/color"
+)
+
+// Image draws an image on top of the screenshot, e.g. the mouse cursor.
+type Image struct {
+	// Image to draw, with premultiplied alpha.
+	Image *image.RGBA
+
+	// Position of the top-left corner of the image.
+	Position image.Point
+}
+
+// NewImage creates a new Image filter, that draws `img` with its top-left corner at `position`.
+func NewImage(img *image.RGBA, position image.Point) *Image {
+	return &Image{Image: img, Position: position}
+}
+
+// at is the function given to the filterImage object.
+func (f *Image) at(x, y int, under color.Color) color.Color {
+	pos := image.Pt(x, y).Sub(f.Position).Add(f.Image.Rect.Min)
+	if !pos.In(f.Image.Rect) {
+		return under
+	}
+	i := f.Image.PixOffset(pos.X, pos.Y)
+	pix := f.Image.Pix[i : i+4]
+	a := uint32(pix[3])
+	if a == 0 {
+		return under
+	}
+	// Both colors have premultiplied alpha, in 8 and 16 bits.
+	underR, underG, underB, underA := under.RGBA()
+	blend := func(underChan uint32, pixChan uint8) uint8 {
+		return uint8((uint32(pixChan)*0x101 + underChan*(0xff-a)/0xff) >> 8)
+	}
+	return color.RGBA{
+		R: blend(underR, pix[0]),
+		G: blend(underG, pix[1]),
+		B: blend(underB, pix[2]),
+		A: uint8((a*0x101 + underA*(0xff-a)/0xff) >> 8),
+	}
+}
+
+// Apply implements the ImageFilter interface.
+func (f *Image) Apply(image image.Image) image.Image {
+	return &filterImage{image, f.at}
+}
+
+// Transform moves the image along with the screenshot, when it's rotated, flipped, resized,
+// etc. The image itself is not transformed: its center is moved to where it's mapped to.
+func (f *Image) Transform(mapPoint func(image.Point) image.Point, _ float64) {
+	half := f.Image.Rect.Size().Div(2)
+	f.Position = mapPoint(f.Position.Add(half)).Sub(half)
+}
@@ -5,6 +5,9 @@ import (
 	"github.com/golang/glog"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
 	"golang.org/x/image/math/fixed"
 	"image"
 	"image/color"
@@ -14,6 +17,17 @@ import (
 // DPI constant. Ideally it would be read from the various system.
 const DPI = 96
 
+// Fonts available for the text, by name. The first one is the default.
+var (
+	FontNames = []string{"Go Bold", "Go Regular", "Go Italic", "Go Mono"}
+	fontTTFs  = map[string][]byte{
+		"Go Bold":    gobold.TTF,
+		"Go Regular": goregular.TTF,
+		"Go Italic":  goitalic.TTF,
+		"Go Mono":    gomono.TTF,
+	}
+)
+
 type Text struct {
 	// Text to render.
 	Text string
@@ -24,6 +38,9 @@ type Text struct {
 	// Color of the Text to be drawn.
 	Color, Background color.Color
 
+	// Font is one of FontNames, the default (the first one) if empty or unknown.
+	Font string
+
 	// Font size.
 	Size float64
 
@@ -52,14 +69,18 @@ func NewText(text string, center image.Point, color, background color.Color, siz
 func (t *Text) SetText(text string) {
 	t.Text = text
 	point := fixed.Point26_6{X: 0, Y: fixed.Int26_6(t.Size * 64)}
-	goBoldFont, err := truetype.Parse(gobold.TTF)
+	ttf, found := fontTTFs[t.Font]
+	if !found {
+		ttf = fontTTFs[FontNames[0]]
+	}
+	parsedFont, err := truetype.Parse(ttf)
 	if err != nil {
-		glog.Fatalf("Failed to generate font for golang.org/x/image/font/gofont/gobold TTF.")
+		glog.Fatalf("Failed to generate font %q from golang.org/x/image/font/gofont TTF.", t.Font)
 	}
 	d := &font.Drawer{
 		Dst: t.renderedText,
 		Src: image.NewUniform(t.Color),
-		Face: truetype.NewFace(goBoldFont, &truetype.Options{
+		Face: truetype.NewFace(parsedFont, &truetype.Options{
 			Size:       t.Size,
 			DPI:        DPI,
 			Hinting:    font.HintingFull,
@@ -111,6 +132,12 @@ func (t *Text) SetText(text string) {
 	t.rect = image.Rect(cx-dx/2, cy-dy/2, cx+dx/2, cy+dy/2)
 }
 
+// SetFont sets the font, one of FontNames, and renders the text again.
+func (t *Text) SetFont(name string) {
+	t.Font = name
+	t.SetText(t.Text)
+}
+
 func normalizeAlpha(img *image.RGBA) {
 	var maxAlpha uint8
 	for ii := 0; ii < len(img.Pix); ii += 4 {
@@ -164,3 +191,12 @@ func (t *Text) at(x, y int, under color.Color) color.Color {
 func (t *Text) Apply(image image.Image) image.Image {
 	return &filterImage{image, t.at}
 }
+
+// Transform moves the text along with the image, when it's rotated, flipped, resized, etc.
+// The text itself is kept horizontal. `mapPoint` maps the points of the image, and `scale` is
+// the factor applied to the sizes.
+func (t *Text) Transform(mapPoint func(image.Point) image.Point, scale float64) {
+	t.Center = mapPoint(t.Center)
+	t.Size *= scale
+	t.SetText(t.Text)
+}
@@ -0,0 +1,185 @@
+// Package frame presents an image for release notes or slides: with rounded corners and a soft
+// drop shadow, over a padding filled with a color or a gradient.
+//
+// Styles are usually kept as named presets (see Preset), encoded as JSON.
+package frame
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Style of the frame around an image.
+type Style struct {
+	// CornerRadius of the image, in pixels, 0 for square corners.
+	CornerRadius int `json:",omitempty"`
+
+	// Padding added around the image, in pixels, filled with the background. The shadow is drawn
+	// over it, so it should be at least ShadowBlur plus the ShadowOffset.
+	Padding int `json:",omitempty"`
+
+	// ShadowOpacity of the drop shadow, from 0 (no shadow) to 1.
+	ShadowOpacity float64 `json:",omitempty"`
+
+	// ShadowBlur is the radius of the blur of the shadow, in pixels.
+	ShadowBlur int `json:",omitempty"`
+
+	// ShadowOffset moves the shadow, usually down.
+	ShadowOffset image.Point
+
+	// Background fills the padding, transparent if zero. If GradientTo is set, the padding is
+	// filled with a linear gradient from Background to GradientTo, in the direction given by
+	// GradientAngle: 0 for left to right, 90 for top to bottom.
+	Background    color.NRGBA
+	GradientTo    *color.NRGBA `json:",omitempty"`
+	GradientAngle float64      `json:",omitempty"`
+}
+
+// Preset is a named Style.
+type Preset struct {
+	Name string
+	Style
+}
+
+// DefaultPresets are offered if none were configured.
+var DefaultPresets = []Preset{
+	{Name: "Slides", Style: Style{
+		CornerRadius: 12, Padding: 64, ShadowOpacity: 0.5, ShadowBlur: 24, ShadowOffset: image.Pt(0, 8),
+		Background:    color.NRGBA{R: 0x43, G: 0x5e, B: 0xe8, A: 0xff},
+		GradientTo:    &color.NRGBA{R: 0xa8, G: 0x3f, B: 0xd6, A: 0xff},
+		GradientAngle: 45,
+	}},
+	{Name: "Release notes", Style: Style{
+		CornerRadius: 8, Padding: 32, ShadowOpacity: 0.35, ShadowBlur: 16, ShadowOffset: image.Pt(0, 4),
+		Background: color.NRGBA{R: 0xf4, G: 0xf5, B: 0xf7, A: 0xff},
+	}},
+	{Name: "Shadow only", Style: Style{
+		CornerRadius: 6, Padding: 24, ShadowOpacity: 0.5, ShadowBlur: 12, ShadowOffset: image.Pt(0, 4),
+	}},
+}
+
+// Apply returns the image framed with the style. The image is not changed.
+func (s Style) Apply(img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	padding := s.Padding
+	if padding < 0 {
+		padding = 0
+	}
+	framed := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+2*padding, bounds.Dy()+2*padding))
+	imageRect := image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy())
+	s.fillBackground(framed)
+
+	mask := roundedMask(imageRect, s.CornerRadius)
+	if s.ShadowOpacity > 0 {
+		shadow := image.NewAlpha(framed.Rect)
+		draw.Draw(shadow, imageRect.Add(s.ShadowOffset), mask, imageRect.Min, draw.Src)
+		opacity := math.Min(s.ShadowOpacity, 1)
+		for ii, a := range shadow.Pix {
+			shadow.Pix[ii] = uint8(float64(a)*opacity + 0.5)
+		}
+		Blur(shadow, s.ShadowBlur/2)
+		draw.DrawMask(framed, framed.Rect, image.Black, image.Point{}, shadow, image.Point{}, draw.Over)
+	}
+	draw.DrawMask(framed, imageRect, img, bounds.Min, mask, imageRect.Min, draw.Over)
+	return framed
+}
+
+// fillBackground fills the image with the background color or gradient.
+func (s Style) fillBackground(img *image.RGBA) {
+	if s.GradientTo == nil {
+		if s.Background.A > 0 {
+			draw.Draw(img, img.Rect, image.NewUniform(s.Background), image.Point{}, draw.Src)
+		}
+		return
+	}
+	from, to := s.Background, *s.GradientTo
+	angle := s.GradientAngle * math.Pi / 180
+	dirX, dirY := math.Cos(angle), math.Sin(angle)
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	length := math.Abs(w*dirX) + math.Abs(h*dirY) // Extent of the image in the direction.
+	lerp := func(a, b uint8, t float64) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5) }
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			// Position along the direction, from 0 to 1.
+			t := ((float64(x)+0.5-w/2)*dirX+(float64(y)+0.5-h/2)*dirY)/length + 0.5
+			t = math.Max(0, math.Min(1, t))
+			img.Set(x, y, color.NRGBA{
+				R: lerp(from.R, to.R, t),
+				G: lerp(from.G, to.G, t),
+				B: lerp(from.B, to.B, t),
+				A: lerp(from.A, to.A, t),
+			})
+		}
+	}
+}
+
+// roundedMask returns the mask of the rectangle with rounded corners, anti-aliased.
+func roundedMask(rect image.Rectangle, radius int) *image.Alpha {
+	mask := image.NewAlpha(rect)
+	draw.Draw(mask, rect, image.Opaque, image.Point{}, draw.Src)
+	if max := minInt(rect.Dx(), rect.Dy()) / 2; radius > max {
+		radius = max
+	}
+	if radius <= 0 {
+		return mask
+	}
+	r := float64(radius)
+	for dy := 0; dy < radius; dy++ {
+		for dx := 0; dx < radius; dx++ {
+			// Distance from the center of the pixel to the center of the corner arc.
+			dist := math.Hypot(r-float64(dx)-0.5, r-float64(dy)-0.5)
+			a := uint8(255 * math.Max(0, math.Min(1, r-dist+0.5)))
+			mask.SetAlpha(rect.Min.X+dx, rect.Min.Y+dy, color.Alpha{A: a})
+			mask.SetAlpha(rect.Max.X-1-dx, rect.Min.Y+dy, color.Alpha{A: a})
+			mask.SetAlpha(rect.Min.X+dx, rect.Max.Y-1-dy, color.Alpha{A: a})
+			mask.SetAlpha(rect.Max.X-1-dx, rect.Max.Y-1-dy, color.Alpha{A: a})
+		}
+	}
+	return mask
+}
+
+// Blur blurs the alpha image in place, with 3 passes of a box blur of the given radius in
+// each direction, which approximates a gaussian blur.
+func Blur(img *image.Alpha, radius int) {
+	if radius <= 0 {
+		return
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	tmp := make([]uint8, len(img.Pix))
+	for pass := 0; pass < 3; pass++ {
+		blurLines(img.Pix, tmp, h, w, img.Stride, 1, radius)
+		blurLines(tmp, img.Pix, w, h, 1, img.Stride, radius)
+	}
+}
+
+// blurLines applies a box blur to `numLines` lines of `length` pixels, from `src` into `dst`.
+// `lineStep` and `pixelStep` are the offsets between lines and between the pixels of a line.
+func blurLines(src, dst []uint8, numLines, length, lineStep, pixelStep, radius int) {
+	window := 2*radius + 1
+	for line := 0; line < numLines; line++ {
+		base := line * lineStep
+		at := func(ii int) int {
+			if ii < 0 || ii >= length {
+				return 0
+			}
+			return int(src[base+ii*pixelStep])
+		}
+		sum := 0
+		for ii := -radius; ii <= radius; ii++ {
+			sum += at(ii)
+		}
+		for ii := 0; ii < length; ii++ {
+			dst[base+ii*pixelStep] = uint8(sum / window)
+			sum += at(ii+radius+1) - at(ii-radius)
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,242 @@
+package frame
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testImage returns an opaque image with a pattern, not starting at (0, 0).
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(5, 7, 45, 37)) // 40 x 30 pixels.
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 5), G: uint8(y * 7), B: 0x80, A: 0xff})
+		}
+	}
+	return img
+}
+
+func TestApply(t *testing.T) {
+	img := testImage()
+	original := append([]uint8(nil), img.Pix...)
+	s := Style{CornerRadius: 6, Padding: 12, ShadowOpacity: 0.5, ShadowBlur: 4, ShadowOffset: image.Pt(0, 5)}
+	framed := s.Apply(img)
+	if string(img.Pix) != string(original) {
+		t.Errorf("Apply() changed the image")
+	}
+
+	wantBounds := image.Rect(0, 0, 40+2*12, 30+2*12)
+	if framed.Rect != wantBounds {
+		t.Fatalf("Apply() bounds = %v, wanted %v", framed.Rect, wantBounds)
+	}
+	imageRect := image.Rect(12, 12, 12+40, 12+30)
+
+	// The image pixels are unchanged inside the mask.
+	mask := roundedMask(imageRect, s.CornerRadius)
+	for y := imageRect.Min.Y; y < imageRect.Max.Y; y++ {
+		for x := imageRect.Min.X; x < imageRect.Max.X; x++ {
+			if mask.AlphaAt(x, y).A != 0xff {
+				continue
+			}
+			want := img.RGBAAt(x-imageRect.Min.X+img.Rect.Min.X, y-imageRect.Min.Y+img.Rect.Min.Y)
+			if got := framed.RGBAAt(x, y); got != want {
+				t.Fatalf("Pixel (%d, %d) = %v, wanted the image pixel %v", x, y, got, want)
+			}
+		}
+	}
+
+	// The shadow shows under the image, moved by ShadowOffset, and not far from it.
+	below := framed.RGBAAt((imageRect.Min.X+imageRect.Max.X)/2, imageRect.Max.Y+2)
+	if below.A == 0 || below.R != 0 || below.G != 0 || below.B != 0 {
+		t.Errorf("Pixel below the image = %v, wanted the black shadow", below)
+	}
+	if above := framed.RGBAAt((imageRect.Min.X+imageRect.Max.X)/2, imageRect.Min.Y-2); above.A >= below.A {
+		t.Errorf("Shadow above the image = %v, wanted less than below it %v: the shadow is moved down", above, below)
+	}
+	if corner := framed.RGBAAt(0, framed.Rect.Max.Y-1); corner.A != 0 {
+		t.Errorf("Pixel at the bottom-left corner = %v, wanted transparent, away from the shadow", corner)
+	}
+	if below.A > uint8(0.5*0xff+0.5) {
+		t.Errorf("Shadow alpha %d, higher than the ShadowOpacity", below.A)
+	}
+
+	// Without shadow nor background, the rounded corners are transparent.
+	s.ShadowOpacity = 0
+	framed = s.Apply(img)
+	if c := framed.RGBAAt(imageRect.Min.X, imageRect.Min.Y); c.A != 0 {
+		t.Errorf("Corner of the image = %v, wanted transparent with CornerRadius %d", c, s.CornerRadius)
+	}
+	s.CornerRadius = 0
+	framed = s.Apply(img)
+	if c, want := framed.RGBAAt(imageRect.Min.X, imageRect.Min.Y), img.RGBAAt(img.Rect.Min.X, img.Rect.Min.Y); c != want {
+		t.Errorf("Corner of the image = %v, wanted the image pixel %v with square corners", c, want)
+	}
+
+	// Negative padding is ignored.
+	s.Padding = -3
+	if framed := s.Apply(img); framed.Rect != image.Rect(0, 0, 40, 30) {
+		t.Errorf("Apply() with negative padding: bounds %v, wanted the image size", framed.Rect)
+	}
+}
+
+func TestRoundedMask(t *testing.T) {
+	rect := image.Rect(3, 4, 23, 14) // 20 x 10 pixels.
+	mask := roundedMask(rect, 4)
+	if mask.Rect != rect {
+		t.Fatalf("roundedMask() bounds %v, wanted %v", mask.Rect, rect)
+	}
+	corners := []image.Point{rect.Min, {X: rect.Max.X - 1, Y: rect.Min.Y}, {X: rect.Min.X, Y: rect.Max.Y - 1}, rect.Max.Sub(image.Pt(1, 1))}
+	for _, p := range corners {
+		if a := mask.AlphaAt(p.X, p.Y).A; a != 0 {
+			t.Errorf("Corner %v alpha = %d, wanted 0", p, a)
+		}
+	}
+	// Anti-aliased: the edge of the arc is partially transparent, the edges are opaque.
+	if a := mask.AlphaAt(rect.Min.X+1, rect.Min.Y+1).A; a == 0 || a == 0xff {
+		t.Errorf("Pixel on the arc alpha = %d, wanted partially transparent", a)
+	}
+	for _, p := range []image.Point{{X: rect.Min.X + 10, Y: rect.Min.Y}, {X: rect.Min.X, Y: rect.Min.Y + 5}, {X: rect.Min.X + 10, Y: rect.Min.Y + 5}} {
+		if a := mask.AlphaAt(p.X, p.Y).A; a != 0xff {
+			t.Errorf("Pixel %v alpha = %d, wanted opaque", p, a)
+		}
+	}
+	// Symmetric corners.
+	for dy := 0; dy < 4; dy++ {
+		for dx := 0; dx < 4; dx++ {
+			a := mask.AlphaAt(rect.Min.X+dx, rect.Min.Y+dy).A
+			for _, b := range []uint8{
+				mask.AlphaAt(rect.Max.X-1-dx, rect.Min.Y+dy).A,
+				mask.AlphaAt(rect.Min.X+dx, rect.Max.Y-1-dy).A,
+				mask.AlphaAt(rect.Max.X-1-dx, rect.Max.Y-1-dy).A,
+			} {
+				if a != b {
+					t.Errorf("Corners differ at offset (%d, %d): %d and %d", dx, dy, a, b)
+				}
+			}
+		}
+	}
+
+	// The radius is limited to half the smaller side: the middle of the short sides is opaque.
+	mask = roundedMask(rect, 100)
+	if a := mask.AlphaAt(rect.Min.X+10, rect.Min.Y+5).A; a != 0xff {
+		t.Errorf("Center alpha with a large radius = %d, wanted opaque", a)
+	}
+	if a := mask.AlphaAt(rect.Min.X, rect.Min.Y+5).A; a == 0 {
+		t.Errorf("Middle of the left side with a large radius is transparent")
+	}
+
+	// Square corners.
+	mask = roundedMask(rect, 0)
+	for _, a := range mask.Pix {
+		if a != 0xff {
+			t.Fatalf("roundedMask() with radius 0 has alpha %d, wanted all opaque", a)
+		}
+	}
+}
+
+func TestBlur(t *testing.T) {
+	const size, radius = 41, 3
+	img := image.NewAlpha(image.Rect(0, 0, size, size))
+	center := size / 2
+	img.SetAlpha(center, center, color.Alpha{A: 0xff})
+	img.SetAlpha(center+1, center, color.Alpha{A: 0xff})
+	img.SetAlpha(center, center+1, color.Alpha{A: 0xff})
+	img.SetAlpha(center+1, center+1, color.Alpha{A: 0xff})
+
+	total := func() (sum int) {
+		for _, a := range img.Pix {
+			sum += int(a)
+		}
+		return sum
+	}
+	before := total()
+	unchanged := append([]uint8(nil), img.Pix...)
+	Blur(img, 0)
+	if string(img.Pix) != string(unchanged) {
+		t.Errorf("Blur() with radius 0 changed the image")
+	}
+
+	Blur(img, radius)
+	// The blur spreads the alpha, losing some with the rounding down of each of its 6 passes,
+	// which for such a small spot is a good part of it.
+	if after := total(); after > before || after < before/2 {
+		t.Errorf("Total alpha %d after the blur, wanted close to %d", after, before)
+	}
+	peak := img.AlphaAt(center, center).A
+	if peak == 0 || peak == 0xff {
+		t.Errorf("Blurred peak alpha = %d, wanted spread out", peak)
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			a := img.AlphaAt(x, y).A
+			if a > peak {
+				t.Errorf("Pixel (%d, %d) alpha %d is higher than the peak %d", x, y, a, peak)
+			}
+			// 3 passes of the box blur spread it at most 3 radius in each direction.
+			if (x < center-3*radius || x > center+1+3*radius || y < center-3*radius || y > center+1+3*radius) && a != 0 {
+				t.Errorf("Pixel (%d, %d) alpha %d, wanted 0 beyond the reach of the blur", x, y, a)
+			}
+			// Symmetric around the center of the 2x2 square.
+			if b := img.AlphaAt(2*center+1-x, y).A; x <= 2*center+1 && a != b {
+				t.Errorf("Pixels (%d, %d) and (%d, %d) differ: %d and %d", x, y, 2*center+1-x, y, a, b)
+			}
+		}
+	}
+	if a := img.AlphaAt(center+radius, center).A; a == 0 || a >= peak {
+		t.Errorf("Pixel at radius from the center alpha %d, wanted less than the peak %d and non-zero", a, peak)
+	}
+}
+
+// near returns whether the colors differ by at most 1 in each channel, as rounding does.
+func near(a, b color.RGBA) bool {
+	for _, d := range []int{int(a.R) - int(b.R), int(a.G) - int(b.G), int(a.B) - int(b.B), int(a.A) - int(b.A)} {
+		if d < -1 || d > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGradient(t *testing.T) {
+	from, to := color.NRGBA{R: 0xff, A: 0xff}, color.NRGBA{B: 0xff, A: 0xff}
+	img := image.NewRGBA(image.Rect(0, 0, 101, 51))
+	s := Style{Background: from, GradientTo: &to}
+	s.fillBackground(img)
+	left, right := img.RGBAAt(0, 25), img.RGBAAt(100, 25)
+	if left.R < 0xf0 || left.B > 0x10 || right.B < 0xf0 || right.R > 0x10 {
+		t.Errorf("Gradient left to right: left %v, right %v, wanted close to %v and %v", left, right, from, to)
+	}
+	if middle := img.RGBAAt(50, 25); middle.R != middle.B || middle.R < 0x78 || middle.R > 0x88 {
+		t.Errorf("Gradient middle = %v, wanted half way", middle)
+	}
+	for x := 1; x < 101; x++ {
+		if img.RGBAAt(x, 10).B < img.RGBAAt(x-1, 10).B {
+			t.Fatalf("Gradient not monotonic at x=%d", x)
+		}
+		if a, b := img.RGBAAt(x, 0), img.RGBAAt(x, 50); !near(a, b) {
+			t.Fatalf("Gradient at angle 0 changes along column %d: %v and %v", x, a, b)
+		}
+	}
+
+	s.GradientAngle = 90
+	s.fillBackground(img)
+	top, bottom := img.RGBAAt(50, 0), img.RGBAAt(50, 50)
+	if top.R < 0xf0 || bottom.B < 0xf0 {
+		t.Errorf("Gradient top to bottom: top %v, bottom %v, wanted close to %v and %v", top, bottom, from, to)
+	}
+	if a, b := img.RGBAAt(0, 20), img.RGBAAt(100, 20); !near(a, b) {
+		t.Errorf("Gradient at angle 90 changes along row 20: %v and %v", a, b)
+	}
+
+	// Without GradientTo, the background is uniform.
+	s.GradientTo = nil
+	s.fillBackground(img)
+	for y := 0; y < 51; y++ {
+		for x := 0; x < 101; x++ {
+			if c := img.RGBAAt(x, y); c != (color.RGBA{R: 0xff, A: 0xff}) {
+				t.Fatalf("Background pixel (%d, %d) = %v, wanted %v", x, y, c, from)
+			}
+		}
+	}
+}
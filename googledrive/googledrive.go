@@ -4,15 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/golang/glog"
 	"google.golang.org/api/googleapi"
 	"image"
 	"image/png"
+	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"os/exec"
 	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -44,7 +50,8 @@ type Manager struct {
 // New creates a new Google Drive "Manager", it's the object that manages authentication, authorization
 // tokens, configs and communication with GoogleDrive.
 //
-// All files are create within the fixed given `path` (list of strings).
+// By default files are created within the given `path` (list of strings), this can be
+// overridden for each file with ShareOptions.
 //
 // A previously saved authorization `token` can be passed to reuse authorization. If none are available simply pass
 // an empty string here, and a new authorization will be requested.
@@ -122,7 +129,7 @@ func (m *Manager) getClient(ctx context.Context) (*http.Client, error) {
 func (m *Manager) getTokenFromWeb() (*oauth2.Token, error) {
 	// Scope of authorization is given in the config object.
 	authURL := m.config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	if err := openurl(authURL); err != nil {
+	if err := OpenURL(authURL); err != nil {
 		return nil, err
 	}
 	authCode := m.EnterAuthorization()
@@ -137,70 +144,287 @@ func (m *Manager) getTokenFromWeb() (*oauth2.Token, error) {
 	return tok, nil
 }
 
-// ShareImage will create
-func (m *Manager) ShareImage(ctx context.Context, name string, img image.Image) (url string, err error) {
-	parentId, err := m.createPath(ctx)
+// Permission defines who is given access to a shared image.
+type Permission int
+
+const (
+	// PermissionPrivate doesn't share the image: only the owner can see it. It's the zero value,
+	// so nothing is made public unless asked for.
+	PermissionPrivate Permission = iota
+
+	// PermissionUsers makes the image readable only by the users (email addresses) listed in ShareOptions.Users.
+	PermissionUsers
+
+	// PermissionDomain makes the image readable by anyone in the domain given in ShareOptions.Domain.
+	PermissionDomain
+
+	// PermissionAnyone makes the image readable by anyone with the link.
+	PermissionAnyone
+)
+
+// PermissionNames are the user readable names of the Permission values, in order.
+var PermissionNames = []string{"Private (no sharing)", "Specific users", "Domain", "Anyone with the link"}
+
+// LinkType defines the type of URL returned by ShareImage.
+type LinkType int
+
+const (
+	// WebViewLink is the link to the Google Drive page that displays the image.
+	WebViewLink LinkType = iota
+
+	// DirectLink is the link to the image content itself, it can be used
+	// for instance in `<img src="...">` tags.
+	DirectLink
+)
+
+// LinkTypeNames are the user readable names of the LinkType values, in order.
+var LinkTypeNames = []string{"Google Drive page", "Direct image link"}
+
+// ShareOptions configure where an image is stored, and with whom it is shared.
+// The zero value stores the image in the Manager's default path, and doesn't share it: only
+// the owner can see it.
+type ShareOptions struct {
+	// Path of folders where to store the image. If empty, the Manager's default path is used.
+	Path []string
+
+	// SharedDriveID is the ID of a Shared Drive where to store the image. If empty, the image
+	// is stored in the user's "My Drive".
+	SharedDriveID string
+
+	// Permission defines who can read the image.
+	Permission Permission
+
+	// Domain to share the image with, used only if Permission == PermissionDomain.
+	Domain string
+
+	// Users are the emails of users to share the image with, used only if Permission == PermissionUsers.
+	Users []string
+
+	// Link defines what kind of link is returned.
+	Link LinkType
+
+	// Progress, if not nil, is called during the upload with the number of bytes already sent
+	// and the total number of bytes to send.
+	Progress func(sent, total int64)
+}
+
+// UploadChunkSize is the size of the chunks used in resumable uploads. Images larger than that
+// are uploaded in parts, and each part is retried if it fails. It must be a multiple of 256KiB.
+const UploadChunkSize = 256 * 1024
+
+// ShareImage stores the image as a PNG file with the given name (".png" is appended) in Google Drive,
+// shares it according to `opts` and returns the URL to it, and the id of the file created. If the
+// file can't be shared or its link retrieved, it is deleted and only the error is returned.
+//
+// The upload is resumable: it is sent in chunks of UploadChunkSize, and transient errors are retried
+// with an exponential backoff. It can be interrupted by cancelling `ctx`.
+func (m *Manager) ShareImage(ctx context.Context, name string, img image.Image, opts ShareOptions) (url, fileID string, err error) {
+	parentId, err := m.createPath(ctx, opts)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// Create PNG content of the image.
 	var contentBuffer bytes.Buffer
 	_ = png.Encode(&contentBuffer, img)
 	content := contentBuffer.Bytes()
+	total := int64(len(content))
 
-	f := &drive.File{
-		MimeType: "image/png",
-		Name:     name + ".png",
-		Parents:  []string{parentId},
-	}
-	f, err = m.service.Files.Create(f).
-		Context(ctx).
-		Media(bytes.NewReader(content)).
-		Do()
+	// Each chunk is retried within the upload session by the Drive library. If the whole upload
+	// fails nonetheless, the server may have already committed the file: it is tagged with a
+	// unique uploadID, so a retry reuses it instead of creating a duplicate.
+	uploadID := fmt.Sprintf("%x-%x", time.Now().UnixNano(), rand.Int63())
+	var f *drive.File
+	attempt := 0
+	err = withRetry(ctx, "upload image", func() (err error) {
+		attempt++
+		if attempt > 1 {
+			f, err = m.findUpload(ctx, parentId, uploadID)
+			if err != nil || f != nil {
+				return err
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(0, total)
+		}
+		f, err = m.service.Files.Create(&drive.File{
+			MimeType:      "image/png",
+			Name:          name + ".png",
+			Parents:       []string{parentId},
+			AppProperties: map[string]string{uploadIDProperty: uploadID},
+		}).
+			Context(ctx).
+			SupportsAllDrives(true).
+			Media(bytes.NewReader(content), googleapi.ChunkSize(UploadChunkSize)).
+			ProgressUpdater(func(current, _ int64) {
+				if opts.Progress != nil {
+					opts.Progress(current, total)
+				}
+			}).
+			Do()
+		return
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return "", "", fmt.Errorf("failed to create file: %w", err)
+	}
+	if opts.Progress != nil {
+		opts.Progress(total, total)
 	}
 	glog.V(2).Infof("Returned file: %+v", f)
 
-	// Make uploaded image visible (but not writeable) to all.
-	_, err = m.service.Permissions.Create(f.Id, &drive.Permission{
-		AllowFileDiscovery: false,
-		Role:               "reader",
-		Type:               "anyone",
-		View:               "",
-		ServerResponse:     googleapi.ServerResponse{},
-		ForceSendFields:    nil,
-		NullFields:         nil,
-	}).Context(ctx).Do()
-	if err != nil {
-		return "", fmt.Errorf("failed to create shared read permissions for file name=%q id=%q: %w",
-			f.Name, f.Id, err)
+	// If sharing fails after the upload, the file is deleted: otherwise it would be left in
+	// Google Drive without the caller knowing about it.
+	defer func() {
+		if err != nil {
+			m.deleteFailedUpload(f)
+			url, fileID = "", ""
+		}
+	}()
+
+	// Make uploaded image visible (but not writeable) according to the options.
+	if err = m.setPermissions(ctx, f, opts); err != nil {
+		return "", f.Id, err
 	}
 
 	// Get link to image.
-	f2, err := m.service.Files.Get(f.Id).Fields("webViewLink").Do()
+	if opts.Link == DirectLink {
+		url = fmt.Sprintf("https://drive.google.com/uc?export=view&id=%s", f.Id)
+		glog.V(2).Infof("- DirectLink=%s", url)
+		return url, f.Id, nil
+	}
+	var f2 *drive.File
+	err = withRetry(ctx, "get link", func() (err error) {
+		f2, err = m.service.Files.Get(f.Id).Context(ctx).SupportsAllDrives(true).Fields("webViewLink").Do()
+		return
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to get shared link to file name=%q id=%q: %w",
+		return "", f.Id, fmt.Errorf("failed to get shared link to file name=%q id=%q: %w",
 			f.Name, f.Id, err)
 	}
 	glog.V(2).Infof("- WebLinkView=%s", f2.WebViewLink)
-	return f2.WebViewLink, nil
+	return f2.WebViewLink, f.Id, nil
+}
+
+// uploadIDProperty is the application property of the files uploaded by ShareImage that holds the
+// unique id of the upload. It is used to find out whether a failed upload was committed anyway.
+const uploadIDProperty = "goshotUploadID"
+
+// findUpload returns the file in the folder `parentID` created by the upload `uploadID`, or nil if
+// there is none.
+func (m *Manager) findUpload(ctx context.Context, parentID, uploadID string) (*drive.File, error) {
+	fileList, err := m.service.Files.List().
+		Context(ctx).
+		Q(fmt.Sprintf("'%s' in parents and trashed=false and appProperties has { key='%s' and value='%s' }",
+			parentID, uploadIDProperty, escapeQuery(uploadID))).
+		IncludeItemsFromAllDrives(true).SupportsAllDrives(true).
+		Fields("files(id, name, mimeType)").
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for the file of a previous upload attempt: %w", err)
+	}
+	if len(fileList.Files) == 0 {
+		return nil, nil
+	}
+	glog.V(1).Infof("googledrive: upload %s was committed before failing, reusing file id=%q", uploadID, fileList.Files[0].Id)
+	return fileList.Files[0], nil
+}
+
+// deleteFailedUploadTimeout is the time given to delete a file whose sharing failed.
+const deleteFailedUploadTimeout = 30 * time.Second
+
+// deleteFailedUpload deletes the file uploaded by ShareImage, when sharing it failed afterwards.
+// It uses its own context, since the failure may have been the cancellation of the upload's context.
+func (m *Manager) deleteFailedUpload(f *drive.File) {
+	ctx, cancel := context.WithTimeout(context.Background(), deleteFailedUploadTimeout)
+	defer cancel()
+	if err := m.DeleteFile(ctx, f.Id); err != nil {
+		glog.Errorf("googledrive: failed to delete file name=%q id=%q after sharing it failed: %v", f.Name, f.Id, err)
+		return
+	}
+	glog.V(1).Infof("googledrive: deleted file name=%q id=%q after sharing it failed", f.Name, f.Id)
+}
+
+// DeleteFile permanently deletes the file with the given id, e.g. an image previously shared
+// with ShareImage.
+func (m *Manager) DeleteFile(ctx context.Context, fileID string) error {
+	err := withRetry(ctx, "delete file", func() error {
+		return m.service.Files.Delete(fileID).Context(ctx).SupportsAllDrives(true).Do()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file id=%q: %w", fileID, err)
+	}
+	return nil
+}
+
+// setPermissions creates the read permissions on the file `f`, as configured in `opts`.
+func (m *Manager) setPermissions(ctx context.Context, f *drive.File, opts ShareOptions) error {
+	var permissions []*drive.Permission
+	switch opts.Permission {
+	case PermissionAnyone:
+		permissions = append(permissions, &drive.Permission{Role: "reader", Type: "anyone"})
+	case PermissionDomain:
+		if opts.Domain == "" {
+			return fmt.Errorf("sharing with a domain requires a domain name")
+		}
+		permissions = append(permissions, &drive.Permission{Role: "reader", Type: "domain", Domain: opts.Domain})
+	case PermissionUsers:
+		if len(opts.Users) == 0 {
+			return fmt.Errorf("sharing with specific users requires at least one email address")
+		}
+		for _, user := range opts.Users {
+			permissions = append(permissions, &drive.Permission{Role: "reader", Type: "user", EmailAddress: user})
+		}
+	case PermissionPrivate:
+		// Nothing to share.
+	default:
+		return fmt.Errorf("unknown permission type %d", opts.Permission)
+	}
+
+	for _, permission := range permissions {
+		call := m.service.Permissions.Create(f.Id, permission).Context(ctx).SupportsAllDrives(true)
+		if permission.Type == "user" {
+			call = call.SendNotificationEmail(false)
+		}
+		err := withRetry(ctx, "set permissions", func() error {
+			_, err := call.Do()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create shared read permissions (%s) for file name=%q id=%q: %w",
+				permission.Type, f.Name, f.Id, err)
+		}
+	}
+	return nil
 }
 
 const folderMimeType = "application/vnd.google-apps.folder"
 
-// createPath creates the path for the manager, if it doesn't yet exist.
-func (m *Manager) createPath(ctx context.Context) (id string, err error) {
+// createPath creates the path configured in `opts` (or the manager's default path), if it doesn't yet exist.
+func (m *Manager) createPath(ctx context.Context, opts ShareOptions) (id string, err error) {
 	var parents []string
-	subPath := m.path
+	subPath := opts.Path
+	if len(subPath) == 0 {
+		subPath = m.path
+	}
 
 	id = "root"
+	if opts.SharedDriveID != "" {
+		id = opts.SharedDriveID
+	}
 	for len(subPath) > 0 {
-		fileList, err := m.service.Files.List().
+		list := m.service.Files.List().
+			Context(ctx).
 			Q(fmt.Sprintf("mimeType = '%s' and trashed=false and '%s' in parents and name='%s'",
-				folderMimeType, id, subPath[0])).
-			Do()
+				folderMimeType, id, escapeQuery(subPath[0])))
+		if opts.SharedDriveID != "" {
+			list = list.Corpora("drive").DriveId(opts.SharedDriveID).
+				IncludeItemsFromAllDrives(true).SupportsAllDrives(true)
+		}
+		var fileList *drive.FileList
+		err = withRetry(ctx, "list folder", func() (err error) {
+			fileList, err = list.Do()
+			return
+		})
 		if err != nil {
 			err = fmt.Errorf("failed to find subdirectory %q in %v: %w", subPath[0], parents, err)
 			glog.Errorf("googledrive.Manager.createPath: %v", err)
@@ -212,11 +436,15 @@ func (m *Manager) createPath(ctx context.Context) (id string, err error) {
 				Name:     subPath[0],
 				Parents:  []string{id},
 			}
-			f, err = m.service.Files.Create(f).
-				Context(ctx).
-				Do()
+			err = withRetry(ctx, "create folder", func() (err error) {
+				f, err = m.service.Files.Create(f).
+					Context(ctx).
+					SupportsAllDrives(true).
+					Do()
+				return
+			})
 			if err != nil {
-				glog.Warningf("Failed to create sub-folder %q in %v: %v", subPath[0], parents, err)
+				return "", fmt.Errorf("failed to create sub-folder %q in %v: %w", subPath[0], parents, err)
 			}
 			id = f.Id
 		} else {
@@ -230,7 +458,73 @@ func (m *Manager) createPath(ctx context.Context) (id string, err error) {
 	return
 }
 
-func openurl(url string) error {
+// escapeQuery escapes a value to be used within single quotes in a Google Drive query.
+func escapeQuery(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, `'`, `\'`)
+}
+
+// Parameters of the exponential backoff used when retrying transient errors.
+const (
+	maxRetries     = 5
+	initialBackoff = time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// withRetry calls `fn` until it succeeds, returns a non-transient error, `ctx` is cancelled
+// or `maxRetries` is reached. Between attempts it waits for an exponentially increasing
+// (and jittered) time.
+func withRetry(ctx context.Context, what string, fn func() error) error {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= maxRetries || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		glog.Warningf("googledrive: %s failed (attempt %d of %d), retrying in %s: %v",
+			what, attempt+1, maxRetries+1, wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// isTransient returns whether the error is likely to go away if the request is retried.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		case http.StatusForbidden:
+			for _, item := range apiErr.Errors {
+				if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// OpenURL opens the given URL in the user's default browser.
+func OpenURL(url string) error {
 	switch runtime.GOOS {
 	case "linux":
 		return exec.Command("xdg-open", url).Start()
@@ -0,0 +1,158 @@
+package history
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Capture is one screenshot taken by GoShot, saved to be reopened later.
+type Capture struct {
+	// Time when the screenshot was taken.
+	Time time.Time
+
+	// Path to the PNG file with the screenshot.
+	Path string
+
+	// Width and Height of the screenshot.
+	Width, Height int
+}
+
+// MaxCaptures is the maximum number of screenshots kept: older ones are dropped, along with
+// their files. If 0, the default, screenshots are not kept: since they often hold sensitive
+// information, keeping them is left for the user to enable.
+//
+// The screenshots are saved as PNG files in the "captures" folder of Dir.
+var MaxCaptures = 0
+
+const (
+	capturesIndexFileName = "captures.json"
+	capturesDir           = "captures"
+)
+
+// CapturesIndexPath returns the path to the index file of the screenshots taken, it can be
+// used to check for changes.
+func CapturesIndexPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, capturesIndexFileName), nil
+}
+
+// LoadCaptures returns the screenshots kept, most recent first.
+func LoadCaptures() (captures []Capture, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	err = loadIndex(capturesIndexFileName, &captures)
+	return
+}
+
+// AddCapture saves the screenshot taken at the given time, and includes it in the history.
+// Screenshots beyond MaxCaptures are dropped.
+func AddCapture(img image.Image, t time.Time) (capture Capture, err error) {
+	if MaxCaptures <= 0 {
+		return
+	}
+	dir, err := Dir()
+	if err != nil {
+		return
+	}
+	dir = filepath.Join(dir, capturesDir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return capture, fmt.Errorf("failed to create captures directory %q: %w", dir, err)
+	}
+	bounds := img.Bounds()
+	capture = Capture{
+		Time:   t,
+		Path:   filepath.Join(dir, t.Format("2006-01-02_15-04-05.000")+".png"),
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	}
+	f, err := os.Create(capture.Path)
+	if err != nil {
+		return capture, fmt.Errorf("failed to create %q: %w", capture.Path, err)
+	}
+	err = png.Encode(f, img)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(capture.Path)
+		return capture, fmt.Errorf("failed to write %q: %w", capture.Path, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var captures []Capture
+	if err := loadIndex(capturesIndexFileName, &captures); err != nil {
+		glog.Errorf("Discarding broken captures history: %v", err)
+	}
+	captures = append([]Capture{capture}, captures...)
+	return capture, saveIndex(capturesIndexFileName, trimCaptures(captures))
+}
+
+// PruneCaptures drops the screenshots beyond MaxCaptures, along with their files: all of them
+// if it is 0. It is called when MaxCaptures is changed.
+func PruneCaptures() error {
+	mu.Lock()
+	defer mu.Unlock()
+	var captures []Capture
+	if err := loadIndex(capturesIndexFileName, &captures); err != nil {
+		return err
+	}
+	if len(captures) <= MaxCaptures {
+		return nil
+	}
+	return saveIndex(capturesIndexFileName, trimCaptures(captures))
+}
+
+// trimCaptures removes the files of the screenshots beyond MaxCaptures, and returns the ones kept.
+// It must be called with mu locked.
+func trimCaptures(captures []Capture) []Capture {
+	for len(captures) > MaxCaptures && len(captures) > 0 {
+		last := captures[len(captures)-1]
+		if err := os.Remove(last.Path); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("Failed to remove old screenshot %q: %v", last.Path, err)
+		}
+		captures = captures[:len(captures)-1]
+	}
+	return captures
+}
+
+const autoSavedIndexFileName = "autosaved.json"
+
+// AddAutoSaved records a screenshot automatically saved by GoShot, in a folder chosen by the user.
+// If a screenshot with the same path was already recorded, it is replaced.
+//
+// Only the files recorded here are subject to the retention rules: automatically saved files
+// beyond the `keep` most recent ones, or older than `maxAge`, are deleted. Zero values disable
+// the corresponding rule.
+func AddAutoSaved(capture Capture, keep int, maxAge time.Duration) error {
+	mu.Lock()
+	defer mu.Unlock()
+	var saved []Capture
+	if err := loadIndex(autoSavedIndexFileName, &saved); err != nil {
+		glog.Errorf("Discarding broken automatically saved screenshots history: %v", err)
+	}
+	kept := []Capture{capture}
+	for _, c := range saved {
+		if c.Path == capture.Path {
+			continue
+		}
+		expired := (keep > 0 && len(kept) >= keep) || (maxAge > 0 && time.Since(c.Time) > maxAge)
+		if expired {
+			glog.V(1).Infof("Removing automatically saved screenshot %q", c.Path)
+			if err := os.Remove(c.Path); err != nil && !os.IsNotExist(err) {
+				glog.Warningf("Failed to remove old screenshot %q: %v", c.Path, err)
+			}
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return saveIndex(autoSavedIndexFileName, kept)
+}
@@ -0,0 +1,248 @@
+// Package history keeps a persistent local history of the images shared by GoShot, and of
+// the screenshots taken (see Capture).
+//
+// The history is stored in the user's configuration directory (see Dir), in JSON
+// index files along with thumbnails of the shared images. It can be read and updated
+// from different GoShot processes (e.g. the system tray and the edit windows).
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"golang.org/x/image/draw"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Backends of the shared images.
+const (
+	GoogleDrive = "GoogleDrive"
+)
+
+// Entry is one image shared by GoShot.
+type Entry struct {
+	// Time when the image was shared.
+	Time time.Time
+
+	// Backend where the image was shared, e.g. GoogleDrive.
+	Backend string
+
+	// RemoteID is the id of the file in the backend, used to delete it.
+	RemoteID string
+
+	// Name of the shared file.
+	Name string
+
+	// URL where the image was shared.
+	URL string
+
+	// Thumbnail is the path to a small PNG version of the shared image. May be empty.
+	Thumbnail string
+}
+
+var (
+	// MaxEntries is the maximum number of entries kept in the history: older entries
+	// are dropped.
+	MaxEntries = 100
+
+	// ThumbnailSize is the maximum width and height of the thumbnails.
+	ThumbnailSize = 128
+)
+
+const (
+	appDir        = "GoShot"
+	indexFileName = "shared.json"
+	thumbnailsDir = "thumbnails"
+)
+
+// mu serializes changes to the index file within the process.
+var mu sync.Mutex
+
+// Dir returns the directory where the history is stored. It is created if it doesn't exist.
+func Dir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user configuration directory: %w", err)
+	}
+	dir := filepath.Join(configDir, appDir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create history directory %q: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// IndexPath returns the path to the index file, it can be used to check for changes in the history.
+func IndexPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, indexFileName), nil
+}
+
+// Load returns the entries in the history, most recent first.
+func Load() ([]Entry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return load()
+}
+
+func load() (entries []Entry, err error) {
+	err = loadIndex(indexFileName, &entries)
+	return
+}
+
+func save(entries []Entry) error {
+	return saveIndex(indexFileName, entries)
+}
+
+// loadIndex reads the JSON index file in Dir into `entries`. A missing file is not an error.
+func loadIndex(fileName string, entries interface{}) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	indexPath := filepath.Join(dir, fileName)
+	content, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read history from %q: %w", indexPath, err)
+	}
+	if err = json.Unmarshal(content, entries); err != nil {
+		return fmt.Errorf("failed to parse history in %q: %w", indexPath, err)
+	}
+	return nil
+}
+
+// saveIndex writes `entries` to the JSON index file in Dir.
+func saveIndex(fileName string, entries interface{}) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	indexPath := filepath.Join(dir, fileName)
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+
+	// Write to a temporary file and rename it, so readers never see a partially written index.
+	tmpPath := indexPath + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, content, 0600); err != nil {
+		return fmt.Errorf("failed to write history to %q: %w", tmpPath, err)
+	}
+	if err = os.Rename(tmpPath, indexPath); err != nil {
+		return fmt.Errorf("failed to move history to %q: %w", indexPath, err)
+	}
+	return nil
+}
+
+// Add includes the entry in the history. If `img` is not nil, a thumbnail of it is
+// saved and the entry's Thumbnail field is set accordingly.
+// Entries beyond MaxEntries are dropped, along with their thumbnails.
+func Add(entry Entry, img image.Image) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if img != nil {
+		thumbnail, err := saveThumbnail(entry, img)
+		if err != nil {
+			glog.Warningf("Failed to save thumbnail of shared image: %v", err)
+		} else {
+			entry.Thumbnail = thumbnail
+		}
+	}
+	entries, err := load()
+	if err != nil {
+		glog.Errorf("Discarding broken sharing history: %v", err)
+	}
+	entries = append([]Entry{entry}, entries...)
+	for len(entries) > MaxEntries {
+		removeThumbnail(entries[len(entries)-1])
+		entries = entries[:len(entries)-1]
+	}
+	return save(entries)
+}
+
+// Remove removes from the history the entry with the same backend and remote id, along
+// with its thumbnail. It doesn't delete the remote file.
+func Remove(entry Entry) error {
+	mu.Lock()
+	defer mu.Unlock()
+	entries, err := load()
+	if err != nil {
+		return err
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Backend == entry.Backend && e.RemoteID == entry.RemoteID {
+			removeThumbnail(e)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return save(kept)
+}
+
+func saveThumbnail(entry Entry, img image.Image) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, thumbnailsDir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create thumbnails directory %q: %w", dir, err)
+	}
+	thumbnailPath := filepath.Join(dir, fmt.Sprintf("%s-%s.png", entry.Backend, entry.RemoteID))
+	f, err := os.Create(thumbnailPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create thumbnail %q: %w", thumbnailPath, err)
+	}
+	err = png.Encode(f, Thumbnail(img, ThumbnailSize))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write thumbnail %q: %w", thumbnailPath, err)
+	}
+	return thumbnailPath, nil
+}
+
+func removeThumbnail(entry Entry) {
+	if entry.Thumbnail == "" {
+		return
+	}
+	if err := os.Remove(entry.Thumbnail); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("Failed to remove thumbnail %q: %v", entry.Thumbnail, err)
+	}
+}
+
+// Thumbnail returns a scaled down version of `img` that fits in a square of `size` pixels,
+// preserving the aspect ratio.
+func Thumbnail(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > size || h > size {
+		if w > h {
+			w, h = size, (h*size+w/2)/w
+		} else {
+			w, h = (w*size+h/2)/h, size
+		}
+		if w < 1 {
+			w = 1
+		}
+		if h < 1 {
+			h = 1
+		}
+	}
+	thumbnail := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(thumbnail, thumbnail.Rect, img, bounds, draw.Src, nil)
+	return thumbnail
+}
@@ -0,0 +1,122 @@
+// Package hotkey registers global hotkeys: key combinations that trigger an action even when
+// GoShot doesn't have the focus, e.g. to take a screenshot when running in the system tray.
+//
+// Hotkeys are described as a list of modifiers and one key separated by "+", e.g. "win+control+s".
+// It is implemented for X11 (using XGrabKey) and Windows (using RegisterHotKey).
+package hotkey
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Modifier is a bit set of the modifier keys of a hotkey.
+type Modifier uint8
+
+const (
+	ModShift Modifier = 1 << iota
+	ModControl
+	ModAlt
+	ModWin
+)
+
+// modifierNames maps the accepted names of modifiers to their values.
+var modifierNames = map[string]Modifier{
+	"shift":   ModShift,
+	"control": ModControl,
+	"ctrl":    ModControl,
+	"alt":     ModAlt,
+	"win":     ModWin,
+	"super":   ModWin,
+	"meta":    ModWin,
+}
+
+// Hotkey is a combination of modifiers and one key.
+type Hotkey struct {
+	Modifiers Modifier
+
+	// Key is the lowercase name of the key, one of Keys().
+	Key string
+}
+
+// keyCodes holds the platform codes for a key: the X11 keysym name and the Windows virtual-key code.
+type keyCodes struct {
+	x11 string
+	vk  uint32
+}
+
+// keys maps the name of the supported keys to their platform codes. Letters, digits and function keys
+// are added in init().
+var keys = map[string]keyCodes{
+	"print":      {"Print", 0x2C},
+	"space":      {"space", 0x20},
+	"insert":     {"Insert", 0x2D},
+	"delete":     {"Delete", 0x2E},
+	"home":       {"Home", 0x24},
+	"end":        {"End", 0x23},
+	"pageup":     {"Prior", 0x21},
+	"pagedown":   {"Next", 0x22},
+	"pause":      {"Pause", 0x13},
+	"scrolllock": {"Scroll_Lock", 0x91},
+}
+
+func init() {
+	for c := 'a'; c <= 'z'; c++ {
+		keys[string(c)] = keyCodes{string(c), uint32(c - 'a' + 'A')}
+	}
+	for c := '0'; c <= '9'; c++ {
+		keys[string(c)] = keyCodes{string(c), uint32(c)}
+	}
+	for ii := 1; ii <= 24; ii++ {
+		keys[fmt.Sprintf("f%d", ii)] = keyCodes{fmt.Sprintf("F%d", ii), uint32(0x70 + ii - 1)}
+	}
+}
+
+// Keys returns the names of the keys supported, sorted.
+func Keys() []string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse parses a hotkey description, e.g. "win+control+s": any combination of the modifiers
+// "shift", "control" (or "ctrl"), "alt" and "win" (or "super", "meta"), and exactly one key,
+// separated by "+". It is case-insensitive.
+func Parse(desc string) (hk Hotkey, err error) {
+	for _, part := range strings.Split(desc, "+") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if mod, found := modifierNames[part]; found {
+			hk.Modifiers |= mod
+			continue
+		}
+		if _, found := keys[part]; !found {
+			return Hotkey{}, fmt.Errorf("unknown key %q in hotkey %q", part, desc)
+		}
+		if hk.Key != "" {
+			return Hotkey{}, fmt.Errorf("more than one key (%q and %q) in hotkey %q", hk.Key, part, desc)
+		}
+		hk.Key = part
+	}
+	if hk.Key == "" {
+		return Hotkey{}, fmt.Errorf("no key in hotkey %q", desc)
+	}
+	return hk, nil
+}
+
+// String returns the description of the hotkey, in the format accepted by Parse.
+func (hk Hotkey) String() string {
+	var parts []string
+	for _, mod := range []struct {
+		mod  Modifier
+		name string
+	}{{ModWin, "win"}, {ModControl, "control"}, {ModAlt, "alt"}, {ModShift, "shift"}} {
+		if hk.Modifiers&mod.mod != 0 {
+			parts = append(parts, mod.name)
+		}
+	}
+	return strings.Join(append(parts, hk.Key), "+")
+}
@@ -0,0 +1,175 @@
+//go:build linux
+// +build linux
+
+package hotkey
+
+// Global hotkeys in Linux/X11: the keys are grabbed (XGrabKey) in the root window, using its own
+// connection to the X server. All Xlib calls are made from one goroutine, the event loop.
+
+/*
+#cgo pkg-config: x11
+#cgo LDFLAGS: -lX11
+#include <stdlib.h>
+#include <poll.h>
+#include <X11/Xlib.h>
+
+static int grabFailed;
+
+static int grabErrorHandler(Display *dpy, XErrorEvent *ev) {
+	grabFailed = 1;
+	return 0;
+}
+
+// grabKey grabs the key with the modifiers in the root window, also with NumLock and CapsLock
+// (otherwise the hotkey doesn't trigger if they are on). It returns 0 if the grab failed,
+// usually because another program already grabbed the same key combination.
+static int grabKey(Display *dpy, KeyCode keycode, unsigned int modifiers) {
+	unsigned int extra[] = {0, LockMask, Mod2Mask, LockMask | Mod2Mask};
+	Window root = DefaultRootWindow(dpy);
+	int (*previous)(Display *, XErrorEvent *) = XSetErrorHandler(grabErrorHandler);
+	grabFailed = 0;
+	for (int ii = 0; ii < 4; ii++) {
+		XGrabKey(dpy, keycode, modifiers | extra[ii], root, False, GrabModeAsync, GrabModeAsync);
+	}
+	XSync(dpy, False);
+	XSetErrorHandler(previous);
+	return !grabFailed;
+}
+
+// waitForEvents waits for events from the X server for up to timeoutMs milliseconds.
+static void waitForEvents(Display *dpy, int timeoutMs) {
+	struct pollfd fds = {ConnectionNumber(dpy), POLLIN, 0};
+	poll(&fds, 1, timeoutMs);
+}
+
+static int eventType(XEvent *ev) { return ev->type; }
+static unsigned int keyCode(XEvent *ev) { return ev->xkey.keycode; }
+static unsigned int keyState(XEvent *ev) { return ev->xkey.state; }
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/xwindow"
+	"os"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// pollIntervalMs is how often the event loop checks for new registrations, when there are no events.
+const pollIntervalMs = 100
+
+type registration struct {
+	keycode   C.uint
+	modifiers C.uint
+	fn        func()
+}
+
+var (
+	x11Once    sync.Once
+	x11Failure error
+	display    *C.Display
+
+	// requests are executed in the event loop goroutine, that owns the X11 connection.
+	requests = make(chan func())
+
+	// registrations are only accessed from the event loop goroutine.
+	registrations []registration
+)
+
+// initX11 connects to the X server and starts the event loop. It should be called only once.
+func initX11() {
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") != "" {
+		x11Failure = errors.New("global hotkeys are not supported in Wayland sessions, " +
+			"configure a shortcut in the desktop settings instead")
+		return
+	}
+	display = C.XOpenDisplay(nil)
+	if display == nil {
+		x11Failure = errors.New("cannot open X11 display for global hotkeys")
+		return
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		glog.Warningf("Global hotkeys in Wayland sessions only work while an X11 (XWayland) window has the focus.")
+	}
+	go eventLoop()
+}
+
+// Register registers the hotkey globally: `fn` is called (in a separate goroutine) every time it is pressed.
+func Register(hk Hotkey, fn func()) error {
+	x11Once.Do(initX11)
+	if x11Failure != nil {
+		return x11Failure
+	}
+	done := make(chan error)
+	requests <- func() { done <- register(hk, fn) }
+	return <-done
+}
+
+// register implements Register, it must be called from the event loop.
+func register(hk Hotkey, fn func()) error {
+	keysymName := C.CString(keys[hk.Key].x11)
+	defer C.free(unsafe.Pointer(keysymName))
+	keysym := C.XStringToKeysym(keysymName)
+	if keysym == C.NoSymbol {
+		return fmt.Errorf("key %q of hotkey %q not known by X11", hk.Key, hk)
+	}
+	keycode := C.XKeysymToKeycode(display, keysym)
+	if keycode == 0 {
+		return fmt.Errorf("key %q of hotkey %q not in the keyboard map", hk.Key, hk)
+	}
+
+	var modifiers C.uint
+	if hk.Modifiers&ModShift != 0 {
+		modifiers |= C.ShiftMask
+	}
+	if hk.Modifiers&ModControl != 0 {
+		modifiers |= C.ControlMask
+	}
+	if hk.Modifiers&ModAlt != 0 {
+		modifiers |= C.Mod1Mask
+	}
+	if hk.Modifiers&ModWin != 0 {
+		modifiers |= C.Mod4Mask
+	}
+	xwindow.LockErrorHandler() // grabKey replaces the Xlib error handler while grabbing.
+	grabbed := C.grabKey(display, keycode, modifiers) != 0
+	xwindow.UnlockErrorHandler()
+	if !grabbed {
+		return fmt.Errorf("failed to grab hotkey %q: probably already in use by another program", hk)
+	}
+	glog.V(1).Infof("Registered global hotkey %q (keycode=%d, modifiers=0x%x)", hk, keycode, modifiers)
+	registrations = append(registrations, registration{keycode: C.uint(keycode), modifiers: modifiers, fn: fn})
+	return nil
+}
+
+// eventLoop executes the requests and dispatches the key presses of the registered hotkeys.
+func eventLoop() {
+	runtime.LockOSThread()
+	for {
+		select {
+		case req := <-requests:
+			req()
+		default:
+		}
+		for C.XPending(display) > 0 {
+			var xev C.XEvent
+			C.XNextEvent(display, &xev)
+			if C.eventType(&xev) != C.KeyPress {
+				continue
+			}
+			// Ignore NumLock and CapsLock.
+			state := C.keyState(&xev) &^ (C.LockMask | C.Mod2Mask)
+			keycode := C.keyCode(&xev)
+			for _, r := range registrations {
+				if r.keycode == keycode && r.modifiers == state {
+					go r.fn()
+				}
+			}
+		}
+		C.waitForEvents(display, pollIntervalMs)
+	}
+}
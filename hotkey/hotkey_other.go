@@ -0,0 +1,15 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package hotkey
+
+// Placeholder implementation that informs about missing capability.
+
+import (
+	"errors"
+)
+
+// Register is not implemented in this platform.
+func Register(hk Hotkey, fn func()) error {
+	return errors.New("Global hotkeys not implemented in this platform, sorry.")
+}
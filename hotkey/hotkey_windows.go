@@ -0,0 +1,124 @@
+//go:build windows
+// +build windows
+
+package hotkey
+
+// Global hotkeys in Windows: registered with RegisterHotKey, the WM_HOTKEY messages are posted
+// to the thread that registered them, so all calls are made from one locked thread, the message loop.
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32                = syscall.MustLoadDLL("user32.dll")
+	procRegisterHotKey    = user32.MustFindProc("RegisterHotKey")
+	procGetMessage        = user32.MustFindProc("GetMessageW")
+	procPeekMessage       = user32.MustFindProc("PeekMessageW")
+	procPostThreadMessage = user32.MustFindProc("PostThreadMessageW")
+
+	kernel32               = syscall.MustLoadDLL("kernel32.dll")
+	procGetCurrentThreadId = kernel32.MustFindProc("GetCurrentThreadId")
+)
+
+const (
+	modAlt      = 0x0001
+	modControl  = 0x0002
+	modShift    = 0x0004
+	modWin      = 0x0008
+	modNoRepeat = 0x4000
+
+	wmHotkey = 0x0312
+	wmApp    = 0x8000 // Used to wake up the message loop to execute requests.
+)
+
+// msg is the Windows MSG structure.
+type msg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      struct{ x, y int32 }
+}
+
+var (
+	loopOnce sync.Once
+	threadID uintptr
+
+	// requests are executed in the message loop thread.
+	requests = make(chan func(), 1)
+
+	// handlers maps the hotkey ids to their functions, only accessed from the message loop.
+	handlers = make(map[uintptr]func())
+)
+
+// Register registers the hotkey globally: `fn` is called (in a separate goroutine) every time it is pressed.
+func Register(hk Hotkey, fn func()) error {
+	loopOnce.Do(startMessageLoop)
+	done := make(chan error)
+	requests <- func() { done <- register(hk, fn) }
+	procPostThreadMessage.Call(threadID, wmApp, 0, 0)
+	return <-done
+}
+
+// register implements Register, it must be called from the message loop thread.
+func register(hk Hotkey, fn func()) error {
+	modifiers := uintptr(modNoRepeat)
+	if hk.Modifiers&ModShift != 0 {
+		modifiers |= modShift
+	}
+	if hk.Modifiers&ModControl != 0 {
+		modifiers |= modControl
+	}
+	if hk.Modifiers&ModAlt != 0 {
+		modifiers |= modAlt
+	}
+	if hk.Modifiers&ModWin != 0 {
+		modifiers |= modWin
+	}
+	id := uintptr(len(handlers) + 1)
+	r, _, err := procRegisterHotKey.Call(0, id, modifiers, uintptr(keys[hk.Key].vk))
+	if r == 0 {
+		return fmt.Errorf("failed to register hotkey %q: %v", hk, err)
+	}
+	glog.V(1).Infof("Registered global hotkey %q (id=%d)", hk, id)
+	handlers[id] = fn
+	return nil
+}
+
+// startMessageLoop starts the message loop in a locked thread, and waits for it to be ready.
+func startMessageLoop() {
+	ready := make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		threadID, _, _ = procGetCurrentThreadId.Call()
+		var m msg
+		// Makes sure the thread message queue is created, before anyone posts to it.
+		procPeekMessage.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0, 0)
+		close(ready)
+		for {
+			r, _, _ := procGetMessage.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+			if int32(r) <= 0 {
+				glog.Errorf("Hotkeys message loop finished.")
+				return
+			}
+			switch m.message {
+			case wmApp:
+				for len(requests) > 0 {
+					(<-requests)()
+				}
+			case wmHotkey:
+				if fn, found := handlers[m.wParam]; found {
+					go fn()
+				}
+			}
+		}
+	}()
+	<-ready
+}
@@ -0,0 +1,212 @@
+// Package ipc implements the local socket used by a running GoShot (e.g. in the system tray) to
+// receive commands from other GoShot invocations, e.g. to take a screenshot.
+//
+// The protocol is line based: the client sends one line with the command and its arguments,
+// separated by spaces (arguments with spaces or quotes are quoted as Go strings), and the
+// server replies with one line, starting with "ok" or "error", followed by a message. Each
+// connection carries one command.
+package ipc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout for the client to connect, send its command and receive the reply.
+var Timeout = 5 * time.Second
+
+// ErrNotRunning is returned by Send if there is no GoShot instance listening.
+var ErrNotRunning = errors.New("no GoShot instance running")
+
+// ErrAlreadyRunning is returned by Listen if another GoShot instance is already listening.
+var ErrAlreadyRunning = errors.New("another GoShot instance is already running")
+
+// Handler executes a command received with its arguments, and returns a message to the client.
+type Handler func(command string, args []string) (string, error)
+
+// SocketPath returns the path of the socket: in $XDG_RUNTIME_DIR if set, otherwise in the user's
+// cache directory.
+func SocketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find directory for socket: %w", err)
+		}
+		dir = filepath.Join(cacheDir, "goshot")
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("failed to create directory for socket: %w", err)
+		}
+	}
+	return filepath.Join(dir, "goshot.sock"), nil
+}
+
+// Server listens to commands on the socket.
+type Server struct {
+	listener net.Listener
+	handler  Handler
+
+	// active tracks the connections being handled, so Close can wait for their replies.
+	active sync.WaitGroup
+}
+
+// Listen creates the socket and starts serving commands in a separate goroutine, calling
+// `handler` for each command. It returns ErrAlreadyRunning if another instance is listening.
+// A stale socket, left by an instance that didn't exit cleanly, is removed.
+func Listen(handler Handler) (*Server, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+	if conn, err := net.DialTimeout("unix", path, Timeout); err == nil {
+		_ = conn.Close()
+		return nil, ErrAlreadyRunning
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket %q: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", path, err)
+	}
+	glog.V(1).Infof("Listening to commands on %q", path)
+	s := &Server{listener: listener, handler: handler}
+	go s.serve()
+	return s, nil
+}
+
+// Close stops listening and removes the socket. It waits for the commands being handled
+// to reply.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.active.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				glog.Errorf("Failed to accept connection: %v", err)
+			}
+			return
+		}
+		s.active.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle reads one command from the connection, executes it and writes the reply.
+func (s *Server) handle(conn net.Conn) {
+	defer s.active.Done()
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetReadDeadline(time.Now().Add(Timeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		glog.Errorf("Failed to read command: %v", err)
+		return
+	}
+	fields, err := splitArgs(line)
+	if err == nil && len(fields) == 0 {
+		err = errors.New("empty command")
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(conn, "error %s\n", err)
+		return
+	}
+	glog.V(1).Infof("Received command %q", fields)
+	msg, err := s.handler(fields[0], fields[1:])
+	if err != nil {
+		_, _ = fmt.Fprintf(conn, "error %s\n", err)
+		return
+	}
+	_, _ = fmt.Fprintf(conn, "ok %s\n", msg)
+}
+
+// Send sends the command to the running GoShot instance, and returns its reply message. It
+// returns ErrNotRunning if there is no instance listening.
+func Send(command string, args ...string) (string, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.DialTimeout("unix", path, Timeout)
+	if err != nil {
+		return "", ErrNotRunning
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(Timeout))
+	if _, err = fmt.Fprintln(conn, joinArgs(append([]string{command}, args...))); err != nil {
+		return "", fmt.Errorf("failed to send command %q: %w", command, err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read reply to %q: %w", command, err)
+	}
+	reply = strings.TrimSpace(reply)
+	status, msg := reply, ""
+	if idx := strings.Index(reply, " "); idx >= 0 {
+		status, msg = reply[:idx], reply[idx+1:]
+	}
+	if status != "ok" {
+		return "", fmt.Errorf("%s: %s", command, msg)
+	}
+	return msg, nil
+}
+
+// joinArgs joins the arguments with spaces, quoting those that need it.
+func joinArgs(args []string) string {
+	parts := make([]string, len(args))
+	for ii, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"\\") {
+			arg = strconv.Quote(arg)
+		}
+		parts[ii] = arg
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitArgs splits a line joined by joinArgs.
+func splitArgs(line string) (args []string, err error) {
+	line = strings.TrimSpace(line)
+	for line != "" {
+		var arg string
+		if line[0] == '"' {
+			// Find the closing quote, skipping the escaped characters.
+			end := 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated quoted argument in %q", line)
+			}
+			arg, err = strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted argument %q: %w", line[:end+1], err)
+			}
+			line = line[end+1:]
+		} else {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			arg, line = line[:end], line[end:]
+		}
+		args = append(args, arg)
+		line = strings.TrimLeft(line, " \t")
+	}
+	return args, nil
+}
@@ -3,9 +3,10 @@ package main
 import (
 	"flag"
 	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/ipc"
 	"github.com/janpfeifer/goshot/screenshot"
 	"github.com/janpfeifer/goshot/systray"
-	"os"
 )
 
 var (
@@ -13,19 +14,73 @@ var (
 		"Set this flag to take the app run in a system tray, and respond to a "+
 			"global shortcut to take screenshots.")
 	flagHotkey = flag.String("hotkey", "win+control+s",
-		"Hotkey to register to trigger a screenshot. It accepts any combination "+
+		"Global hotkeys to register to trigger a screenshot. It accepts any combination "+
 			"of 'shift', 'control', 'win', 'alt' and normal key, separated by '+'. Eg.: "+
-			"'win+control+s`. Only used in -systray mode.")
+			"'win+control+s`. Several hotkeys can be given separated by ',', each optionally "+
+			"prefixed by the action it triggers, one of 'screenshot' (default), 'region', "+
+			"'delayed', 'window' (the active window), 'record' (start or stop recording a region) or "+
+			"'repeat' (the last action). Eg.: 'win+control+s,region:win+control+r'. "+
+			"Only used in -systray mode.")
+	flagDisplay = flag.Int("display", 0,
+		"Index of the display to capture, 0 for the primary one.")
+	flagDelay = flag.Duration("delay", 0,
+		"Delay before taking the screenshot, e.g. '5s'. In -systray mode, it's the delay of the "+
+			"delayed screenshot (the one in the settings if not set).")
+	flagWindow = flag.String("window", "",
+		"Capture only one window, instead of the display: 'active' for the active window, or 'pick' "+
+			"to click on the window to capture. Only supported in X11.")
+	flagScroll = flag.String("scroll", "",
+		"Scrolling capture: select a region, which is captured while it is scrolled down and stitched "+
+			"into one tall image. 'manual' to scroll it yourself, or 'auto' to let GoShot scroll it (X11 only).")
+	flagRecord = flag.Bool("record", false,
+		"Record a region as an animation: select the region of the screenshot, and it's recorded until "+
+			"the maximum duration set in the settings, or until 'goshot ctl stop'.")
+	flagRegion = flag.Bool("region", false,
+		"Set this flag to start the editor selecting a region of the screenshot.")
+	flagStandalone = flag.Bool("standalone", false,
+		"Set this flag to take the screenshot in this process, even if GoShot is running "+
+			"in the system tray. By default the running GoShot is asked to take it.")
+	flagShared = flag.Bool("shared", false,
+		"Set this flag to only open the window with the images shared previously, "+
+			"from where one can copy their links or delete them.")
 )
 
 func main() {
-	systray.PreParseArgs = make([]string, len(os.Args))
-	copy(systray.PreParseArgs, os.Args)
+	// If started as a clipboard helper, it doesn't return.
+	clipboard.RunHelperIfRequested()
+
 	flag.Parse()
+	if flag.Arg(0) == "ctl" {
+		runCtl(flag.Args()[1:])
+		return
+	}
 	if *flagSysTray {
 		glog.Infof("Running in system tray.")
-		systray.Run()
+		if *flagDelay > 0 {
+			systray.Delay = *flagDelay
+		}
+		systray.Run(*flagHotkey)
+	} else if *flagShared {
+		screenshot.RunShared()
 	} else {
-		screenshot.Run()
+		opts := screenshot.CaptureOptions{Delay: *flagDelay, Region: *flagRegion, Display: *flagDisplay,
+			Window: *flagWindow, Scroll: *flagScroll, Record: *flagRecord}
+		if !*flagStandalone {
+			// Ask GoShot running in the system tray to take the screenshot, if there is one.
+			msg, err := ipc.Send("capture", opts.Args()...)
+			if err == nil {
+				glog.Infof("GoShot running in the system tray: %s", msg)
+				return
+			}
+			if err != ipc.ErrNotRunning {
+				glog.Errorf("Failed to contact GoShot running in the system tray: %v", err)
+			}
+		}
+		screenshot.RunWithOptions(opts)
+	}
+
+	// Keep what was copied to the clipboard available after we exit.
+	if err := clipboard.Persist(); err != nil {
+		glog.Errorf("Failed to keep clipboard content after exit: %v", err)
 	}
 }
@@ -0,0 +1,199 @@
+// Package palette holds the colors offered for annotations: a palette of named swatches, that
+// can be shared as JSON or GIMP palette (.gpl) files, and the list of recently used colors.
+package palette
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Swatch is a named color of a palette. In JSON the color is formatted as "#rrggbb", or
+// "#rrggbbaa" if not opaque.
+type Swatch struct {
+	Name  string
+	Color color.NRGBA
+}
+
+// Palette is a named list of swatches.
+type Palette struct {
+	Name     string   `json:"name,omitempty"`
+	Swatches []Swatch `json:"swatches"`
+}
+
+// Default is the palette offered if none was configured.
+var Default = Palette{Name: "GoShot", Swatches: []Swatch{
+	{"Red", color.NRGBA{R: 0xe5, G: 0x1c, B: 0x23, A: 0xff}},
+	{"Orange", color.NRGBA{R: 0xff, G: 0x98, B: 0x00, A: 0xff}},
+	{"Yellow", color.NRGBA{R: 0xff, G: 0xeb, B: 0x3b, A: 0xff}},
+	{"Green", color.NRGBA{R: 0x25, G: 0x9b, B: 0x24, A: 0xff}},
+	{"Blue", color.NRGBA{R: 0x45, G: 0x5e, B: 0xde, A: 0xff}},
+	{"Purple", color.NRGBA{R: 0x9c, G: 0x27, B: 0xb0, A: 0xff}},
+	{"Black", color.NRGBA{A: 0xff}},
+	{"White", color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+}}
+
+// gplHeader is the first line of GIMP palette files.
+const gplHeader = "GIMP Palette"
+
+// ParseHex parses colors formatted as "#rrggbb" or "#rrggbbaa". An empty string is transparent.
+func ParseHex(s string) (c color.NRGBA, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return c, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return c, fmt.Errorf("invalid color %q, it must be formatted as #rrggbb or #rrggbbaa", s)
+	}
+	return color.NRGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
+
+// FormatHex formats the color as "#rrggbb", or "#rrggbbaa" if not opaque.
+func FormatHex(c color.NRGBA) string {
+	if c.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// swatchJSON is how a Swatch is encoded in JSON.
+type swatchJSON struct {
+	Name  string `json:"name,omitempty"`
+	Color string `json:"color"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Swatch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(swatchJSON{Name: s.Name, Color: FormatHex(s.Color)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Swatch) UnmarshalJSON(data []byte) error {
+	var encoded swatchJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	c, err := ParseHex(encoded.Color)
+	if err != nil {
+		return err
+	}
+	*s = Swatch{Name: encoded.Name, Color: c}
+	return nil
+}
+
+// Read reads a palette in JSON or in the GIMP palette format, detected from its content.
+func Read(r io.Reader) (Palette, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Palette{}, fmt.Errorf("failed to read palette: %w", err)
+	}
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte(gplHeader)) {
+		return ReadGPL(bytes.NewReader(trimmed))
+	}
+	var p Palette
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Palette{}, fmt.Errorf("invalid palette, it must be JSON or a GIMP palette: %w", err)
+	}
+	return p, nil
+}
+
+// ReadGPL reads a palette in the GIMP palette format: a "GIMP Palette" header, optional "Name:"
+// and "Columns:" lines, and one color per line as red, green and blue values followed by its name.
+// Lines starting with "#" are comments.
+func ReadGPL(r io.Reader) (Palette, error) {
+	var p Palette
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case lineNum == 1:
+			if line != gplHeader {
+				return p, fmt.Errorf("not a GIMP palette, it must start with %q", gplHeader)
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+			// Empty or comment.
+		case strings.HasPrefix(line, "Name:"):
+			p.Name = strings.TrimSpace(strings.TrimPrefix(line, "Name:"))
+		case strings.HasPrefix(line, "Columns:"):
+			// Only used for display by GIMP.
+		default:
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return p, fmt.Errorf("invalid color in line %d of GIMP palette: %q", lineNum, line)
+			}
+			var rgb [3]uint8
+			for ii := range rgb {
+				value, err := strconv.ParseUint(fields[ii], 10, 8)
+				if err != nil {
+					return p, fmt.Errorf("invalid color in line %d of GIMP palette: %q", lineNum, line)
+				}
+				rgb[ii] = uint8(value)
+			}
+			p.Swatches = append(p.Swatches, Swatch{
+				Name:  strings.Join(fields[3:], " "),
+				Color: color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xff},
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return p, fmt.Errorf("failed to read GIMP palette: %w", err)
+	}
+	return p, nil
+}
+
+// WriteJSON writes the palette as indented JSON.
+func (p Palette) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// WriteGPL writes the palette in the GIMP palette format. The format has no transparency, so
+// the alpha of the colors is dropped.
+func (p Palette) WriteGPL(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, gplHeader)
+	if p.Name != "" {
+		fmt.Fprintf(&buf, "Name: %s\n", p.Name)
+	}
+	fmt.Fprintln(&buf, "#")
+	for _, s := range p.Swatches {
+		name := s.Name
+		if name == "" {
+			name = FormatHex(color.NRGBA{R: s.Color.R, G: s.Color.G, B: s.Color.B, A: 0xff})
+		}
+		fmt.Fprintf(&buf, "%3d %3d %3d\t%s\n", s.Color.R, s.Color.G, s.Color.B, name)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// AddRecent returns the list of recent colors with `c` first, removed from where it was before,
+// and keeping at most `size` colors.
+func AddRecent(recent []color.NRGBA, c color.NRGBA, size int) []color.NRGBA {
+	if size <= 0 {
+		return nil
+	}
+	updated := []color.NRGBA{c}
+	for _, previous := range recent {
+		if len(updated) >= size {
+			break
+		}
+		if previous != c {
+			updated = append(updated, previous)
+		}
+	}
+	return updated
+}
@@ -0,0 +1,161 @@
+package palette
+
+import (
+	"bytes"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHex(t *testing.T) {
+	testCases := []struct {
+		hex, formatted string
+		want           color.NRGBA
+	}{
+		{hex: "#e51c23", formatted: "#e51c23", want: color.NRGBA{R: 0xe5, G: 0x1c, B: 0x23, A: 0xff}},
+		{hex: "#E51C23FF", formatted: "#e51c23", want: color.NRGBA{R: 0xe5, G: 0x1c, B: 0x23, A: 0xff}},
+		{hex: "  00ff0080 ", formatted: "#00ff0080", want: color.NRGBA{G: 0xff, A: 0x80}},
+		{hex: "", formatted: "#00000000", want: color.NRGBA{}},
+	}
+	for _, tc := range testCases {
+		got, err := ParseHex(tc.hex)
+		if err != nil {
+			t.Errorf("ParseHex(%q) failed: %v", tc.hex, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseHex(%q) = %v, wanted %v", tc.hex, got, tc.want)
+		}
+		formatted := FormatHex(got)
+		if formatted != tc.formatted {
+			t.Errorf("FormatHex(%v) = %q, wanted %q", got, formatted, tc.formatted)
+		}
+		if again, err := ParseHex(formatted); err != nil || again != got {
+			t.Errorf("ParseHex(FormatHex(%v)) = (%v, %v), wanted the same color", got, again, err)
+		}
+	}
+
+	for _, invalid := range []string{"#fff", "#12345", "#1234567", "#gg0000", "red", "#ff0000ff00"} {
+		if c, err := ParseHex(invalid); err == nil {
+			t.Errorf("ParseHex(%q) = %v, wanted an error", invalid, c)
+		}
+	}
+}
+
+const gplPalette = `GIMP Palette
+Name: Test colors
+Columns: 4
+# A comment.
+
+255   0   0	Bright red
+  0 128 255	Sky
+ 16  32  48
+`
+
+func TestReadGPL(t *testing.T) {
+	want := Palette{Name: "Test colors", Swatches: []Swatch{
+		{"Bright red", color.NRGBA{R: 0xff, A: 0xff}},
+		{"Sky", color.NRGBA{G: 0x80, B: 0xff, A: 0xff}},
+		{"", color.NRGBA{R: 16, G: 32, B: 48, A: 0xff}},
+	}}
+	got, err := ReadGPL(strings.NewReader(gplPalette))
+	if err != nil {
+		t.Fatalf("ReadGPL() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadGPL() = %+v, wanted %+v", got, want)
+	}
+
+	// Read detects the format from the content.
+	got, err = Read(strings.NewReader("\n" + gplPalette))
+	if err != nil {
+		t.Fatalf("Read() of a GIMP palette failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() of a GIMP palette = %+v, wanted %+v", got, want)
+	}
+
+	for _, invalid := range []string{
+		"Not a palette\n255 0 0 Red\n",
+		"GIMP Palette\n255 0 Red\n",
+		"GIMP Palette\n256 0 0 Red\n",
+	} {
+		if p, err := ReadGPL(strings.NewReader(invalid)); err == nil {
+			t.Errorf("ReadGPL(%q) = %+v, wanted an error", invalid, p)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	p := Palette{Name: "Round trip", Swatches: []Swatch{
+		{"Red", color.NRGBA{R: 0xe5, G: 0x1c, B: 0x23, A: 0xff}},
+		{"Translucent blue", color.NRGBA{B: 0xff, A: 0x40}},
+		{"", color.NRGBA{R: 1, G: 2, B: 3, A: 0xff}},
+	}}
+
+	var buf bytes.Buffer
+	if err := p.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"#0000ff40"`) {
+		t.Errorf("WriteJSON() didn't format colors as hex: %s", buf.String())
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() of JSON failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("Read(WriteJSON()) = %+v, wanted %+v", got, p)
+	}
+
+	// GIMP palettes drop the alpha, and unnamed colors are named after their hex value.
+	buf.Reset()
+	if err := p.WriteGPL(&buf); err != nil {
+		t.Fatalf("WriteGPL() failed: %v", err)
+	}
+	want := Palette{Name: "Round trip", Swatches: []Swatch{
+		{"Red", color.NRGBA{R: 0xe5, G: 0x1c, B: 0x23, A: 0xff}},
+		{"Translucent blue", color.NRGBA{B: 0xff, A: 0xff}},
+		{"#010203", color.NRGBA{R: 1, G: 2, B: 3, A: 0xff}},
+	}}
+	got, err = ReadGPL(&buf)
+	if err != nil {
+		t.Fatalf("ReadGPL(WriteGPL()) failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadGPL(WriteGPL()) = %+v, wanted %+v", got, want)
+	}
+
+	if p, err := Read(strings.NewReader("{not json")); err == nil {
+		t.Errorf("Read() of invalid content = %+v, wanted an error", p)
+	}
+}
+
+func TestAddRecent(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	green := color.NRGBA{G: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	black := color.NRGBA{A: 0xff}
+	testCases := []struct {
+		name   string
+		recent []color.NRGBA
+		c      color.NRGBA
+		size   int
+		want   []color.NRGBA
+	}{
+		{name: "empty", recent: nil, c: red, size: 3, want: []color.NRGBA{red}},
+		{name: "new color", recent: []color.NRGBA{red, green}, c: blue, size: 3, want: []color.NRGBA{blue, red, green}},
+		{name: "moved to front", recent: []color.NRGBA{red, green, blue}, c: blue, size: 3, want: []color.NRGBA{blue, red, green}},
+		{name: "already first", recent: []color.NRGBA{red, green}, c: red, size: 3, want: []color.NRGBA{red, green}},
+		{name: "oldest dropped", recent: []color.NRGBA{red, green, blue}, c: black, size: 3, want: []color.NRGBA{black, red, green}},
+		{name: "size reduced", recent: []color.NRGBA{red, green, blue}, c: black, size: 2, want: []color.NRGBA{black, red}},
+		{name: "size 0", recent: []color.NRGBA{red, green}, c: blue, size: 0, want: nil},
+	}
+	for _, tc := range testCases {
+		got := AddRecent(tc.recent, tc.c, tc.size)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: AddRecent(%v, %v, %d) = %v, wanted %v", tc.name, tc.recent, tc.c, tc.size, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package record
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// pngSignature starts every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// APNG frame disposal and blending operations, see https://wiki.mozilla.org/APNG_Specification.
+const (
+	apngDisposeNone = 0
+	apngBlendSource = 0
+	apngBlendOver   = 1
+)
+
+// EncodeAPNG encodes the frames as an animated PNG (APNG) that loops forever, in 8 bits RGBA.
+// Viewers that don't support APNG show the first frame.
+func EncodeAPNG(w io.Writer, frames []Frame) error {
+	list, err := patches(frames)
+	if err != nil {
+		return err
+	}
+	bounds := list[0].frame.Rect
+	e := &apngEncoder{w: w}
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(header[4:], uint32(bounds.Dy()))
+	header[8] = 8  // Bit depth.
+	header[9] = 6  // Color type: RGBA.
+	header[10] = 0 // Compression, filter and interlace methods.
+	e.chunk("IHDR", header)
+	animControl := make([]byte, 8)
+	binary.BigEndian.PutUint32(animControl[0:], uint32(len(list)))
+	binary.BigEndian.PutUint32(animControl[4:], 0) // Loop forever.
+	e.chunk("acTL", animControl)
+
+	for ii, p := range list {
+		rect := p.rect.Sub(bounds.Min)
+		frameControl := make([]byte, 26)
+		binary.BigEndian.PutUint32(frameControl[0:], e.nextSequence())
+		binary.BigEndian.PutUint32(frameControl[4:], uint32(rect.Dx()))
+		binary.BigEndian.PutUint32(frameControl[8:], uint32(rect.Dy()))
+		binary.BigEndian.PutUint32(frameControl[12:], uint32(rect.Min.X))
+		binary.BigEndian.PutUint32(frameControl[16:], uint32(rect.Min.Y))
+		num, den := apngDelay(p.delay)
+		binary.BigEndian.PutUint16(frameControl[20:], num)
+		binary.BigEndian.PutUint16(frameControl[22:], den)
+		frameControl[24] = apngDisposeNone
+		frameControl[25] = apngBlendOver
+		if ii == 0 {
+			frameControl[25] = apngBlendSource
+		}
+		e.chunk("fcTL", frameControl)
+
+		data, err := compressPatch(&p)
+		if err != nil {
+			return err
+		}
+		if ii == 0 {
+			e.chunk("IDAT", data)
+		} else {
+			e.chunk("fdAT", append(uint32Bytes(e.nextSequence()), data...))
+		}
+	}
+	e.chunk("IEND", nil)
+	return e.err
+}
+
+// apngDelay returns the delay as a fraction of seconds, in milliseconds if it fits.
+func apngDelay(d time.Duration) (num, den uint16) {
+	if ms := d.Milliseconds(); ms <= 0xffff {
+		return uint16(ms), 1000
+	}
+	cs := d.Milliseconds() / 10
+	if cs > 0xffff {
+		cs = 0xffff
+	}
+	return uint16(cs), 100
+}
+
+// apngEncoder writes the chunks of a PNG file, keeping the first error.
+type apngEncoder struct {
+	w        io.Writer
+	sequence uint32
+	err      error
+}
+
+// nextSequence returns the next sequence number of the animation chunks.
+func (e *apngEncoder) nextSequence() uint32 {
+	seq := e.sequence
+	e.sequence++
+	return seq
+}
+
+// chunk writes a chunk: its length, type, data and CRC.
+func (e *apngEncoder) chunk(chunkType string, data []byte) {
+	if e.err != nil {
+		return
+	}
+	buf := make([]byte, 0, len(data)+12)
+	buf = append(buf, uint32Bytes(uint32(len(data)))...)
+	buf = append(buf, chunkType...)
+	buf = append(buf, data...)
+	buf = append(buf, uint32Bytes(crc32.ChecksumIEEE(buf[4:]))...)
+	_, e.err = e.w.Write(buf)
+}
+
+// uint32Bytes returns the value in big-endian order, as used by PNG.
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// compressPatch returns the zlib compressed and filtered rows of the patch, in non-premultiplied
+// RGBA. Unchanged pixels are transparent.
+func compressPatch(p *patch) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	width := 4 * p.rect.Dx()
+	prevRow := make([]byte, width)
+	row := make([]byte, width)
+	filtered := make([]byte, width+1)
+	for y := p.rect.Min.Y; y < p.rect.Max.Y; y++ {
+		for x := p.rect.Min.X; x < p.rect.Max.X; x++ {
+			pix := row[4*(x-p.rect.Min.X):]
+			if p.unchanged(x, y) {
+				copy(pix[:4], []byte{0, 0, 0, 0})
+				continue
+			}
+			i := p.frame.PixOffset(x, y)
+			r, g, b, a := p.frame.Pix[i], p.frame.Pix[i+1], p.frame.Pix[i+2], p.frame.Pix[i+3]
+			if a != 0 && a != 0xff {
+				// Un-premultiply alpha.
+				r = uint8(int(r) * 0xff / int(a))
+				g = uint8(int(g) * 0xff / int(a))
+				b = uint8(int(b) * 0xff / int(a))
+			}
+			pix[0], pix[1], pix[2], pix[3] = r, g, b, a
+		}
+		filterRow(filtered, row, prevRow)
+		if _, err := zw.Write(filtered); err != nil {
+			return nil, err
+		}
+		row, prevRow = prevRow, row
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PNG row filters.
+const (
+	filterNone = iota
+	filterSub
+	filterUp
+	filterAverage
+	filterPaeth
+)
+
+// filterRow writes into `dst` the filter type followed by the filtered `row`, choosing the
+// filter with the smallest sum of absolute differences, like the standard image/png encoder.
+func filterRow(dst, row, prevRow []byte) {
+	const bpp = 4
+	bestSum := -1
+	candidate := make([]byte, len(row))
+	for filter := filterNone; filter <= filterPaeth; filter++ {
+		sum := 0
+		for ii := range row {
+			var left, up, upLeft byte
+			if ii >= bpp {
+				left, upLeft = row[ii-bpp], prevRow[ii-bpp]
+			}
+			up = prevRow[ii]
+			var predictor byte
+			switch filter {
+			case filterSub:
+				predictor = left
+			case filterUp:
+				predictor = up
+			case filterAverage:
+				predictor = byte((int(left) + int(up)) / 2)
+			case filterPaeth:
+				predictor = paeth(left, up, upLeft)
+			}
+			v := row[ii] - predictor
+			candidate[ii] = v
+			if v < 0x80 {
+				sum += int(v)
+			} else {
+				sum += 0x100 - int(v)
+			}
+		}
+		if bestSum < 0 || sum < bestSum {
+			bestSum = sum
+			dst[0] = byte(filter)
+			copy(dst[1:], candidate)
+		}
+	}
+}
+
+// paeth implements the Paeth predictor of the PNG specification.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,260 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+	"time"
+)
+
+type pngChunk struct {
+	chunkType string
+	data      []byte
+}
+
+// readChunks splits the PNG file in its chunks, checking the signature and their CRCs.
+func readChunks(t *testing.T, data []byte) (chunks []pngChunk) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		t.Fatalf("PNG signature missing")
+	}
+	data = data[len(pngSignature):]
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("Truncated chunk at the end of the file")
+		}
+		length := int(binary.BigEndian.Uint32(data))
+		if len(data) < 12+length {
+			t.Fatalf("Truncated chunk %q", data[4:8])
+		}
+		c := pngChunk{chunkType: string(data[4:8]), data: data[8 : 8+length]}
+		if crc := binary.BigEndian.Uint32(data[8+length:]); crc != crc32.ChecksumIEEE(data[4:8+length]) {
+			t.Errorf("Chunk %q: wrong CRC %#x", c.chunkType, crc)
+		}
+		chunks = append(chunks, c)
+		data = data[12+length:]
+	}
+	return chunks
+}
+
+// writeChunk appends a chunk to the PNG file.
+func writeChunk(buf *bytes.Buffer, chunkType string, data []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.WriteString(chunkType)
+	buf.Write(data)
+	_ = binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(chunkType), data...)))
+}
+
+// decodeFrame decodes the compressed rows of an APNG frame of the given size, by wrapping them
+// in a plain PNG file.
+func decodeFrame(t *testing.T, width, height int, data []byte) image.Image {
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(width))
+	binary.BigEndian.PutUint32(header[4:], uint32(height))
+	header[8], header[9] = 8, 6
+	writeChunk(&buf, "IHDR", header)
+	writeChunk(&buf, "IDAT", data)
+	writeChunk(&buf, "IEND", nil)
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode APNG frame: %v", err)
+	}
+	return img
+}
+
+func TestEncodeAPNG(t *testing.T) {
+	// A gradient with noise, so the rows use different filters, and a semi-transparent corner,
+	// away from the changes, for the un-premultiplication.
+	frames, wantFrames := testFrames(func(x, y int) color.RGBA {
+		c := color.RGBA{R: uint8(x * 9), G: uint8(y * 13), B: uint8((x*x*7 + y*31) % 251), A: 0xff}
+		if x >= testBounds.Max.X-6 && y < testBounds.Min.Y+4 {
+			c.A = uint8(0x40 + 0x20*(y-testBounds.Min.Y))
+			c.R = uint8(int(c.R) * int(c.A) / 0xff)
+			c.G = uint8(int(c.G) * int(c.A) / 0xff)
+			c.B = uint8(int(c.B) * int(c.A) / 0xff)
+		}
+		return c
+	})
+	var buf bytes.Buffer
+	if err := EncodeAPNG(&buf, frames); err != nil {
+		t.Fatalf("EncodeAPNG() failed: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Viewers without APNG support show the first frame.
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() failed: %v", err)
+	}
+	if err := diffPixels(img, frames[0].Image, 1); err != nil {
+		t.Errorf("Default image: %v", err)
+	}
+
+	chunks := readChunks(t, data)
+	var types []string
+	for _, c := range chunks {
+		types = append(types, c.chunkType)
+	}
+	wantTypes := []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}
+	if len(types) != len(wantTypes) {
+		t.Fatalf("Chunks %q, wanted %q", types, wantTypes)
+	}
+	for ii := range types {
+		if types[ii] != wantTypes[ii] {
+			t.Fatalf("Chunks %q, wanted %q", types, wantTypes)
+		}
+	}
+	acTL := chunks[1].data
+	if n, plays := binary.BigEndian.Uint32(acTL), binary.BigEndian.Uint32(acTL[4:]); n != uint32(len(wantFrames)) || plays != 0 {
+		t.Errorf("acTL: %d frames, %d plays, wanted %d frames, 0 plays", n, plays, len(wantFrames))
+	}
+
+	wantDelays := []time.Duration{100 * time.Millisecond, 150 * time.Millisecond, 200 * time.Millisecond}
+	canvas := image.NewRGBA(image.Rect(0, 0, testBounds.Dx(), testBounds.Dy()))
+	var sequence uint32
+	var frame int
+	var rect image.Rectangle
+	var blend byte
+	for _, c := range chunks[2 : len(chunks)-1] {
+		switch c.chunkType {
+		case "fcTL":
+			if seq := binary.BigEndian.Uint32(c.data); seq != sequence {
+				t.Errorf("fcTL of frame %d: sequence number %d, wanted %d", frame, seq, sequence)
+			}
+			sequence++
+			width, height := int(binary.BigEndian.Uint32(c.data[4:])), int(binary.BigEndian.Uint32(c.data[8:]))
+			x, y := int(binary.BigEndian.Uint32(c.data[12:])), int(binary.BigEndian.Uint32(c.data[16:]))
+			rect = image.Rect(x, y, x+width, y+height)
+			want := canvas.Rect
+			if frame > 0 {
+				want = diffRect(frames[wantFrames[frame-1]].Image, frames[wantFrames[frame]].Image).Sub(testBounds.Min)
+			}
+			if rect != want {
+				t.Errorf("fcTL of frame %d: rectangle %v, wanted %v", frame, rect, want)
+			}
+			num, den := binary.BigEndian.Uint16(c.data[20:]), binary.BigEndian.Uint16(c.data[22:])
+			if delay := time.Duration(num) * time.Second / time.Duration(den); delay != wantDelays[frame] {
+				t.Errorf("fcTL of frame %d: delay %s, wanted %s", frame, delay, wantDelays[frame])
+			}
+			blend = c.data[25]
+			wantBlend := byte(apngBlendOver)
+			if frame == 0 {
+				wantBlend = apngBlendSource
+			}
+			if blend != wantBlend {
+				t.Errorf("fcTL of frame %d: blend operation %d, wanted %d", frame, blend, wantBlend)
+			}
+		case "IDAT", "fdAT":
+			rows := c.data
+			if c.chunkType == "fdAT" {
+				if seq := binary.BigEndian.Uint32(c.data); seq != sequence {
+					t.Errorf("fdAT of frame %d: sequence number %d, wanted %d", frame, seq, sequence)
+				}
+				sequence++
+				rows = c.data[4:]
+			}
+			img := decodeFrame(t, rect.Dx(), rect.Dy(), rows)
+			op := draw.Over
+			if blend == apngBlendSource {
+				op = draw.Src
+			}
+			draw.Draw(canvas, rect, img, image.Point{}, op)
+			if err := diffPixels(canvas, frames[wantFrames[frame]].Image, 1); err != nil {
+				t.Errorf("Frame %d: %v", frame, err)
+			}
+			frame++
+		}
+	}
+}
+
+func TestAPNGDelay(t *testing.T) {
+	for _, tc := range []struct {
+		d        time.Duration
+		num, den uint16
+	}{
+		{100 * time.Millisecond, 100, 1000},
+		{65535 * time.Millisecond, 65535, 1000},
+		{100 * time.Second, 10000, 100},
+		{time.Hour, 0xffff, 100},
+	} {
+		if num, den := apngDelay(tc.d); num != tc.num || den != tc.den {
+			t.Errorf("apngDelay(%s) = %d/%d, wanted %d/%d", tc.d, num, den, tc.num, tc.den)
+		}
+	}
+}
+
+// unfilterRow reverses the PNG filter of the row, as a decoder does.
+func unfilterRow(filtered, prevRow []byte) []byte {
+	const bpp = 4
+	row := make([]byte, len(filtered)-1)
+	for ii := range row {
+		var left, up, upLeft int
+		if ii >= bpp {
+			left, upLeft = int(row[ii-bpp]), int(prevRow[ii-bpp])
+		}
+		up = int(prevRow[ii])
+		var predictor int
+		switch filtered[0] {
+		case filterSub:
+			predictor = left
+		case filterUp:
+			predictor = up
+		case filterAverage:
+			predictor = (left + up) / 2
+		case filterPaeth:
+			p := left + up - upLeft
+			predictor = upLeft
+			if pa, pb, pc := abs(p-left), abs(p-up), abs(p-upLeft); pa <= pb && pa <= pc {
+				predictor = left
+			} else if pb <= pc {
+				predictor = up
+			}
+		}
+		row[ii] = filtered[ii+1] + byte(predictor)
+	}
+	return row
+}
+
+func TestFilterRow(t *testing.T) {
+	const width = 4 * 16
+	prevRow := make([]byte, width)
+	for ii := range prevRow {
+		prevRow[ii] = byte(ii*ii*37 + 11)
+	}
+	// predicted returns a row where each byte after the first pixel is the prediction of the
+	// filter, from the previous bytes, so it's the best filter.
+	predicted := func(predictor func(left, up, upLeft byte) byte) []byte {
+		row := []byte{50, 110, 170, 230}
+		for ii := 4; ii < width; ii++ {
+			row = append(row, predictor(row[ii-4], prevRow[ii], prevRow[ii-4]))
+		}
+		return row
+	}
+	testCases := []struct {
+		name   string
+		row    []byte
+		filter byte
+	}{
+		{name: "none", row: make([]byte, width), filter: filterNone},
+		{name: "sub", row: predicted(func(left, up, upLeft byte) byte { return left + 3 }), filter: filterSub},
+		{name: "up", row: append([]byte(nil), prevRow...), filter: filterUp},
+		{name: "average", row: predicted(func(left, up, upLeft byte) byte { return byte((int(left) + int(up)) / 2) }), filter: filterAverage},
+		{name: "paeth", row: predicted(paeth), filter: filterPaeth},
+	}
+	for _, tc := range testCases {
+		filtered := make([]byte, width+1)
+		filterRow(filtered, tc.row, prevRow)
+		if filtered[0] != tc.filter {
+			t.Errorf("%s: filterRow() chose filter %d, wanted %d", tc.name, filtered[0], tc.filter)
+		}
+		if got := unfilterRow(filtered, prevRow); !bytes.Equal(got, tc.row) {
+			t.Errorf("%s: unfiltered row %v, wanted %v", tc.name, got, tc.row)
+		}
+	}
+}
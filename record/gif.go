@@ -0,0 +1,124 @@
+package record
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"sort"
+	"time"
+)
+
+// Colors are grouped in buckets of 5 bits per channel, to build the palette and to map them to it.
+const numBuckets = 1 << 15
+
+func bucket(r, g, b uint8) int {
+	return int(r>>3)<<10 | int(g>>3)<<5 | int(b>>3)
+}
+
+// EncodeGIF encodes the frames as an animated GIF that loops forever. The palette, shared by all
+// frames, has the 255 most frequent colors, and index 0 is transparent: it's used for the pixels
+// that don't change from the previous frame.
+func EncodeGIF(w io.Writer, frames []Frame) error {
+	list, err := patches(frames)
+	if err != nil {
+		return err
+	}
+	palette := buildPalette(list)
+	indices := newColorIndex(palette)
+	bounds := list[0].frame.Rect
+	anim := &gif.GIF{
+		Config: image.Config{ColorModel: palette, Width: bounds.Dx(), Height: bounds.Dy()},
+	}
+	for _, p := range list {
+		img := image.NewPaletted(p.rect.Sub(bounds.Min), palette)
+		for y := p.rect.Min.Y; y < p.rect.Max.Y; y++ {
+			for x := p.rect.Min.X; x < p.rect.Max.X; x++ {
+				if p.unchanged(x, y) {
+					continue // Index 0, transparent.
+				}
+				i := p.frame.PixOffset(x, y)
+				pix := p.frame.Pix[i : i+3]
+				img.SetColorIndex(x-bounds.Min.X, y-bounds.Min.Y, indices.index(pix[0], pix[1], pix[2]))
+			}
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, gifDelay(p.delay))
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// gifDelay converts the delay to 100ths of a second. Most viewers don't honor delays shorter
+// than 2.
+func gifDelay(d time.Duration) int {
+	delay := int((d + 5*time.Millisecond) / (10 * time.Millisecond))
+	if delay < 2 {
+		delay = 2
+	}
+	return delay
+}
+
+// buildPalette returns a palette with the transparent color followed by the average colors of
+// the 255 buckets with the most pixels changed in the animation.
+func buildPalette(list []patch) color.Palette {
+	var counts [numBuckets]int
+	var sums [numBuckets][3]int
+	for _, p := range list {
+		for y := p.rect.Min.Y; y < p.rect.Max.Y; y++ {
+			for x := p.rect.Min.X; x < p.rect.Max.X; x++ {
+				i := p.frame.PixOffset(x, y)
+				r, g, b := p.frame.Pix[i], p.frame.Pix[i+1], p.frame.Pix[i+2]
+				k := bucket(r, g, b)
+				counts[k]++
+				sums[k][0] += int(r)
+				sums[k][1] += int(g)
+				sums[k][2] += int(b)
+			}
+		}
+	}
+	var used []int
+	for k, count := range counts {
+		if count > 0 {
+			used = append(used, k)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool { return counts[used[i]] > counts[used[j]] })
+	if len(used) > 255 {
+		used = used[:255]
+	}
+	palette := color.Palette{color.RGBA{}}
+	for _, k := range used {
+		n := counts[k]
+		palette = append(palette, color.RGBA{
+			R: uint8(sums[k][0] / n), G: uint8(sums[k][1] / n), B: uint8(sums[k][2] / n), A: 0xff})
+	}
+	return palette
+}
+
+// colorIndex maps colors to the nearest opaque color of the palette, caching it per bucket.
+type colorIndex struct {
+	palette color.Palette
+	cache   [numBuckets]uint8
+	cached  [numBuckets]bool
+}
+
+func newColorIndex(palette color.Palette) *colorIndex {
+	return &colorIndex{palette: palette}
+}
+
+func (c *colorIndex) index(r, g, b uint8) uint8 {
+	k := bucket(r, g, b)
+	if !c.cached[k] {
+		best, bestDist := 1, -1
+		for ii := 1; ii < len(c.palette); ii++ {
+			p := c.palette[ii].(color.RGBA)
+			dr, dg, db := int(p.R)-int(r), int(p.G)-int(g), int(p.B)-int(b)
+			if dist := dr*dr + dg*dg + db*db; bestDist < 0 || dist < bestDist {
+				best, bestDist = ii, dist
+			}
+		}
+		c.cache[k], c.cached[k] = uint8(best), true
+	}
+	return c.cache[k]
+}
@@ -0,0 +1,136 @@
+package record
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+	"time"
+)
+
+// testBounds of the test animations, not at the origin, like a region of the screen.
+var testBounds = image.Rect(10, 5, 34, 21)
+
+// testFrames returns an animation over the background: a square moving, a frame that doesn't
+// change anything (merged with the previous one by the encoders) and a single pixel changed.
+// wantFrames lists the index of the input frame shown by each encoded frame.
+func testFrames(background func(x, y int) color.RGBA) (frames []Frame, wantFrames []int) {
+	square := color.RGBA{R: 0xe0, G: 0x20, B: 0x20, A: 0xff}
+	pixel := color.RGBA{R: 0x20, G: 0x20, B: 0xe0, A: 0xff}
+	newFrame := func(squareAt image.Point) *image.RGBA {
+		img := image.NewRGBA(testBounds)
+		for y := testBounds.Min.Y; y < testBounds.Max.Y; y++ {
+			for x := testBounds.Min.X; x < testBounds.Max.X; x++ {
+				img.SetRGBA(x, y, background(x, y))
+			}
+		}
+		rect := image.Rect(0, 0, 5, 4).Add(testBounds.Min).Add(squareAt)
+		draw.Draw(img, rect, image.NewUniform(square), image.Point{}, draw.Src)
+		return img
+	}
+	moved := newFrame(image.Pt(7, 6))
+	changed := newFrame(image.Pt(7, 6))
+	changed.SetRGBA(testBounds.Max.X-1, testBounds.Max.Y-1, pixel)
+	frames = []Frame{
+		{Image: newFrame(image.Pt(1, 1)), Delay: 100 * time.Millisecond},
+		{Image: moved, Delay: 100 * time.Millisecond},
+		{Image: newFrame(image.Pt(7, 6)), Delay: 50 * time.Millisecond},
+		{Image: changed, Delay: 200 * time.Millisecond},
+	}
+	return frames, []int{0, 1, 3}
+}
+
+// diffPixels returns an error describing the first pixel of `got` that differs from `want` by
+// more than `tolerance` in any channel. `got` is at the origin, `want` at testBounds.
+func diffPixels(got image.Image, want *image.RGBA, tolerance int) error {
+	for y := testBounds.Min.Y; y < testBounds.Max.Y; y++ {
+		for x := testBounds.Min.X; x < testBounds.Max.X; x++ {
+			gr, gg, gb, ga := got.At(x-testBounds.Min.X, y-testBounds.Min.Y).RGBA()
+			wr, wg, wb, wa := want.At(x, y).RGBA()
+			for _, d := range []int{int(gr>>8) - int(wr>>8), int(gg>>8) - int(wg>>8), int(gb>>8) - int(wb>>8), int(ga>>8) - int(wa>>8)} {
+				if d > tolerance || d < -tolerance {
+					return fmt.Errorf("pixel (%d, %d) = %v, wanted %v", x, y, got.At(x-testBounds.Min.X, y-testBounds.Min.Y), want.At(x, y))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestEncodeGIF(t *testing.T) {
+	// Few colors, in different buckets, so the palette has them exactly.
+	frames, wantFrames := testFrames(func(x, y int) color.RGBA {
+		if (x/4+y/4)%2 == 0 {
+			return color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		}
+		return color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+	})
+	var buf bytes.Buffer
+	if err := EncodeGIF(&buf, frames); err != nil {
+		t.Fatalf("EncodeGIF() failed: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll() failed: %v", err)
+	}
+	if anim.Config.Width != testBounds.Dx() || anim.Config.Height != testBounds.Dy() {
+		t.Errorf("GIF size = %dx%d, wanted %dx%d", anim.Config.Width, anim.Config.Height, testBounds.Dx(), testBounds.Dy())
+	}
+	if anim.LoopCount != 0 {
+		t.Errorf("GIF LoopCount = %d, wanted 0 (forever)", anim.LoopCount)
+	}
+	if len(anim.Image) != len(wantFrames) {
+		t.Fatalf("GIF has %d frames, wanted %d", len(anim.Image), len(wantFrames))
+	}
+	wantDelays := []int{10, 15, 20} // The merged frame adds its delay to the previous one.
+
+	canvas := image.NewRGBA(image.Rect(0, 0, testBounds.Dx(), testBounds.Dy()))
+	for ii, img := range anim.Image {
+		if anim.Delay[ii] != wantDelays[ii] {
+			t.Errorf("Frame %d: delay %d, wanted %d", ii, anim.Delay[ii], wantDelays[ii])
+		}
+		if ii > 0 {
+			// Only the rectangle that changed is stored.
+			want := diffRect(frames[wantFrames[ii-1]].Image, frames[wantFrames[ii]].Image).Sub(testBounds.Min)
+			if img.Rect != want {
+				t.Errorf("Frame %d: rectangle %v, wanted %v", ii, img.Rect, want)
+			}
+		}
+		// Transparent pixels (index 0) keep the previous frame.
+		draw.Draw(canvas, img.Rect, img, img.Rect.Min, draw.Over)
+		if err := diffPixels(canvas, frames[wantFrames[ii]].Image, 0); err != nil {
+			t.Errorf("Frame %d: %v", ii, err)
+		}
+	}
+}
+
+func TestGIFDelay(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Duration
+		want int
+	}{
+		{0, 2}, {10 * time.Millisecond, 2}, {34 * time.Millisecond, 3}, {35 * time.Millisecond, 4}, {time.Second, 100},
+	} {
+		if got := gifDelay(tc.d); got != tc.want {
+			t.Errorf("gifDelay(%s) = %d, wanted %d", tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeInvalidFrames(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeGIF(&buf, nil); err == nil {
+		t.Errorf("EncodeGIF() without frames should fail")
+	}
+	if err := EncodeAPNG(&buf, nil); err == nil {
+		t.Errorf("EncodeAPNG() without frames should fail")
+	}
+	frames, _ := testFrames(func(x, y int) color.RGBA { return color.RGBA{A: 0xff} })
+	frames[1].Image = image.NewRGBA(image.Rect(0, 0, 3, 3))
+	if err := EncodeGIF(&buf, frames); err == nil {
+		t.Errorf("EncodeGIF() of frames with different sizes should fail")
+	}
+}
@@ -0,0 +1,178 @@
+// Package record captures a region of the screen as an animation, and encodes animations as
+// animated GIF or APNG (animated PNG), in pure Go.
+//
+// Both encoders store only the rectangle of each frame that changed from the previous one,
+// with the unchanged pixels inside it left transparent, which keeps recordings of user
+// interfaces small.
+package record
+
+import (
+	"bytes"
+	"errors"
+	"github.com/golang/glog"
+	"github.com/kbinani/screenshot"
+	"image"
+	"sync"
+	"time"
+)
+
+// Frame of an animation.
+type Frame struct {
+	Image *image.RGBA
+
+	// Delay is how long the frame is shown.
+	Delay time.Duration
+}
+
+// MaxBytes is the maximum memory used by the frames of a recording: the recording stops once
+// it's reached.
+var MaxBytes = 1 << 30
+
+var errNoFrames = errors.New("animation has no frames")
+
+// Recorder captures a region of the screen at a fixed frame rate, until stopped. Consecutive
+// identical frames are kept only once, with a longer Delay.
+type Recorder struct {
+	region   image.Rectangle
+	period   time.Duration
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+
+	// Set by run, and read once done is closed.
+	frames []Frame
+	err    error
+}
+
+// Start starts recording the region of the screen at `fps` frames per second. It stops after
+// `maxDuration`, if it's > 0, or when Stop is called.
+func Start(region image.Rectangle, fps int, maxDuration time.Duration) *Recorder {
+	if fps <= 0 {
+		fps = 1
+	}
+	r := &Recorder{
+		region: region,
+		period: time.Second / time.Duration(fps),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.run(maxDuration)
+	return r
+}
+
+// Stop stops the recording. It can be called more than once, and from any goroutine.
+func (r *Recorder) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// Wait waits for the recording to stop, and returns its frames.
+func (r *Recorder) Wait() ([]Frame, error) {
+	<-r.done
+	return r.frames, r.err
+}
+
+// run captures the frames until stopped, or until the maximum duration or memory is reached.
+func (r *Recorder) run(maxDuration time.Duration) {
+	defer close(r.done)
+	var timeout <-chan time.Time
+	if maxDuration > 0 {
+		timeout = time.After(maxDuration)
+	}
+	ticker := time.NewTicker(r.period)
+	defer ticker.Stop()
+
+	var lastTime time.Time // When the last frame was first captured.
+	numBytes := 0
+	for {
+		img, err := screenshot.CaptureRect(r.region)
+		if err != nil {
+			r.err = err
+			return
+		}
+		now := time.Now()
+		if last := len(r.frames) - 1; last < 0 || !bytes.Equal(r.frames[last].Image.Pix, img.Pix) {
+			if last >= 0 {
+				r.frames[last].Delay = now.Sub(lastTime)
+			}
+			r.frames = append(r.frames, Frame{Image: img, Delay: r.period})
+			lastTime = now
+			numBytes += len(img.Pix)
+			if numBytes >= MaxBytes {
+				glog.Warningf("Recording stopped: frames use more than %d bytes", MaxBytes)
+				break
+			}
+		}
+		select {
+		case <-ticker.C:
+			continue
+		case <-r.stop:
+		case <-timeout:
+		}
+		break
+	}
+	if last := len(r.frames) - 1; last >= 0 && time.Since(lastTime) > r.frames[last].Delay {
+		r.frames[last].Delay = time.Since(lastTime)
+	}
+	glog.V(1).Infof("Recorded %d frames of %+v", len(r.frames), r.region)
+}
+
+// patch is the part of a frame that changed from the previous one.
+type patch struct {
+	frame, prev *image.RGBA // prev is nil for the first frame.
+	rect        image.Rectangle
+	delay       time.Duration
+}
+
+// unchanged returns whether the pixel at (x, y) is the same as in the previous frame.
+func (p *patch) unchanged(x, y int) bool {
+	if p.prev == nil {
+		return false
+	}
+	i := p.frame.PixOffset(x, y)
+	j := p.prev.PixOffset(x, y)
+	return bytes.Equal(p.frame.Pix[i:i+4], p.prev.Pix[j:j+4])
+}
+
+// patches returns the frames as the changes from the previous ones. Frames that don't change
+// anything are merged into the previous one. All frames must have the same bounds.
+func patches(frames []Frame) ([]patch, error) {
+	if len(frames) == 0 {
+		return nil, errNoFrames
+	}
+	bounds := frames[0].Image.Rect
+	list := []patch{{frame: frames[0].Image, rect: bounds, delay: frames[0].Delay}}
+	for _, f := range frames[1:] {
+		if f.Image.Rect != bounds {
+			return nil, errors.New("animation frames have different sizes")
+		}
+		last := &list[len(list)-1]
+		rect := diffRect(last.frame, f.Image)
+		if rect.Empty() {
+			last.delay += f.Delay
+			continue
+		}
+		list = append(list, patch{frame: f.Image, prev: last.frame, rect: rect, delay: f.Delay})
+	}
+	return list, nil
+}
+
+// diffRect returns the smallest rectangle containing all the pixels that differ between the
+// two images, which must have the same bounds.
+func diffRect(a, b *image.RGBA) (rect image.Rectangle) {
+	for y := a.Rect.Min.Y; y < a.Rect.Max.Y; y++ {
+		rowA := a.Pix[a.PixOffset(a.Rect.Min.X, y) : a.PixOffset(a.Rect.Max.X-1, y)+4]
+		rowB := b.Pix[b.PixOffset(b.Rect.Min.X, y) : b.PixOffset(b.Rect.Max.X-1, y)+4]
+		if bytes.Equal(rowA, rowB) {
+			continue
+		}
+		first, last := 0, len(rowA)/4-1
+		for bytes.Equal(rowA[4*first:4*first+4], rowB[4*first:4*first+4]) {
+			first++
+		}
+		for bytes.Equal(rowA[4*last:4*last+4], rowB[4*last:4*last+4]) {
+			last--
+		}
+		rect = rect.Union(image.Rect(a.Rect.Min.X+first, y, a.Rect.Min.X+last+1, y+1))
+	}
+	return
+}
@@ -0,0 +1,186 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/history"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Preferences of the automatic saving of screenshots, and of their file names.
+const (
+	// AutoSavePreference enables saving every screenshot, and its edits when its window is closed.
+	AutoSavePreference = "AutoSave"
+
+	// AutoSaveFolderPreference is where screenshots are automatically saved, DefaultAutoSaveFolder if not set.
+	AutoSaveFolderPreference = "AutoSaveFolder"
+
+	// AutoSaveKeepPreference is the number of automatically saved screenshots kept, 0 for all.
+	AutoSaveKeepPreference = "AutoSaveKeep"
+
+	// AutoSaveKeepDaysPreference is the number of days automatically saved screenshots are kept, 0 for ever.
+	AutoSaveKeepDaysPreference = "AutoSaveKeepDays"
+
+	// FileNameTemplatePreference is the template of the file names, see ExpandFileNameTemplate.
+	FileNameTemplatePreference = "FileNameTemplate"
+
+	// sequencePreference holds the last sequence number given to a screenshot.
+	sequencePreference = "Sequence"
+)
+
+// DefaultFileNameTemplate is used if FileNameTemplatePreference is not set.
+var DefaultFileNameTemplate = "Screenshot {date} {time} {title}"
+
+// DefaultAutoSaveFolder returns the folder where screenshots are automatically saved, if
+// AutoSaveFolderPreference is not set.
+func DefaultAutoSaveFolder() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "GoShot"
+	}
+	return filepath.Join(home, "Pictures", "GoShot")
+}
+
+// FileNameTemplate returns the template of the file names set in the preferences.
+func FileNameTemplate(prefs fyne.Preferences) string {
+	return prefs.StringWithFallback(FileNameTemplatePreference, DefaultFileNameTemplate)
+}
+
+// ExpandFileNameTemplate returns the file name (without extension) for the screenshot, with
+// the placeholders of the template replaced:
+//
+//   - {date}: date of the screenshot, e.g. "2021-01-31".
+//   - {time}: time of the screenshot, e.g. "15-04-05".
+//   - {display}: index of the display captured.
+//   - {seq}: sequence number of the screenshot, e.g. "0042".
+//   - {title}: title of the window captured, empty if not a window.
+//
+// Characters not allowed in file names are replaced by "_".
+func (gs *GoShot) ExpandFileNameTemplate(template string) string {
+	name := strings.NewReplacer(
+		"{date}", gs.ScreenshotTime.Format("2006-01-02"),
+		"{time}", gs.ScreenshotTime.Format("15-04-05"),
+		"{display}", fmt.Sprintf("%d", gs.Display),
+		"{seq}", fmt.Sprintf("%04d", gs.Sequence),
+		"{title}", gs.Title,
+	).Replace(template)
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		name = "Screenshot"
+	}
+	return name
+}
+
+// resetAutoSave makes the next automatic saving go to a new file, e.g. for a new screenshot.
+func (gs *GoShot) resetAutoSave() {
+	gs.autoSaveMu.Lock()
+	gs.autoSavePath = ""
+	gs.edited = false
+	gs.autoSaveMu.Unlock()
+}
+
+// setEdited records that the image was cropped or transformed, so it's saved again with its edits.
+func (gs *GoShot) setEdited() {
+	gs.autoSaveMu.Lock()
+	gs.edited = true
+	gs.autoSaveMu.Unlock()
+}
+
+// nextSequence returns the next sequence number for a screenshot.
+func nextSequence(prefs fyne.Preferences) int {
+	seq := prefs.Int(sequencePreference) + 1
+	prefs.SetInt(sequencePreference, seq)
+	return seq
+}
+
+// autoSave saves the image of the screenshot, if AutoSavePreference is set. The first time a
+// new file is created in the AutoSaveFolderPreference, and later calls overwrite it. If `initial`
+// is set, the image is only saved if it wasn't yet: it may run after the edits were saved.
+// The retention rules are applied to the older automatically saved files.
+func (gs *GoShot) autoSave(img image.Image, initial bool) {
+	prefs := gs.App.Preferences()
+	if !prefs.Bool(AutoSavePreference) {
+		return
+	}
+	gs.autoSaveMu.Lock()
+	defer gs.autoSaveMu.Unlock()
+	if initial && gs.autoSavePath != "" {
+		return
+	}
+	if gs.autoSavePath == "" {
+		folder := prefs.String(AutoSaveFolderPreference)
+		if folder == "" {
+			folder = DefaultAutoSaveFolder()
+		}
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			glog.Errorf("Failed to create folder for automatic saving %q: %v", folder, err)
+			return
+		}
+		gs.autoSavePath = uniquePath(folder, gs.ExpandFileNameTemplate(FileNameTemplate(prefs)), ".png")
+	}
+	if err := writePNG(gs.autoSavePath, img); err != nil {
+		glog.Errorf("Failed to automatically save screenshot: %v", err)
+		return
+	}
+	glog.V(1).Infof("Screenshot automatically saved to %q", gs.autoSavePath)
+	err := history.AddAutoSaved(history.Capture{
+		Time:   gs.ScreenshotTime,
+		Path:   gs.autoSavePath,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}, prefs.Int(AutoSaveKeepPreference), time.Duration(prefs.Int(AutoSaveKeepDaysPreference))*24*time.Hour)
+	if err != nil {
+		glog.Errorf("Failed to record automatically saved screenshot: %v", err)
+	}
+}
+
+// autoSaveEdits saves the screenshot again, if it was edited: annotated, cropped or transformed.
+func (gs *GoShot) autoSaveEdits() {
+	gs.autoSaveMu.Lock()
+	edited := gs.edited
+	gs.autoSaveMu.Unlock()
+	if !edited && len(gs.Filters) == 0 {
+		return
+	}
+	gs.autoSave(gs.Screenshot, false)
+}
+
+// uniquePath returns the path for a new file in the folder with the given name and extension,
+// adding a number to the name if a file with the same name exists.
+func uniquePath(folder, name, ext string) string {
+	filePath := filepath.Join(folder, name+ext)
+	for ii := 2; ; ii++ {
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			return filePath
+		}
+		filePath = filepath.Join(folder, fmt.Sprintf("%s (%d)%s", name, ii, ext))
+	}
+}
+
+// writePNG writes the image to the file, encoded as PNG.
+func writePNG(filePath string, img image.Image) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", filePath, err)
+	}
+	err = png.Encode(f, img)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %q: %w", filePath, err)
+	}
+	return nil
+}
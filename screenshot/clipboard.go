@@ -0,0 +1,123 @@
+package screenshot
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/history"
+	"image"
+)
+
+// ClipboardHistorySizePreference is the number of images kept in the clipboard history.
+// Set to 0 to disable it.
+const ClipboardHistorySizePreference = "ClipboardHistorySize"
+
+// PasteImageFromClipboard replaces the image being edited with the one in the clipboard.
+// If there are edits, it asks for confirmation first, since they are lost.
+func (gs *GoShot) PasteImageFromClipboard() {
+	glog.V(2).Info("GoShot.PasteImageFromClipboard")
+	img, err := clipboard.PasteImage()
+	if err != nil {
+		if errors.Is(err, clipboard.ErrNotAvailable) {
+			gs.status.SetText("No image in the clipboard.")
+			return
+		}
+		glog.Errorf("Failed to paste image from clipboard: %v", err)
+		gs.status.SetText(fmt.Sprintf("Failed to paste image from clipboard: %v", err))
+		return
+	}
+	gs.confirmReplaceScreenshot(img, "Image pasted from clipboard.")
+}
+
+// confirmReplaceScreenshot replaces the image being edited, asking for confirmation if
+// there are edits that would be lost.
+func (gs *GoShot) confirmReplaceScreenshot(img image.Image, msg string) {
+	replace := func() {
+		gs.ReplaceScreenshot(img)
+		gs.status.SetText(fmt.Sprintf("%s Image size: %d x %d pixels.", msg, img.Bounds().Dx(), img.Bounds().Dy()))
+	}
+	if len(gs.Filters) == 0 {
+		replace()
+		return
+	}
+	dialog.ShowConfirm("Replace image",
+		fmt.Sprintf("Replace the image being edited?\nThe %d edits made will be lost.", len(gs.Filters)),
+		func(confirm bool) {
+			if confirm {
+				replace()
+			}
+		}, gs.Win)
+}
+
+// ShowClipboardHistory opens a dialog with the images previously copied to the clipboard, from
+// where they can be copied again, or opened for edit.
+func (gs *GoShot) ShowClipboardHistory() {
+	entries := clipboard.History()
+	if len(entries) == 0 {
+		if clipboard.HistorySize <= 0 {
+			gs.status.SetText("Clipboard history is disabled.")
+		} else {
+			gs.status.SetText("No images copied to the clipboard yet.")
+		}
+		return
+	}
+
+	var historyDialog dialog.Dialog
+	list := widget.NewList(
+		func() int { return len(entries) },
+		func() fyne.CanvasObject {
+			thumbnail := canvas.NewImageFromImage(nil)
+			thumbnail.FillMode = canvas.ImageFillContain
+			thumbnail.SetMinSize(thumbnailListSize)
+			return container.NewHBox(thumbnail, widget.NewLabel(""), layout.NewSpacer(),
+				widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), nil),
+				widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), nil))
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			if id < 0 || id >= len(entries) {
+				return
+			}
+			entry := entries[id]
+			objects := item.(*fyne.Container).Objects
+			thumbnail := objects[0].(*canvas.Image)
+			thumbnail.Image = history.Thumbnail(entry.Image, int(thumbnailListSize.Width))
+			thumbnail.Refresh()
+			bounds := entry.Image.Bounds()
+			objects[1].(*widget.Label).SetText(fmt.Sprintf("Copied @ %s, %d x %d pixels",
+				entry.Time.Format("15:04:05"), bounds.Dx(), bounds.Dy()))
+			objects[3].(*widget.Button).OnTapped = func() {
+				historyDialog.Hide()
+				if err := clipboard.CopyImage(entry.Image); err != nil {
+					glog.Errorf("Failed to copy to clipboard: %s", err)
+					gs.status.SetText(fmt.Sprintf("Failed to copy to clipboard: %s", err))
+					return
+				}
+				gs.status.SetText(fmt.Sprintf("Image copied @ %s copied again to clipboard.",
+					entry.Time.Format("15:04:05")))
+			}
+			objects[4].(*widget.Button).OnTapped = func() {
+				historyDialog.Hide()
+				gs.confirmReplaceScreenshot(entry.Image, "Image from clipboard history.")
+			}
+		})
+	clearButton := widget.NewButtonWithIcon("Clear history", theme.DeleteIcon(), func() {
+		clipboard.ClearHistory()
+		historyDialog.Hide()
+		gs.status.SetText("Clipboard history cleared.")
+	})
+	historyDialog = dialog.NewCustom("Clipboard history", "Close",
+		container.NewBorder(nil, container.NewHBox(layout.NewSpacer(), clearButton), nil, nil, list), gs.Win)
+	size := gs.Win.Canvas().Size()
+	size.Width *= 0.90
+	size.Height *= 0.90
+	historyDialog.Resize(size)
+	historyDialog.Show()
+}
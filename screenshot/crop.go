@@ -0,0 +1,444 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// cropRatios are the aspect ratios (width / height) offered by the crop tool, 0 for free.
+var cropRatios = []struct {
+	name  string
+	ratio float64
+}{
+	{"Free", 0},
+	{"16:9", 16.0 / 9.0},
+	{"4:3", 4.0 / 3.0},
+	{"1:1", 1},
+}
+
+// cropHandle is what is being dragged with the crop tool.
+type cropHandle int
+
+const (
+	cropNew  cropHandle = iota // A new rectangle, from where the drag started.
+	cropMove                   // The whole rectangle.
+	cropTopLeft
+	cropTop
+	cropTopRight
+	cropRight
+	cropBottomRight
+	cropBottom
+	cropBottomLeft
+	cropLeft
+)
+
+// cropHandleSize is the size of the drag handles, in pixels.
+const cropHandleSize = 9
+
+// cropTool is the state of the crop tool: while it's active (the Crop operation), the whole
+// original screenshot is shown, with the rectangle being selected over it.
+type cropTool struct {
+	active   bool
+	rect     image.Rectangle // Selected rectangle, in the coordinates of the OriginalScreenshot.
+	previous image.Rectangle // CropRect when the tool started, restored if cancelled.
+	ratio    float64         // Aspect ratio (width / height) enforced, 0 if free.
+
+	// Drag in progress: what is dragged, from where and the rectangle when it started.
+	handle   cropHandle
+	dragFrom image.Point
+	dragRect image.Rectangle
+
+	// UI elements: the panel is only visible while the tool is active. updating is set while
+	// the entries are updated from rect, so their changes are ignored.
+	panel       *fyne.Container
+	ratioSelect *widget.Select
+	entries     [4]*widget.Entry // X, Y, W and H.
+	updating    bool
+}
+
+// buildCropPanel creates the panel with the options of the crop tool, shown in the toolbar while
+// it's active.
+func (gs *GoShot) buildCropPanel() fyne.CanvasObject {
+	c := &gs.crop
+	names := make([]string, len(cropRatios))
+	for ii, r := range cropRatios {
+		names[ii] = r.name
+	}
+	c.ratioSelect = widget.NewSelect(names, func(name string) {
+		for _, r := range cropRatios {
+			if r.name == name {
+				c.ratio = r.ratio
+			}
+		}
+		if c.ratio > 0 && !c.rect.Empty() {
+			gs.setCropRect(fitRatioWithin(c.rect, gs.OriginalScreenshot.Rect, c.ratio, false, false, false))
+		}
+	})
+	c.ratioSelect.SetSelected(cropRatios[0].name)
+
+	grid := container.NewGridWithColumns(4)
+	for ii, label := range []string{"X", "Y", "W", "H"} {
+		entry := &widget.Entry{Validator: validation.NewRegexp(`^\d+$`, "Must be a number")}
+		idx := ii
+		entry.OnChanged = func(string) { gs.cropEntriesChanged(idx) }
+		entry.OnSubmitted = func(string) { gs.ApplyCrop() }
+		c.entries[ii] = entry
+		grid.Add(widget.NewLabel(label))
+		grid.Add(entry)
+	}
+	c.panel = container.NewVBox(
+		container.NewHBox(widget.NewLabel("Aspect ratio:"), c.ratioSelect),
+		grid,
+		container.NewHBox(
+			widget.NewButtonWithIcon("Apply (Enter)", theme.ConfirmIcon(), func() { gs.ApplyCrop() }),
+			widget.NewButtonWithIcon("Cancel (Esc)", theme.CancelIcon(), func() { gs.viewPort.SetOp(NoOp) }),
+		),
+	)
+	c.panel.Hide()
+	return c.panel
+}
+
+// StartCrop starts the crop tool: the whole original screenshot is shown, and the current crop
+// can be adjusted, or a new one selected. If `fresh` is set, it starts with no selection.
+func (gs *GoShot) StartCrop(fresh bool) {
+	vp := gs.viewPort
+	if gs.crop.active {
+		vp.SetOp(NoOp) // Cancel previous one.
+	}
+	c := &gs.crop
+	c.previous = gs.CropRect
+	c.rect = gs.CropRect
+	if fresh {
+		c.rect = image.Rectangle{}
+	}
+
+	// Show the original screenshot, keeping the view on the same pixels.
+	vp.viewX += gs.CropRect.Min.X
+	vp.viewY += gs.CropRect.Min.Y
+	gs.CropRect = gs.OriginalScreenshot.Rect
+	gs.ApplyFilters(true)
+	vp.SetOp(Crop)
+	c.active = true
+	c.panel.Show()
+	gs.updateCropEntries()
+	vp.postCrop()
+	gs.status.SetText("Crop: drag to select the region, or drag its handles to adjust it. Enter applies it, Esc cancels.")
+}
+
+// ApplyCrop crops the screenshot to the rectangle selected with the crop tool, and ends it.
+func (gs *GoShot) ApplyCrop() {
+	c := &gs.crop
+	if !c.active {
+		return
+	}
+	rect := c.rect.Intersect(gs.OriginalScreenshot.Rect)
+	if rect.Empty() {
+		gs.status.SetText("Crop: drag to select the region first, or press Esc to cancel.")
+		return
+	}
+	gs.endCrop(rect)
+	if after := gs.afterRegion; after != nil {
+		gs.afterRegion = nil
+		after()
+	}
+}
+
+// cancelCrop ends the crop tool, restoring the previous crop. It's called by ViewPort.SetOp when
+// the operation changes.
+func (gs *GoShot) cancelCrop() {
+	if !gs.crop.active {
+		return
+	}
+	gs.afterRegion = nil
+	gs.endCrop(gs.crop.previous)
+	gs.status.SetText("Crop cancelled.")
+}
+
+// endCrop ends the crop tool, setting the CropRect.
+func (gs *GoShot) endCrop(rect image.Rectangle) {
+	vp := gs.viewPort
+	gs.crop.active = false
+	gs.crop.panel.Hide()
+	vp.SetOp(NoOp)
+	vp.viewX -= rect.Min.X - gs.CropRect.Min.X
+	vp.viewY -= rect.Min.Y - gs.CropRect.Min.Y
+	if rect != gs.crop.previous {
+		gs.setEdited()
+	}
+	gs.CropRect = rect
+	gs.ApplyFilters(true)
+	vp.postCrop()
+}
+
+// setCropRect sets the rectangle selected with the crop tool, limited to the screenshot.
+func (gs *GoShot) setCropRect(rect image.Rectangle) {
+	gs.crop.rect = rect.Canon().Intersect(gs.OriginalScreenshot.Rect)
+	gs.updateCropEntries()
+	gs.viewPort.Refresh()
+}
+
+// updateCropEntries updates the X, Y, W and H entries, and the status bar, with the selected rectangle.
+func (gs *GoShot) updateCropEntries() {
+	c := &gs.crop
+	c.updating = true
+	defer func() { c.updating = false }()
+	values := []int{c.rect.Min.X, c.rect.Min.Y, c.rect.Dx(), c.rect.Dy()}
+	for ii, entry := range c.entries {
+		entry.SetText(strconv.Itoa(values[ii]))
+	}
+	if !c.rect.Empty() {
+		gs.status.SetText(fmt.Sprintf("Crop: %d x %d pixels at (%d, %d).", c.rect.Dx(), c.rect.Dy(), c.rect.Min.X, c.rect.Min.Y))
+	}
+}
+
+// cropEntriesChanged updates the selected rectangle from the X, Y, W and H entries, `changed` being
+// the index of the one edited. With an aspect ratio, the height follows the width, or the width
+// follows the height if it's the one edited, and the other entries are updated.
+func (gs *GoShot) cropEntriesChanged(changed int) {
+	c := &gs.crop
+	if c.updating || !c.active {
+		return
+	}
+	var values [4]int
+	for ii, entry := range c.entries {
+		v, err := strconv.Atoi(entry.Text)
+		if err != nil {
+			return
+		}
+		values[ii] = v
+	}
+	r := image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3])
+	if c.ratio <= 0 {
+		c.rect = r.Intersect(gs.OriginalScreenshot.Rect)
+		gs.viewPort.Refresh()
+		return
+	}
+	c.rect = fitRatioWithin(r, gs.OriginalScreenshot.Rect, c.ratio, changed == 3, false, false)
+	c.updating = true
+	values = [4]int{c.rect.Min.X, c.rect.Min.Y, c.rect.Dx(), c.rect.Dy()}
+	for ii, entry := range c.entries {
+		if ii != changed {
+			entry.SetText(strconv.Itoa(values[ii]))
+		}
+	}
+	c.updating = false
+	gs.viewPort.Refresh()
+}
+
+// startCropDrag starts dragging with the crop tool from `pos`, in the coordinates of the
+// OriginalScreenshot, and `pixel` in the ViewPort.
+func (gs *GoShot) startCropDrag(pos, pixel image.Point) {
+	c := &gs.crop
+	c.handle = gs.viewPort.cropHandleAt(pixel)
+	c.dragFrom = pos
+	c.dragRect = c.rect
+}
+
+// dragCrop updates the selected rectangle while dragging to `pos`, in the coordinates of the
+// OriginalScreenshot.
+func (gs *GoShot) dragCrop(pos image.Point) {
+	c := &gs.crop
+	delta := pos.Sub(c.dragFrom)
+	r := c.dragRect
+	top, left := false, false
+	switch c.handle {
+	case cropNew:
+		r = image.Rectangle{Min: c.dragFrom, Max: pos}
+		top, left = pos.Y < c.dragFrom.Y, pos.X < c.dragFrom.X
+	case cropMove:
+		// Move, but keep it within the screenshot.
+		bounds := gs.OriginalScreenshot.Rect
+		r = r.Add(delta)
+		shift := image.Point{
+			X: clamp(r.Min.X, bounds.Min.X, bounds.Max.X-r.Dx()) - r.Min.X,
+			Y: clamp(r.Min.Y, bounds.Min.Y, bounds.Max.Y-r.Dy()) - r.Min.Y,
+		}
+		gs.setCropRect(r.Add(shift))
+		return
+	case cropTopLeft:
+		r.Min = r.Min.Add(delta)
+		top, left = true, true
+	case cropTop:
+		r.Min.Y += delta.Y
+		top = true
+	case cropTopRight:
+		r.Min.Y += delta.Y
+		r.Max.X += delta.X
+		top = true
+	case cropRight:
+		r.Max.X += delta.X
+	case cropBottomRight:
+		r.Max = r.Max.Add(delta)
+	case cropBottom:
+		r.Max.Y += delta.Y
+	case cropBottomLeft:
+		r.Min.X += delta.X
+		r.Max.Y += delta.Y
+		left = true
+	case cropLeft:
+		r.Min.X += delta.X
+		left = true
+	}
+	r = r.Canon()
+	if c.ratio > 0 {
+		byHeight := c.handle == cropTop || c.handle == cropBottom
+		r = fitRatioWithin(r, gs.OriginalScreenshot.Rect, c.ratio, byHeight, top, left)
+	}
+	gs.setCropRect(r)
+}
+
+// fitRatio adjusts the rectangle to the aspect ratio (width / height), changing its height, or its
+// width if `byHeight` is set. The edges moved are the bottom and right ones, or the top one if
+// `top` is set and the left one if `left` is set.
+func fitRatio(r image.Rectangle, ratio float64, byHeight, top, left bool) image.Rectangle {
+	if byHeight {
+		w := int(math.Round(float64(r.Dy()) * ratio))
+		if left {
+			r.Min.X = r.Max.X - w
+		} else {
+			r.Max.X = r.Min.X + w
+		}
+		return r
+	}
+	h := int(math.Round(float64(r.Dx()) / ratio))
+	if top {
+		r.Min.Y = r.Max.Y - h
+	} else {
+		r.Max.Y = r.Min.Y + h
+	}
+	return r
+}
+
+// fitRatioWithin limits the rectangle to the bounds, and then adjusts it to the aspect ratio like
+// fitRatio. If the adjusted side doesn't fit in the bounds, it's limited to them, and the other
+// side is adjusted to it instead, so the rectangle keeps the ratio within the bounds.
+func fitRatioWithin(r, bounds image.Rectangle, ratio float64, byHeight, top, left bool) image.Rectangle {
+	r = fitRatio(r.Intersect(bounds), ratio, byHeight, top, left)
+	if inside := r.Intersect(bounds); inside != r {
+		r = fitRatio(inside, ratio, !byHeight, top, left).Intersect(bounds) // Intersect for rounding.
+	}
+	return r
+}
+
+// clamp returns the value limited to the range [from, to].
+func clamp(value, from, to int) int {
+	if value > to {
+		value = to
+	}
+	if value < from {
+		value = from
+	}
+	return value
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// cropRectPixels returns the rectangle selected with the crop tool in ViewPort pixels.
+func (vp *ViewPort) cropRectPixels() image.Rectangle {
+	r := vp.gs.crop.rect.Sub(vp.gs.CropRect.Min)
+	zoom := vp.zoom()
+	toPixel := func(v, view int) int { return int(math.Round(float64(v-view) / zoom)) }
+	return image.Rect(toPixel(r.Min.X, vp.viewX), toPixel(r.Min.Y, vp.viewY),
+		toPixel(r.Max.X, vp.viewX), toPixel(r.Max.Y, vp.viewY))
+}
+
+// cropHandlePoint returns the center of the handle of the rectangle.
+func cropHandlePoint(r image.Rectangle, handle cropHandle) image.Point {
+	center := r.Min.Add(r.Max).Div(2)
+	switch handle {
+	case cropTopLeft:
+		return r.Min
+	case cropTop:
+		return image.Pt(center.X, r.Min.Y)
+	case cropTopRight:
+		return image.Pt(r.Max.X, r.Min.Y)
+	case cropRight:
+		return image.Pt(r.Max.X, center.Y)
+	case cropBottomRight:
+		return r.Max
+	case cropBottom:
+		return image.Pt(center.X, r.Max.Y)
+	case cropBottomLeft:
+		return image.Pt(r.Min.X, r.Max.Y)
+	case cropLeft:
+		return image.Pt(r.Min.X, center.Y)
+	}
+	return center
+}
+
+// cropHandleAt returns what is dragged from the given pixel of the ViewPort: one of the handles
+// of the selected rectangle, the whole rectangle if inside it, or a new one.
+func (vp *ViewPort) cropHandleAt(pixel image.Point) cropHandle {
+	r := vp.cropRectPixels()
+	if vp.gs.crop.rect.Empty() {
+		return cropNew
+	}
+	for handle := cropTopLeft; handle <= cropLeft; handle++ {
+		p := cropHandlePoint(r, handle)
+		if abs(pixel.X-p.X) <= cropHandleSize && abs(pixel.Y-p.Y) <= cropHandleSize {
+			return handle
+		}
+	}
+	if pixel.In(r) {
+		return cropMove
+	}
+	return cropNew
+}
+
+// drawCropOverlay draws the crop tool over the rendered cache: it darkens the area outside the
+// selected rectangle, and draws its border and handles.
+func (vp *ViewPort) drawCropOverlay() {
+	img := vp.cache
+	bounds := img.Rect
+	r := vp.cropRectPixels()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !image.Pt(x, y).In(r) {
+				pix := img.Pix[img.PixOffset(x, y):]
+				pix[0], pix[1], pix[2] = pix[0]/2, pix[1]/2, pix[2]/2
+			}
+		}
+	}
+	if vp.gs.crop.rect.Empty() {
+		return
+	}
+	fill := func(rect image.Rectangle, c color.RGBA) {
+		rect = rect.Intersect(bounds)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	// Border, just outside the rectangle.
+	border := r.Inset(-1)
+	fill(image.Rect(border.Min.X, border.Min.Y, border.Max.X, r.Min.Y), cropBorderColor)
+	fill(image.Rect(border.Min.X, r.Max.Y, border.Max.X, border.Max.Y), cropBorderColor)
+	fill(image.Rect(border.Min.X, r.Min.Y, r.Min.X, r.Max.Y), cropBorderColor)
+	fill(image.Rect(r.Max.X, r.Min.Y, border.Max.X, r.Max.Y), cropBorderColor)
+	for handle := cropTopLeft; handle <= cropLeft; handle++ {
+		p := cropHandlePoint(r, handle)
+		square := image.Rect(p.X, p.Y, p.X+1, p.Y+1).Inset(-cropHandleSize / 2)
+		fill(square.Inset(-1), cropHandleBorderColor)
+		fill(square, cropBorderColor)
+	}
+}
+
+// Colors of the crop tool.
+var (
+	cropBorderColor       = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	cropHandleBorderColor = color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+)
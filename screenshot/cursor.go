@@ -0,0 +1,97 @@
+package screenshot
+
+import (
+	"fyne.io/fyne/v2/canvas"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/filters"
+	"github.com/janpfeifer/goshot/xwindow"
+	"image"
+)
+
+// ShowCursorPreference shows the mouse cursor in new screenshots, false by default. The cursor
+// is captured anyway (X11 only), and can be shown, hidden or moved in the edit window.
+const ShowCursorPreference = "ShowCursor"
+
+// captureCursor returns the mouse cursor, or nil if it can't be captured.
+func captureCursor() *xwindow.CursorImage {
+	cursor, err := xwindow.Cursor()
+	if err != nil {
+		glog.V(1).Infof("Mouse cursor not captured: %v", err)
+		return nil
+	}
+	return &cursor
+}
+
+// setCursor keeps the mouse cursor captured with the screenshot, if not nil, and shows it if
+// ShowCursorPreference is set. `origin` is the screen position of the top-left corner of the
+// screenshot.
+func (gs *GoShot) setCursor(cursor *xwindow.CursorImage, origin image.Point) {
+	gs.Cursor, gs.cursorFilter = nil, nil
+	if cursor == nil {
+		return
+	}
+	gs.Cursor = cursor.Image
+	gs.CursorHotspot = cursor.Hotspot
+	gs.CursorPosition = cursor.Position.Sub(origin)
+	if gs.App.Preferences().Bool(ShowCursorPreference) && gs.CursorPosition.In(gs.OriginalScreenshot.Rect) {
+		gs.ShowCursor(true)
+	}
+}
+
+// ShowCursor shows or hides the mouse cursor captured with the screenshot, drawn by a filter at
+// CursorPosition.
+func (gs *GoShot) ShowCursor(show bool) {
+	if gs.Cursor == nil || show == (gs.cursorFilter != nil) {
+		return
+	}
+	if show {
+		gs.cursorFilter = filters.NewImage(gs.Cursor, gs.CursorPosition.Sub(gs.CursorHotspot))
+		gs.Filters = append(gs.Filters, gs.cursorFilter)
+	} else {
+		for ii, filter := range gs.Filters {
+			if filter == gs.cursorFilter {
+				gs.Filters = append(gs.Filters[:ii], gs.Filters[ii+1:]...)
+				break
+			}
+		}
+		gs.cursorFilter = nil
+	}
+	gs.updateCursorCheck()
+	gs.ApplyFilters(true)
+}
+
+// updateCursorCheck updates the toolbar check that shows the mouse cursor: it's disabled if no
+// cursor was captured.
+func (gs *GoShot) updateCursorCheck() {
+	if gs.cursorCheck == nil {
+		return
+	}
+	gs.cursorCheck.SetChecked(gs.cursorFilter != nil)
+	if gs.Cursor == nil {
+		gs.cursorCheck.Disable()
+	} else {
+		gs.cursorCheck.Enable()
+	}
+}
+
+// MoveCursor moves the mouse cursor, so that it points to `position` (in the coordinates of
+// the OriginalScreenshot), and shows it.
+func (gs *GoShot) MoveCursor(position image.Point) {
+	if gs.Cursor == nil {
+		return
+	}
+	gs.CursorPosition = position
+	if gs.cursorFilter == nil {
+		gs.ShowCursor(true)
+		return
+	}
+	gs.cursorFilter.Position = position.Sub(gs.CursorHotspot)
+	gs.ApplyFilters(true)
+}
+
+// cursorImage returns the image shown by the ViewPort for the MoveCursor operation.
+func (gs *GoShot) cursorImage() *canvas.Image {
+	img := canvas.NewImageFromImage(gs.Cursor)
+	img.FillMode = canvas.ImageFillContain
+	return img
+}
@@ -0,0 +1,139 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/palette"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EyedropperSizePreference is the size N of the NxN square of pixels averaged by the eyedropper
+// and the pixel inspector, 1 (a single pixel) if not set.
+const EyedropperSizePreference = "EyedropperSize"
+
+// eyedropperSizes are the sizes offered for the eyedropper.
+var eyedropperSizes = []int{1, 3, 5, 9}
+
+// eyedropperSize returns the size of the square of pixels sampled, see EyedropperSizePreference.
+func (gs *GoShot) eyedropperSize() int {
+	size := gs.App.Preferences().IntWithFallback(EyedropperSizePreference, 1)
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// formatEyedropperSize formats the size as shown in the toolbar, e.g. "3x3".
+func formatEyedropperSize(size int) string {
+	return fmt.Sprintf("%dx%d", size, size)
+}
+
+// SampleColor returns the average color of the NxN square of pixels of the original screenshot
+// (without the edits) centered at `p`, and false if it is outside the screenshot.
+func (gs *GoShot) SampleColor(p image.Point, size int) (color.NRGBA, bool) {
+	img := gs.OriginalScreenshot
+	if !p.In(img.Rect) {
+		return color.NRGBA{}, false
+	}
+	half := size / 2
+	rect := image.Rect(p.X-half, p.Y-half, p.X-half+size, p.Y-half+size).Intersect(img.Rect)
+	var r, g, b, a uint32
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			r, g, b, a = r+uint32(c.R), g+uint32(c.G), b+uint32(c.B), a+uint32(c.A)
+		}
+	}
+	n := uint32(rect.Dx() * rect.Dy())
+	avg := color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)}
+	return color.NRGBAModel.Convert(avg).(color.NRGBA), true
+}
+
+// PickColor sets the color of the slot to the color sampled at `p`, in the coordinates of the
+// original screenshot.
+func (gs *GoShot) PickColor(p image.Point, slot colorSlot) {
+	c, ok := gs.SampleColor(p, gs.eyedropperSize())
+	if !ok {
+		gs.status.SetText("Eyedropper: click inside the image to pick a color.")
+		return
+	}
+	glog.V(2).Infof("PickColor(%v, slot=%d): %s", p, slot, palette.FormatHex(c))
+	gs.inspectedColor, gs.inspectedValid = c, true
+	gs.SetSlotColor(slot, c)
+	gs.status.SetText(fmt.Sprintf("%s color set to %s.", colorSlotNames[slot], palette.FormatHex(c)))
+}
+
+// inspect shows in the status bar the coordinates and the color of the pixel at the ViewPort
+// position `pos`, or clears it if the position is outside the image.
+func (vp *ViewPort) inspect(pos fyne.Position) {
+	gs := vp.gs
+	if gs.inspector == nil {
+		return
+	}
+	x, y := vp.screenshotPos(pos)
+	p := image.Pt(x, y)
+	absolute := p.Add(gs.CropRect.Min)
+	c, ok := gs.SampleColor(absolute, gs.eyedropperSize())
+	if !ok || !absolute.In(gs.CropRect) {
+		gs.inspector.SetText("")
+		return
+	}
+	gs.inspectedColor, gs.inspectedValid = c, true
+	h, s, l := rgbToHSL(c)
+	gs.inspector.SetText(fmt.Sprintf("(%d, %d) original (%d, %d)  %s  rgb(%d, %d, %d)  hsl(%.0f, %.0f%%, %.0f%%)",
+		p.X, p.Y, absolute.X, absolute.Y, palette.FormatHex(c), c.R, c.G, c.B, h, 100*s, 100*l))
+}
+
+// CopyInspectedColor copies to the clipboard the hex code of the last color inspected or picked.
+func (gs *GoShot) CopyInspectedColor() {
+	if !gs.inspectedValid {
+		gs.status.SetText("No color to copy: move the mouse over the image first.")
+		return
+	}
+	hex := palette.FormatHex(gs.inspectedColor)
+	if err := clipboard.CopyText(hex); err != nil {
+		gs.status.SetText(fmt.Sprintf("Failed to copy color to clipboard: %s", err))
+		return
+	}
+	gs.status.SetText(fmt.Sprintf("Color %s copied to clipboard.", hex))
+}
+
+// rgbToHSL converts the color to hue (in degrees), saturation and lightness (from 0 to 1).
+func rgbToHSL(c color.NRGBA) (h, s, l float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC, minC := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l = (maxC + minC) / 2
+	delta := maxC - minC
+	if delta == 0 {
+		return 0, 0, l
+	}
+	s = delta / (1 - math.Abs(2*l-1))
+	switch maxC {
+	case r:
+		h = math.Mod((g-b)/delta, 6)
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return
+}
+
+// parseEyedropperSize parses the size as shown in the toolbar, e.g. "3x3".
+func parseEyedropperSize(text string) (int, error) {
+	size, err := strconv.Atoi(strings.SplitN(text, "x", 2)[0])
+	if err != nil || size < 1 {
+		return 0, fmt.Errorf("invalid eyedropper size %q", text)
+	}
+	return size, nil
+}
@@ -0,0 +1,307 @@
+package screenshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/frame"
+	"github.com/janpfeifer/goshot/palette"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// Preferences of the frame added to exported images.
+const (
+	// FramePresetsPreference holds the frame presets, encoded as JSON. frame.DefaultPresets are
+	// used if not set.
+	FramePresetsPreference = "FramePresets"
+
+	// FramePresetPreference is the name of the frame preset applied when saving, copying or sharing
+	// the screenshot, empty for none.
+	FramePresetPreference = "FramePreset"
+)
+
+// noFrame is the name shown for no frame preset.
+const noFrame = "None"
+
+// FramePresets returns the frame presets configured in the preferences.
+func FramePresets(prefs fyne.Preferences) []frame.Preset {
+	defaults := append([]frame.Preset(nil), frame.DefaultPresets...) // Copy, since it may be edited.
+	value := prefs.String(FramePresetsPreference)
+	if value == "" {
+		return defaults
+	}
+	var presets []frame.Preset
+	if err := json.Unmarshal([]byte(value), &presets); err != nil {
+		glog.Errorf("Invalid frame presets in preferences, using the default ones: %v", err)
+		return defaults
+	}
+	return presets
+}
+
+// SetFramePresets saves the frame presets in the preferences.
+func SetFramePresets(prefs fyne.Preferences, presets []frame.Preset) {
+	value, err := json.Marshal(presets)
+	if err != nil {
+		glog.Errorf("Failed to encode frame presets: %v", err)
+		return
+	}
+	prefs.SetString(FramePresetsPreference, string(value))
+}
+
+// framePreset returns the frame preset selected, or nil if none.
+func (gs *GoShot) framePreset() *frame.Preset {
+	name := gs.App.Preferences().String(FramePresetPreference)
+	if name == "" {
+		return nil
+	}
+	for _, preset := range FramePresets(gs.App.Preferences()) {
+		if preset.Name == name {
+			return &preset
+		}
+	}
+	return nil
+}
+
+// exportImage returns the edited screenshot as saved, copied or shared: with the frame preset
+// selected, if any. Frames are only added at export, after the filters are applied.
+func (gs *GoShot) exportImage() *image.RGBA {
+	if preset := gs.framePreset(); preset != nil {
+		return preset.Apply(gs.Screenshot)
+	}
+	return gs.Screenshot
+}
+
+// SetFramePreset selects the frame preset applied when saving, copying or sharing, by name.
+// An empty name (or noFrame) selects none.
+func (gs *GoShot) SetFramePreset(name string) {
+	if name == noFrame {
+		name = ""
+	}
+	gs.App.Preferences().SetString(FramePresetPreference, name)
+	gs.updateFrameSelection()
+}
+
+// frameNames returns the names offered to select the frame preset, starting with noFrame.
+func (gs *GoShot) frameNames() []string {
+	names := []string{noFrame}
+	for _, preset := range FramePresets(gs.App.Preferences()) {
+		names = append(names, preset.Name)
+	}
+	return names
+}
+
+// selectedFrameName returns the name of the frame preset selected, noFrame if none.
+func (gs *GoShot) selectedFrameName() string {
+	if preset := gs.framePreset(); preset != nil {
+		return preset.Name
+	}
+	return noFrame
+}
+
+// buildFrameSelect creates the toolbar selector of the frame preset.
+func (gs *GoShot) buildFrameSelect() fyne.CanvasObject {
+	gs.frameSelect = widget.NewSelect(nil, func(name string) {
+		if name != gs.selectedFrameName() {
+			gs.SetFramePreset(name)
+		}
+	})
+	gs.updateFrameSelection()
+	return container.NewBorder(nil, nil, widget.NewLabel("Frame:"),
+		widget.NewButtonWithIcon("", theme.SettingsIcon(), func() { gs.EditFramePresets() }),
+		gs.frameSelect)
+}
+
+// updateFrameSelection updates the toolbar selector and the "Frame" menu with the presets and
+// the one selected.
+func (gs *GoShot) updateFrameSelection() {
+	if gs.frameSelect == nil {
+		return
+	}
+	names, selected := gs.frameNames(), gs.selectedFrameName()
+	gs.frameSelect.Options = names
+	gs.frameSelect.SetSelected(selected)
+
+	gs.frameMenu.Items = nil
+	for _, name := range names {
+		name := name
+		item := fyne.NewMenuItem(name, func() { gs.SetFramePreset(name) })
+		item.Checked = name == selected
+		gs.frameMenu.Items = append(gs.frameMenu.Items, item)
+	}
+	gs.frameMenu.Items = append(gs.frameMenu.Items, fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Edit presets ...", func() { gs.EditFramePresets() }))
+	if mainMenu := gs.Win.MainMenu(); mainMenu != nil {
+		mainMenu.Refresh()
+	}
+}
+
+// EditFramePresets opens a dialog to create, change and delete the frame presets.
+func (gs *GoShot) EditFramePresets() {
+	prefs := gs.App.Preferences()
+	presets := FramePresets(prefs)
+
+	number := func(pattern string) *widget.Entry {
+		return &widget.Entry{Validator: validation.NewRegexp(pattern, "Must be a number")}
+	}
+	colorEntry := func(placeHolder string) *widget.Entry {
+		entry := &widget.Entry{Validator: func(s string) error { _, err := palette.ParseHex(s); return err }}
+		entry.SetPlaceHolder(placeHolder)
+		return entry
+	}
+	nameEntry := widget.NewEntry()
+	radiusEntry := number(`^\d+$`)
+	paddingEntry := number(`^\d+$`)
+	opacityEntry := number(`^\d+$`)
+	blurEntry := number(`^\d+$`)
+	offsetXEntry := number(`^-?\d+$`)
+	offsetYEntry := number(`^-?\d+$`)
+	backgroundEntry := colorEntry("Transparent")
+	gradientEntry := colorEntry("No gradient")
+	angleEntry := number(`^-?\d+(\.\d*)?$`)
+
+	show := func(preset frame.Preset) {
+		nameEntry.SetText(preset.Name)
+		radiusEntry.SetText(strconv.Itoa(preset.CornerRadius))
+		paddingEntry.SetText(strconv.Itoa(preset.Padding))
+		opacityEntry.SetText(strconv.Itoa(int(preset.ShadowOpacity*100 + 0.5)))
+		blurEntry.SetText(strconv.Itoa(preset.ShadowBlur))
+		offsetXEntry.SetText(strconv.Itoa(preset.ShadowOffset.X))
+		offsetYEntry.SetText(strconv.Itoa(preset.ShadowOffset.Y))
+		backgroundEntry.SetText("")
+		if preset.Background.A > 0 {
+			backgroundEntry.SetText(palette.FormatHex(preset.Background))
+		}
+		gradientEntry.SetText("")
+		if preset.GradientTo != nil {
+			gradientEntry.SetText(palette.FormatHex(*preset.GradientTo))
+		}
+		angleEntry.SetText(strconv.FormatFloat(preset.GradientAngle, 'f', -1, 64))
+	}
+	read := func() (preset frame.Preset, err error) {
+		preset.Name = strings.TrimSpace(nameEntry.Text)
+		if preset.Name == "" || preset.Name == noFrame {
+			return preset, fmt.Errorf("invalid preset name %q", preset.Name)
+		}
+		ints := []struct {
+			entry *widget.Entry
+			value *int
+		}{
+			{radiusEntry, &preset.CornerRadius},
+			{paddingEntry, &preset.Padding},
+			{blurEntry, &preset.ShadowBlur},
+			{offsetXEntry, &preset.ShadowOffset.X},
+			{offsetYEntry, &preset.ShadowOffset.Y},
+		}
+		for _, field := range ints {
+			if *field.value, err = strconv.Atoi(field.entry.Text); err != nil {
+				return preset, fmt.Errorf("invalid number %q", field.entry.Text)
+			}
+		}
+		opacity, err := strconv.Atoi(opacityEntry.Text)
+		if err != nil {
+			return preset, fmt.Errorf("invalid shadow opacity %q", opacityEntry.Text)
+		}
+		preset.ShadowOpacity = float64(opacity) / 100
+		if preset.GradientAngle, err = strconv.ParseFloat(angleEntry.Text, 64); err != nil {
+			return preset, fmt.Errorf("invalid gradient angle %q", angleEntry.Text)
+		}
+		if preset.Background, err = palette.ParseHex(backgroundEntry.Text); err != nil {
+			return preset, err
+		}
+		if gradientEntry.Text != "" {
+			c, err := palette.ParseHex(gradientEntry.Text)
+			if err != nil {
+				return preset, err
+			}
+			preset.GradientTo = &c
+		}
+		return preset, nil
+	}
+
+	names := func() []string {
+		names := make([]string, len(presets))
+		for ii, preset := range presets {
+			names[ii] = preset.Name
+		}
+		return names
+	}
+	presetSelect := widget.NewSelect(names(), func(name string) {
+		for _, preset := range presets {
+			if preset.Name == name {
+				show(preset)
+			}
+		}
+	})
+	status := widget.NewLabel("")
+	saveButton := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), func() {
+		preset, err := read()
+		if err != nil {
+			status.SetText(fmt.Sprintf("Not saved: %v", err))
+			return
+		}
+		found := false
+		for ii := range presets {
+			if presets[ii].Name == preset.Name {
+				presets[ii], found = preset, true
+			}
+		}
+		if !found {
+			presets = append(presets, preset)
+		}
+		SetFramePresets(prefs, presets)
+		presetSelect.Options = names()
+		presetSelect.SetSelected(preset.Name)
+		gs.updateFrameSelection()
+		status.SetText(fmt.Sprintf("Preset %q saved.", preset.Name))
+	})
+	deleteButton := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
+		name := strings.TrimSpace(nameEntry.Text)
+		for ii := range presets {
+			if presets[ii].Name == name {
+				presets = append(presets[:ii], presets[ii+1:]...)
+				SetFramePresets(prefs, presets)
+				presetSelect.Options = names()
+				presetSelect.ClearSelected()
+				gs.updateFrameSelection()
+				status.SetText(fmt.Sprintf("Preset %q deleted.", name))
+				return
+			}
+		}
+	})
+	if len(presets) > 0 {
+		presetSelect.SetSelected(gs.selectedFrameName())
+		if presetSelect.Selected == noFrame {
+			presetSelect.SetSelected(presets[0].Name)
+		}
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Corner radius", radiusEntry),
+		widget.NewFormItem("Padding", paddingEntry),
+		widget.NewFormItem("Shadow opacity (%)", opacityEntry),
+		widget.NewFormItem("Shadow blur", blurEntry),
+		widget.NewFormItem("Shadow offset X", offsetXEntry),
+		widget.NewFormItem("Shadow offset Y", offsetYEntry),
+		widget.NewFormItem("Background (#rrggbb[aa])", backgroundEntry),
+		widget.NewFormItem("Gradient to (#rrggbb[aa])", gradientEntry),
+		widget.NewFormItem("Gradient angle (degrees)", angleEntry),
+	)
+	content := container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("Preset:"), nil, presetSelect),
+		form,
+		container.NewHBox(saveButton, deleteButton),
+		status,
+	)
+	d := dialog.NewCustom("Frame presets", "Close", container.NewVScroll(content), gs.Win)
+	d.Resize(fyne.NewSize(500, 600))
+	d.Show()
+}
@@ -0,0 +1,383 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/janpfeifer/goshot/filters"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+)
+
+// Preferences of the measure tool, and of the overlays drawn over the image in the ViewPort
+// (they are not part of the image).
+const (
+	// RulersPreference shows rulers on the top and left edges of the ViewPort, false by default.
+	RulersPreference = "Rulers"
+
+	// GridPreference shows a grid over the image, false by default.
+	GridPreference = "Grid"
+
+	// GridSizePreference is the spacing of the grid, in pixels of the image, 10 if not set.
+	GridSizePreference = "GridSize"
+
+	// SnapToGridPreference snaps the points of new annotations and measurements to the grid.
+	SnapToGridPreference = "SnapToGrid"
+
+	// SnapToEdgesPreference snaps the points of new annotations and measurements to the edges
+	// detected in the image nearby. It's ignored if SnapToGridPreference is set.
+	SnapToEdgesPreference = "SnapToEdges"
+)
+
+const (
+	rulerSize          = 16 // Width of the rulers, in ViewPort pixels.
+	minRulerLabelSpace = 50 // Minimum space between labeled ticks of the rulers, in ViewPort pixels.
+	minGridSpace       = 4  // Minimum space between the lines of the grid to be shown, in ViewPort pixels.
+	snapRadius         = 8  // Distance searched for edges to snap to, in ViewPort pixels.
+	edgeThreshold      = 24 // Minimum difference of luminance (0 to 255) between neighbour pixels of an edge.
+)
+
+// Colors of the overlays.
+var (
+	rulerBackgroundColor = color.RGBA{R: 0x28, G: 0x28, B: 0x28, A: 0xff}
+	rulerColor           = color.RGBA{R: 0xdc, G: 0xdc, B: 0xdc, A: 0xff}
+	gridColor            = color.RGBA{R: 0x00, G: 0xb4, B: 0xff, A: 0xff}
+	measureColor         = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	measureOutlineColor  = color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+)
+
+// measureTool is the state of the Measure operation: the segment measured, in the coordinates of
+// the OriginalScreenshot.
+type measureTool struct {
+	from, to image.Point
+	valid    bool
+}
+
+// gridSize returns the spacing of the grid, see GridSizePreference.
+func (gs *GoShot) gridSize() int {
+	size := gs.App.Preferences().IntWithFallback(GridSizePreference, 10)
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// GridSizeForm opens a dialog to set the spacing of the grid.
+func (gs *GoShot) GridSizeForm() {
+	sizeEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+$`, "Must be a number")}
+	sizeEntry.SetText(strconv.Itoa(gs.gridSize()))
+	form := dialog.NewForm("Grid size", "Ok", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Spacing (pixels)", sizeEntry)},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			size, err := strconv.Atoi(sizeEntry.Text)
+			if err != nil || size < 1 {
+				gs.status.SetText(fmt.Sprintf("Invalid grid size %q: it must be a number of pixels.", sizeEntry.Text))
+				return
+			}
+			gs.App.Preferences().SetInt(GridSizePreference, size)
+			gs.viewPort.Refresh()
+		}, gs.Win)
+	form.Resize(fyne.NewSize(300, 150))
+	form.Show()
+}
+
+// snappedPos returns the point of the OriginalScreenshot at the ViewPort position, snapped to
+// the grid or to the edges nearby, if configured.
+func (vp *ViewPort) snappedPos(pos fyne.Position) image.Point {
+	x, y := vp.screenshotPos(pos)
+	p := image.Pt(x, y).Add(vp.gs.CropRect.Min)
+	prefs := vp.gs.App.Preferences()
+	if prefs.Bool(SnapToGridPreference) {
+		size := vp.gs.gridSize()
+		snap := func(v, origin int) int {
+			return origin + int(math.Round(float64(v-origin)/float64(size)))*size
+		}
+		return image.Pt(snap(p.X, vp.gs.CropRect.Min.X), snap(p.Y, vp.gs.CropRect.Min.Y))
+	}
+	if prefs.Bool(SnapToEdgesPreference) {
+		radius := int(math.Round(snapRadius * vp.zoom()))
+		if radius < 2 {
+			radius = 2
+		}
+		return vp.gs.snapToEdges(p, radius)
+	}
+	return p
+}
+
+// snapToEdges moves the point to the strongest vertical edge within `radius` pixels in its row,
+// and to the strongest horizontal edge in its column. Edges are where the luminance of the
+// OriginalScreenshot changes by at least edgeThreshold between neighbour pixels.
+func (gs *GoShot) snapToEdges(p image.Point, radius int) image.Point {
+	img := gs.OriginalScreenshot
+	if !p.In(img.Rect) {
+		return p
+	}
+	// strongestEdge returns the position, along a line of the image, of the strongest edge
+	// between the pixels at position-1 and position.
+	strongestEdge := func(center, from, to int, lum func(v int) int) int {
+		best, bestDiff := center, edgeThreshold-1
+		for v := clamp(center-radius, from+1, to-1); v <= clamp(center+radius, from+1, to-1); v++ {
+			diff := abs(lum(v) - lum(v-1))
+			if diff > bestDiff || (diff == bestDiff && abs(v-center) < abs(best-center)) {
+				best, bestDiff = v, diff
+			}
+		}
+		return best
+	}
+	x := strongestEdge(p.X, img.Rect.Min.X, img.Rect.Max.X, func(x int) int { return luminance(img.RGBAAt(x, p.Y)) })
+	y := strongestEdge(p.Y, img.Rect.Min.Y, img.Rect.Max.Y, func(y int) int { return luminance(img.RGBAAt(p.X, y)) })
+	return image.Pt(x, y)
+}
+
+// luminance returns the perceived brightness of the color, from 0 to 255.
+func luminance(c color.RGBA) int {
+	return (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+}
+
+// startMeasure starts measuring from the point, in the coordinates of the OriginalScreenshot.
+func (gs *GoShot) startMeasure(p image.Point) {
+	gs.measure = measureTool{from: p, to: p, valid: true}
+	gs.updateMeasureStatus()
+	gs.viewPort.Refresh()
+}
+
+// dragMeasure moves the end of the segment measured.
+func (gs *GoShot) dragMeasure(p image.Point) {
+	gs.measure.to = p
+	gs.updateMeasureStatus()
+	gs.viewPort.Refresh()
+}
+
+// endMeasure clears the segment measured, when leaving the Measure operation.
+func (gs *GoShot) endMeasure() {
+	gs.measure = measureTool{}
+	gs.viewPort.Refresh()
+}
+
+// updateMeasureStatus shows the distance, dx/dy and angle of the segment measured in the status bar.
+func (gs *GoShot) updateMeasureStatus() {
+	m := gs.measure
+	delta := m.to.Sub(m.from)
+	// Angle counter-clockwise from the horizontal, as usual, so the Y axis is inverted.
+	angle := math.Atan2(float64(-delta.Y), float64(delta.X)) * 180 / math.Pi
+	gs.status.SetText(fmt.Sprintf("Measure: %s px, dx=%d, dy=%d, angle %.1f°. Enter adds it as a dimension line, Esc ends.",
+		filters.FormatLength(math.Hypot(float64(delta.X), float64(delta.Y))), delta.X, delta.Y, angle))
+}
+
+// AddDimensionLine turns the segment measured into a dimension line drawn on the image.
+func (gs *GoShot) AddDimensionLine() {
+	m := gs.measure
+	if !m.valid || m.from == m.to {
+		gs.status.SetText("Measure: drag between two points first, or press Esc to end.")
+		return
+	}
+	vp := gs.viewPort
+	gs.Filters = append(gs.Filters, filters.NewDimensionLine(m.from, m.to,
+		vp.DrawingColor, vp.BackgroundColor, vp.Thickness, vp.FontSize, vp.Font))
+	vp.SetOp(NoOp)
+	gs.ApplyFilters(true)
+	gs.status.SetText("Dimension line drawn, use Control+Z to undo.")
+}
+
+// pixelPos returns the ViewPort pixel of the point, in the coordinates of the OriginalScreenshot.
+func (vp *ViewPort) pixelPos(p image.Point) image.Point {
+	p = p.Sub(vp.gs.CropRect.Min)
+	zoom := vp.zoom()
+	return image.Pt(int(math.Round(float64(p.X-vp.viewX)/zoom)), int(math.Round(float64(p.Y-vp.viewY)/zoom)))
+}
+
+// drawMeasureOverlay draws the segment measured over the rendered cache, with its length.
+func (vp *ViewPort) drawMeasureOverlay() {
+	m := vp.gs.measure
+	if !m.valid {
+		return
+	}
+	from, to := vp.pixelPos(m.from), vp.pixelPos(m.to)
+	drawLine(vp.cache, from, to, 1, measureOutlineColor)
+	drawLine(vp.cache, from, to, 0, measureColor)
+	for _, p := range []image.Point{from, to} {
+		square := image.Rect(p.X-2, p.Y-2, p.X+3, p.Y+3)
+		fillRect(vp.cache, square.Inset(-1), measureOutlineColor)
+		fillRect(vp.cache, square, measureColor)
+	}
+	delta := m.to.Sub(m.from)
+	label := filters.FormatLength(math.Hypot(float64(delta.X), float64(delta.Y))) + " px"
+	center := from.Add(to).Div(2)
+	labelRect := image.Rect(0, 0, font.MeasureString(basicfont.Face7x13, label).Ceil(), basicfont.Face7x13.Height).
+		Add(center).Add(image.Pt(6, 6)).Inset(-2)
+	fillRect(vp.cache, labelRect, measureOutlineColor)
+	drawText(vp.cache, label, labelRect.Min.Add(image.Pt(2, 2)), measureColor)
+}
+
+// gridSpace returns the spacing of the grid in pixels of the image, or 0 if it's not shown:
+// because it's disabled, or because its lines would be too close at the current zoom.
+func (vp *ViewPort) gridSpace() int {
+	if !vp.gs.App.Preferences().Bool(GridPreference) {
+		return 0
+	}
+	size := vp.gs.gridSize()
+	if float64(size)/vp.zoom() < minGridSpace {
+		return 0
+	}
+	return size
+}
+
+// crossesGrid returns whether there is a line of the grid of the given spacing between the
+// coordinates `from` (excluded) and `to` (included).
+func crossesGrid(from, to, space int) bool {
+	return floorDiv(from, space) != floorDiv(to, space)
+}
+
+// floorDiv divides rounding towards negative infinity.
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+// blendColor returns `c` blended with `over` with the given opacity.
+func blendColor(c, over color.RGBA, opacity float64) color.RGBA {
+	blend := func(v, o uint8) uint8 { return uint8(float64(v)*(1-opacity) + float64(o)*opacity + 0.5) }
+	return color.RGBA{R: blend(c.R, over.R), G: blend(c.G, over.G), B: blend(c.B, over.B), A: blend(c.A, over.A)}
+}
+
+// showRulers returns whether the rulers are shown, see RulersPreference.
+func (vp *ViewPort) showRulers() bool {
+	return vp.gs.App.Preferences().Bool(RulersPreference)
+}
+
+// rulerBands returns the rectangles of the cache covered by the top and left rulers.
+func (vp *ViewPort) rulerBands() (top, left image.Rectangle) {
+	w, h := vp.cache.Rect.Dx(), vp.cache.Rect.Dy()
+	return image.Rect(0, 0, w, rulerSize), image.Rect(0, 0, rulerSize, h)
+}
+
+// rulerSteps returns the spacing of the labeled ticks of the rulers, and of the minor ticks in
+// between, in pixels of the image: 1, 2 or 5 times a power of 10, as small as possible with the
+// labeled ticks at least minRulerLabelSpace apart.
+func rulerSteps(zoom float64) (major, minor int) {
+	for base := 1; ; base *= 10 {
+		for _, m := range []int{1, 2, 5} {
+			major = base * m
+			if float64(major)/zoom >= minRulerLabelSpace {
+				minor = major / 5
+				if m == 2 {
+					minor = major / 4
+				}
+				if minor < 1 || float64(minor)/zoom < minGridSpace {
+					minor = major
+				}
+				return
+			}
+		}
+	}
+}
+
+// drawRulers draws the rulers over the top and left edges of the cache, with the coordinates of
+// the (cropped) image.
+func (vp *ViewPort) drawRulers() {
+	img := vp.cache
+	top, left := vp.rulerBands()
+	fillRect(img, top, rulerBackgroundColor)
+	fillRect(img, left, rulerBackgroundColor)
+	zoom := vp.zoom()
+	major, minor := rulerSteps(zoom)
+
+	// drawTicks draws the ticks of one ruler: `view` is the first coordinate of the image shown,
+	// `length` the length of the ruler in pixels, and `tick` draws a tick at the given pixel.
+	drawTicks := func(view, length int, tick func(pixel, size int, label string)) {
+		first := floorDiv(view, minor) * minor
+		for v := first; ; v += minor {
+			pixel := int(math.Round(float64(v-view) / zoom))
+			if pixel >= length {
+				break
+			}
+			if pixel < rulerSize {
+				continue
+			}
+			if v%major == 0 {
+				tick(pixel, rulerSize, strconv.Itoa(v))
+			} else {
+				tick(pixel, rulerSize/4, "")
+			}
+		}
+	}
+	drawTicks(vp.viewX, top.Dx(), func(pixel, size int, label string) {
+		fillRect(img, image.Rect(pixel, rulerSize-size, pixel+1, rulerSize), rulerColor)
+		if label != "" {
+			drawText(img, label, image.Pt(pixel+2, 1), rulerColor)
+		}
+	})
+	drawTicks(vp.viewY, left.Dy(), func(pixel, size int, label string) {
+		fillRect(img, image.Rect(rulerSize-size, pixel, rulerSize, pixel+1), rulerColor)
+		if label != "" {
+			drawTextVertical(img, label, image.Pt(1, pixel+2), rulerColor)
+		}
+	})
+}
+
+// fillRect fills the rectangle of the image, clipped to it, with the color.
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	draw.Draw(img, rect.Intersect(img.Rect), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// drawLine draws a line between the points, with `width` pixels on each side of it.
+func drawLine(img *image.RGBA, from, to image.Point, width int, c color.RGBA) {
+	delta := to.Sub(from)
+	steps := abs(delta.X)
+	if abs(delta.Y) > steps {
+		steps = abs(delta.Y)
+	}
+	for ii := 0; ii <= steps; ii++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(ii) / float64(steps)
+		}
+		x := from.X + int(math.Round(t*float64(delta.X)))
+		y := from.Y + int(math.Round(t*float64(delta.Y)))
+		fillRect(img, image.Rect(x-width, y-width, x+width+1, y+width+1), c)
+	}
+}
+
+// drawText draws the text with a small fixed size font, with the top-left corner at `at`.
+func drawText(img draw.Image, text string, at image.Point, c color.RGBA) {
+	face := basicfont.Face7x13
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(at.X, at.Y+face.Ascent),
+	}
+	d.DrawString(text)
+}
+
+// drawTextVertical draws the text like drawText, rotated to be read from the bottom to the top,
+// with the top-left corner at `at`.
+func drawTextVertical(img *image.RGBA, text string, at image.Point, c color.RGBA) {
+	face := basicfont.Face7x13
+	w, h := font.MeasureString(face, text).Ceil(), face.Height
+	horizontal := image.NewRGBA(image.Rect(0, 0, w, h))
+	drawText(horizontal, text, image.Point{}, c)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if pix := horizontal.RGBAAt(x, y); pix.A > 0 {
+				p := at.Add(image.Pt(y, w-1-x))
+				if p.In(img.Rect) {
+					img.SetRGBA(p.X, p.Y, blendColor(img.RGBAAt(p.X, p.Y), c, float64(pix.A)/255))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package screenshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/palette"
+	"image/color"
+	"path"
+	"strings"
+)
+
+// Preferences of the color palette.
+const (
+	// PalettePreference holds the palette of swatches shown in the toolbar, encoded as JSON.
+	// palette.Default is used if not set.
+	PalettePreference = "Palette"
+
+	// RecentColorsPreference holds the colors last used, most recent first, as a JSON list of
+	// hex colors.
+	RecentColorsPreference = "RecentColors"
+
+	// RecentColorsSizePreference is the number of recent colors kept, 8 if not set.
+	RecentColorsSizePreference = "RecentColorsSize"
+)
+
+// colorSlot is the color set by the palette, the color picker and the eyedropper.
+type colorSlot int
+
+const (
+	strokeSlot         colorSlot = iota // ViewPort.DrawingColor: lines, arrows and text.
+	fillSlot                            // ViewPort.FillColor: inside of circles.
+	textBackgroundSlot                  // ViewPort.BackgroundColor: background of text.
+)
+
+// colorSlotNames are the names of the slots, in the order of their values.
+var colorSlotNames = []string{"Stroke", "Fill", "Text background"}
+
+// swatchSize is the size of the swatches in the toolbar.
+const swatchSize = 20
+
+// Palette returns the palette configured in the preferences.
+func Palette(prefs fyne.Preferences) palette.Palette {
+	value := prefs.String(PalettePreference)
+	if value == "" {
+		return palette.Default
+	}
+	var p palette.Palette
+	if err := json.Unmarshal([]byte(value), &p); err != nil {
+		glog.Errorf("Invalid palette in preferences, using the default one: %v", err)
+		return palette.Default
+	}
+	return p
+}
+
+// SetPalette saves the palette in the preferences.
+func SetPalette(prefs fyne.Preferences, p palette.Palette) {
+	value, err := json.Marshal(p)
+	if err != nil {
+		glog.Errorf("Failed to encode palette: %v", err)
+		return
+	}
+	prefs.SetString(PalettePreference, string(value))
+}
+
+// RecentColors returns the colors last used, most recent first.
+func RecentColors(prefs fyne.Preferences) []color.NRGBA {
+	var hexColors []string
+	if value := prefs.String(RecentColorsPreference); value != "" {
+		if err := json.Unmarshal([]byte(value), &hexColors); err != nil {
+			glog.Errorf("Invalid recent colors in preferences: %v", err)
+		}
+	}
+	recent := make([]color.NRGBA, 0, len(hexColors))
+	for _, hex := range hexColors {
+		if c, err := palette.ParseHex(hex); err == nil {
+			recent = append(recent, c)
+		}
+	}
+	return recent
+}
+
+// setRecentColors saves the colors last used in the preferences.
+func setRecentColors(prefs fyne.Preferences, recent []color.NRGBA) {
+	hexColors := make([]string, len(recent))
+	for ii, c := range recent {
+		hexColors[ii] = palette.FormatHex(c)
+	}
+	value, _ := json.Marshal(hexColors)
+	prefs.SetString(RecentColorsPreference, string(value))
+}
+
+// addRecentColor moves the color to the front of the recent colors.
+func (gs *GoShot) addRecentColor(c color.Color) {
+	prefs := gs.App.Preferences()
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if nrgba.A == 0 {
+		return // Transparent is not worth keeping.
+	}
+	setRecentColors(prefs, palette.AddRecent(RecentColors(prefs), nrgba, prefs.IntWithFallback(RecentColorsSizePreference, 8)))
+	gs.updatePalette()
+}
+
+// SetDrawingColor sets the color of new drawings, and saves it in the preferences.
+func (gs *GoShot) SetDrawingColor(c color.Color) {
+	gs.SetSlotColor(strokeSlot, c)
+}
+
+// SetFillColor sets the color filling new circles, and saves it in the preferences.
+func (gs *GoShot) SetFillColor(c color.Color) {
+	gs.SetSlotColor(fillSlot, c)
+}
+
+// SetBackgroundColor sets the background color of new texts, and saves it in the preferences.
+func (gs *GoShot) SetBackgroundColor(c color.Color) {
+	gs.SetSlotColor(textBackgroundSlot, c)
+}
+
+// slotColor returns the current color of the slot.
+func (gs *GoShot) slotColor(slot colorSlot) color.Color {
+	switch slot {
+	case fillSlot:
+		return gs.viewPort.FillColor
+	case textBackgroundSlot:
+		return gs.viewPort.BackgroundColor
+	}
+	return gs.viewPort.DrawingColor
+}
+
+// SetSlotColor sets the color of the slot, see SetDrawingColor, SetFillColor and
+// SetBackgroundColor, and moves it to the front of the recent colors.
+func (gs *GoShot) SetSlotColor(slot colorSlot, c color.Color) {
+	gs.setSlotColor(slot, c)
+	gs.addRecentColor(c)
+}
+
+// setSlotColor sets the color of the slot and saves it in the preferences, without changing the
+// recent colors.
+func (gs *GoShot) setSlotColor(slot colorSlot, c color.Color) {
+	switch slot {
+	case strokeSlot:
+		gs.viewPort.DrawingColor = c
+		gs.SetColorPreference(DrawingColorPreference, c)
+	case fillSlot:
+		gs.viewPort.FillColor = c
+		gs.SetColorPreference(FillColorPreference, c)
+	case textBackgroundSlot:
+		gs.viewPort.BackgroundColor = c
+		gs.SetColorPreference(BackgroundColorPreference, c)
+	}
+	gs.updateColorSample()
+}
+
+// setActiveSlot selects the slot set by the palette, the color picker and the eyedropper.
+func (gs *GoShot) setActiveSlot(slot colorSlot) {
+	gs.colorSlot = slot
+	gs.updateColorSample()
+}
+
+// updateColorSample shows the color of the active slot in the toolbar.
+func (gs *GoShot) updateColorSample() {
+	if gs.colorSample == nil {
+		return
+	}
+	gs.colorSample.FillColor = gs.slotColor(gs.colorSlot)
+	gs.colorSample.Refresh()
+}
+
+// swatchColors returns the colors of the swatches in the toolbar, in the order shown: the
+// palette followed by the recent colors. The first nine are selected with the number keys.
+func (gs *GoShot) swatchColors() (colors []color.NRGBA, names []string) {
+	prefs := gs.App.Preferences()
+	for _, s := range Palette(prefs).Swatches {
+		colors = append(colors, s.Color)
+		names = append(names, s.Name)
+	}
+	for _, c := range RecentColors(prefs) {
+		colors = append(colors, c)
+		names = append(names, "")
+	}
+	return
+}
+
+// SelectSwatch sets the color of the active slot to the n-th swatch (starting from 0) in the
+// toolbar. The recent colors are not changed: the color is either in the palette or already
+// among them, and reordering them would move the swatches under the mouse or number keys.
+func (gs *GoShot) SelectSwatch(n int) {
+	colors, names := gs.swatchColors()
+	if n < 0 || n >= len(colors) {
+		return
+	}
+	c := colors[n]
+	gs.setSlotColor(gs.colorSlot, c)
+	description := palette.FormatHex(c)
+	if names[n] != "" {
+		description = fmt.Sprintf("%s (%s)", names[n], description)
+	}
+	gs.status.SetText(fmt.Sprintf("%s color set to %s.", colorSlotNames[gs.colorSlot], description))
+}
+
+// swatchKey returns the number of the swatch selected with the key (1 to 9), or 0 if it's not
+// a number key.
+func swatchKey(key fyne.KeyName) int {
+	if len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+		return int(key[0] - '0')
+	}
+	return 0
+}
+
+// buildPalettePanel creates the toolbar panel with the slot selector, the palette and the
+// recent colors.
+func (gs *GoShot) buildPalettePanel() fyne.CanvasObject {
+	slotSelect := widget.NewSelect(colorSlotNames, func(selected string) {
+		for ii, name := range colorSlotNames {
+			if name == selected {
+				gs.setActiveSlot(colorSlot(ii))
+			}
+		}
+	})
+	slotSelect.SetSelected(colorSlotNames[gs.colorSlot])
+	gs.paletteBox = container.NewGridWrap(fyne.NewSize(swatchSize, swatchSize))
+	gs.updatePalette()
+	return container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("Color:"), nil, slotSelect),
+		gs.paletteBox,
+	)
+}
+
+// updatePalette rebuilds the swatches of the toolbar, after the palette or the recent colors
+// changed.
+func (gs *GoShot) updatePalette() {
+	if gs.paletteBox == nil {
+		return
+	}
+	colors, _ := gs.swatchColors()
+	numPalette := len(Palette(gs.App.Preferences()).Swatches)
+	gs.paletteBox.Objects = nil
+	for ii, c := range colors {
+		ii := ii
+		var onRemove func()
+		if ii < numPalette {
+			onRemove = func() { gs.RemoveSwatch(ii) }
+		}
+		gs.paletteBox.Objects = append(gs.paletteBox.Objects,
+			newColorSwatch(c, func() { gs.SelectSwatch(ii) }, onRemove))
+	}
+	gs.paletteBox.Refresh()
+}
+
+// AddSwatch adds the color of the active slot to the palette.
+func (gs *GoShot) AddSwatch() {
+	prefs := gs.App.Preferences()
+	p := Palette(prefs)
+	c := color.NRGBAModel.Convert(gs.slotColor(gs.colorSlot)).(color.NRGBA)
+	p.Swatches = append(append([]palette.Swatch(nil), p.Swatches...), palette.Swatch{Color: c})
+	SetPalette(prefs, p)
+	gs.updatePalette()
+	gs.status.SetText(fmt.Sprintf("Color %s added to the palette.", palette.FormatHex(c)))
+}
+
+// RemoveSwatch removes the n-th swatch (starting from 0) from the palette.
+func (gs *GoShot) RemoveSwatch(n int) {
+	prefs := gs.App.Preferences()
+	p := Palette(prefs)
+	if n < 0 || n >= len(p.Swatches) {
+		return
+	}
+	removed := p.Swatches[n]
+	p.Swatches = append(append([]palette.Swatch(nil), p.Swatches[:n]...), p.Swatches[n+1:]...)
+	SetPalette(prefs, p)
+	gs.updatePalette()
+	gs.status.SetText(fmt.Sprintf("Color %s removed from the palette.", palette.FormatHex(removed.Color)))
+}
+
+// ResetPalette restores the default palette.
+func (gs *GoShot) ResetPalette() {
+	gs.App.Preferences().SetString(PalettePreference, "")
+	gs.updatePalette()
+	gs.status.SetText("Palette reset to the default colors.")
+}
+
+// ClearRecentColors forgets the colors last used.
+func (gs *GoShot) ClearRecentColors() {
+	gs.App.Preferences().SetString(RecentColorsPreference, "")
+	gs.updatePalette()
+	gs.status.SetText("Recent colors cleared.")
+}
+
+// ImportPalette opens a file dialog to replace the palette with one in JSON or in the GIMP
+// palette (.gpl) format.
+func (gs *GoShot) ImportPalette() {
+	fileOpen := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			gs.status.SetText(fmt.Sprintf("Failed to import palette: %s", err))
+			return
+		}
+		if reader == nil {
+			gs.status.SetText("Import palette cancelled.")
+			return
+		}
+		defer func() { _ = reader.Close() }()
+		gs.App.Preferences().SetString(DefaultPathPreference, path.Dir(reader.URI().Path()))
+		p, err := palette.Read(reader)
+		if err != nil {
+			glog.Errorf("Failed to import palette from %q: %s", reader.URI(), err)
+			gs.status.SetText(fmt.Sprintf("Failed to import palette from %q: %s", reader.URI(), err))
+			return
+		}
+		SetPalette(gs.App.Preferences(), p)
+		gs.updatePalette()
+		gs.status.SetText(fmt.Sprintf("Imported palette %q with %d colors.", p.Name, len(p.Swatches)))
+	}, gs.Win)
+	fileOpen.SetFilter(storage.NewExtensionFileFilter([]string{".json", ".gpl"}))
+	gs.showFileDialog(fileOpen)
+}
+
+// ExportPalette opens a file dialog to save the palette, as JSON or in the GIMP palette format if
+// `gpl` is true.
+func (gs *GoShot) ExportPalette(gpl bool) {
+	fileSave := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			gs.status.SetText(fmt.Sprintf("Failed to export palette: %s", err))
+			return
+		}
+		if writer == nil {
+			gs.status.SetText("Export palette cancelled.")
+			return
+		}
+		defer func() { _ = writer.Close() }()
+		gs.App.Preferences().SetString(DefaultPathPreference, path.Dir(writer.URI().Path()))
+		p := Palette(gs.App.Preferences())
+		if gpl {
+			err = p.WriteGPL(writer)
+		} else {
+			err = p.WriteJSON(writer)
+		}
+		if err != nil {
+			glog.Errorf("Failed to export palette to %q: %s", writer.URI(), err)
+			gs.status.SetText(fmt.Sprintf("Failed to export palette to %q: %s", writer.URI(), err))
+			return
+		}
+		gs.status.SetText(fmt.Sprintf("Exported palette to %q", writer.URI()))
+	}, gs.Win)
+	name := strings.ToLower(strings.ReplaceAll(Palette(gs.App.Preferences()).Name, " ", "_"))
+	if name == "" {
+		name = "palette"
+	}
+	if gpl {
+		fileSave.SetFileName(name + ".gpl")
+	} else {
+		fileSave.SetFileName(name + ".json")
+	}
+	gs.showFileDialog(fileSave)
+}
+
+// showFileDialog shows the file dialog in the folder last used, sized to the window.
+func (gs *GoShot) showFileDialog(d *dialog.FileDialog) {
+	if defaultPath := gs.App.Preferences().String(DefaultPathPreference); defaultPath != "" {
+		if lister, err := storage.ListerForURI(storage.NewFileURI(defaultPath)); err == nil {
+			d.SetLocation(lister)
+		}
+	}
+	size := gs.Win.Canvas().Size()
+	size.Width *= 0.90
+	size.Height *= 0.90
+	d.Resize(size)
+	d.Show()
+}
+
+// colorSwatch is a square of color in the toolbar: tapping it selects the color, and
+// right-clicking it removes it from the palette, if onRemove is set.
+type colorSwatch struct {
+	widget.BaseWidget
+	color              color.Color
+	onTapped, onRemove func()
+}
+
+// newColorSwatch creates a swatch of the color.
+func newColorSwatch(c color.Color, onTapped, onRemove func()) *colorSwatch {
+	s := &colorSwatch{color: c, onTapped: onTapped, onRemove: onRemove}
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+// CreateRenderer implements fyne.Widget.
+func (s *colorSwatch) CreateRenderer() fyne.WidgetRenderer {
+	rect := canvas.NewRectangle(s.color)
+	rect.StrokeColor = theme.ForegroundColor()
+	rect.StrokeWidth = 1
+	return widget.NewSimpleRenderer(rect)
+}
+
+// MinSize implements fyne.CanvasObject.
+func (s *colorSwatch) MinSize() fyne.Size {
+	return fyne.NewSize(swatchSize, swatchSize)
+}
+
+// Tapped implements fyne.Tappable.
+func (s *colorSwatch) Tapped(*fyne.PointEvent) {
+	s.onTapped()
+}
+
+// TappedSecondary implements fyne.SecondaryTappable.
+func (s *colorSwatch) TappedSecondary(*fyne.PointEvent) {
+	if s.onRemove != nil {
+		s.onRemove()
+	}
+}
@@ -0,0 +1,240 @@
+package screenshot
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/ipc"
+	"github.com/janpfeifer/goshot/record"
+	"image"
+	"image/draw"
+	"path"
+	"strings"
+	"time"
+)
+
+// Preferences of the screen recording.
+const (
+	// RecordFPSPreference is the number of frames captured per second, DefaultRecordFPS if not set.
+	RecordFPSPreference = "RecordFPS"
+
+	// RecordMaxDurationPreference is the maximum duration of a recording, in seconds,
+	// DefaultRecordMaxDuration if not set.
+	RecordMaxDurationPreference = "RecordMaxDuration"
+
+	// RecordAnnotationsPreference burns the edits (the filters) into every frame of the animation
+	// saved, true by default.
+	RecordAnnotationsPreference = "RecordAnnotations"
+)
+
+// Defaults of the screen recording preferences.
+const (
+	DefaultRecordFPS         = 10
+	DefaultRecordMaxDuration = 30 * time.Second
+)
+
+// ErrNotRecording is returned by Session.StopRecording if there is no recording in progress.
+var ErrNotRecording = errors.New("no recording in progress")
+
+// RecordFPS returns the frames per second of recordings set in the preferences.
+func RecordFPS(prefs fyne.Preferences) int {
+	fps := prefs.IntWithFallback(RecordFPSPreference, DefaultRecordFPS)
+	if fps <= 0 {
+		fps = DefaultRecordFPS
+	}
+	return fps
+}
+
+// RecordMaxDuration returns the maximum duration of recordings set in the preferences.
+func RecordMaxDuration(prefs fyne.Preferences) time.Duration {
+	secs := prefs.Int(RecordMaxDurationPreference)
+	if secs <= 0 {
+		return DefaultRecordMaxDuration
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// StartRecording records the selected region (the CropRect) of the screen as an animation, at
+// the frame rate set in the preferences. The edit window is hidden during the recording, which
+// stops after the maximum duration (RecordMaxDurationPreference) or with Session.StopRecording.
+// Outside the system tray (no KeepRunning) nothing else listens to `goshot ctl stop`, so the
+// control socket is served while recording, see listenStop.
+//
+// Once it stops, the edit window shows the first frame: it can be annotated as usual, and the
+// animation saved with SaveAnimation. It's only available for screenshots of the display.
+func (gs *GoShot) StartRecording() {
+	if gs.captureBounds.Empty() {
+		gs.status.SetText("Recording is only available for screenshots of the display.")
+		return
+	}
+	region := gs.CropRect.Add(gs.captureBounds.Min)
+	s := gs.Session
+	s.recMu.Lock()
+	if s.recording != nil {
+		s.recMu.Unlock()
+		gs.status.SetText("A recording is already in progress.")
+		return
+	}
+	s.recording = gs
+	s.recMu.Unlock()
+
+	glog.V(1).Infof("Recording %+v", region)
+	gs.Win.Hide()
+	go func() {
+		time.Sleep(hideDelay)
+		prefs := gs.App.Preferences()
+		recorder := record.Start(region, RecordFPS(prefs), RecordMaxDuration(prefs))
+		s.setRecorder(recorder)
+		var server *ipc.Server
+		if !s.KeepRunning {
+			server = s.listenStop()
+		}
+		frames, err := recorder.Wait()
+		s.setRecorder(nil)
+		if server != nil {
+			if err := server.Close(); err != nil {
+				glog.Errorf("Failed to close control socket: %v", err)
+			}
+		}
+		gs.Win.Show()
+		if err != nil {
+			glog.Errorf("Recording failed: %v", err)
+			gs.status.SetText(fmt.Sprintf("Recording failed: %v", err))
+			return
+		}
+		gs.Filters = nil
+		gs.setCapture(frames[0].Image)
+		gs.frames = frames
+		gs.refreshScreenshot()
+		var duration time.Duration
+		for _, frame := range frames {
+			duration += frame.Delay
+		}
+		gs.status.SetText(fmt.Sprintf("Recorded %d frames, %.1f seconds: annotate the first one, and use \"Save animation\".",
+			len(frames), duration.Seconds()))
+	}()
+}
+
+// listenStop serves the control socket during a recording, accepting only the "stop" command, for
+// a GoShot not running in the system tray. It returns nil if the socket can't be served, e.g. if
+// GoShot is also running in the system tray (with --standalone).
+func (s *Session) listenStop() *ipc.Server {
+	server, err := ipc.Listen(func(command string, args []string) (string, error) {
+		if command != "stop" {
+			return "", fmt.Errorf("only \"stop\" is accepted while recording, not %q", command)
+		}
+		if err := s.StopRecording(); err != nil {
+			return "", err
+		}
+		return "recording stopped", nil
+	})
+	if err != nil {
+		glog.Warningf("The recording can't be stopped with `goshot ctl stop`: %v", err)
+		return nil
+	}
+	return server
+}
+
+// setRecorder sets the recorder in progress, nil once it stopped, and reports it to
+// OnRecordingChanged.
+func (s *Session) setRecorder(recorder *record.Recorder) {
+	s.recMu.Lock()
+	s.recorder = recorder
+	if recorder == nil {
+		s.recording = nil
+	}
+	s.recMu.Unlock()
+	if s.OnRecordingChanged != nil {
+		s.OnRecordingChanged(recorder != nil)
+	}
+}
+
+// Recording returns whether a recording is in progress.
+func (s *Session) Recording() bool {
+	s.recMu.Lock()
+	defer s.recMu.Unlock()
+	return s.recording != nil
+}
+
+// StopRecording stops the recording in progress, see GoShot.StartRecording.
+func (s *Session) StopRecording() error {
+	s.recMu.Lock()
+	defer s.recMu.Unlock()
+	if s.recording == nil {
+		return ErrNotRecording
+	}
+	if s.recorder == nil {
+		return errors.New("recording not started yet")
+	}
+	s.recorder.Stop()
+	return nil
+}
+
+// SaveAnimation opens a file save dialog box to save the recording as an animated GIF, or
+// as an animated PNG if the file name ends with ".png" or ".apng".
+func (gs *GoShot) SaveAnimation() {
+	if len(gs.frames) == 0 {
+		gs.status.SetText("Not a recording: use \"Record region\" to record one.")
+		return
+	}
+	fileSave := dialog.NewFileSave(
+		func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				glog.Errorf("Failed to save animation: %s", err)
+				gs.status.SetText(fmt.Sprintf("Failed to save animation: %s", err))
+				return
+			}
+			if writer == nil {
+				gs.status.SetText("Save file cancelled.")
+				return
+			}
+			gs.App.Preferences().SetString(DefaultPathPreference, path.Dir(writer.URI().Path()))
+			frames := gs.renderFrames(gs.App.Preferences().BoolWithFallback(RecordAnnotationsPreference, true))
+			gs.status.SetText("Encoding animation ...")
+			go func() {
+				defer func() { _ = writer.Close() }()
+				encode := record.EncodeGIF
+				if ext := strings.ToLower(path.Ext(writer.URI().Path())); ext == ".png" || ext == ".apng" {
+					encode = record.EncodeAPNG
+				}
+				if err := encode(writer, frames); err != nil {
+					glog.Errorf("Failed to save animation to %q: %s", writer.URI(), err)
+					gs.status.SetText(fmt.Sprintf("Failed to save animation to %q: %s", writer.URI(), err))
+					return
+				}
+				gs.status.SetText(fmt.Sprintf("Saved animation to %q", writer.URI()))
+			}()
+		}, gs.Win)
+	fileSave.SetFileName(gs.DefaultName() + ".gif")
+	if defaultPath := gs.App.Preferences().String(DefaultPathPreference); defaultPath != "" {
+		if lister, err := storage.ListerForURI(storage.NewFileURI(defaultPath)); err == nil {
+			fileSave.SetLocation(lister)
+		}
+	}
+	size := gs.Win.Canvas().Size()
+	size.Width *= 0.90
+	size.Height *= 0.90
+	fileSave.Resize(size)
+	fileSave.Show()
+}
+
+// renderFrames returns the frames of the recording cropped like the screenshot and, if
+// `annotations` is set, with the filters (edits) applied.
+func (gs *GoShot) renderFrames(annotations bool) []record.Frame {
+	frames := make([]record.Frame, len(gs.frames))
+	for ii, frame := range gs.frames {
+		img := image.Image(frame.Image)
+		if annotations {
+			for _, filter := range gs.Filters {
+				img = filter.Apply(img)
+			}
+		}
+		rendered := image.NewRGBA(image.Rect(0, 0, gs.CropRect.Dx(), gs.CropRect.Dy()))
+		draw.Src.Draw(rendered, rendered.Rect, img, gs.CropRect.Min)
+		frames[ii] = record.Frame{Image: rendered, Delay: frame.Delay}
+	}
+	return frames
+}
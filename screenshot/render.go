@@ -0,0 +1,239 @@
+package screenshot
+
+import (
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// PixelGridPreference shows a grid around the pixels of the screenshot when zoomed in enough
+// (see minGridLog2Zoom), true by default.
+const PixelGridPreference = "PixelGrid"
+
+// minGridLog2Zoom is the minimum zoom (Log2Zoom) at which the pixel grid is shown: 800%.
+const minGridLog2Zoom = 3
+
+// renderedView is the view the ViewPort cache was rendered for: when only the view position
+// changes (panning), the cache is shifted and only the newly exposed strips are rendered.
+type renderedView struct {
+	valid        bool
+	viewX, viewY int
+	log2Zoom     float64
+	screenshot   *image.RGBA
+}
+
+// pixelSpans maps each pixel of the ViewPort, in one dimension, to the range of screenshot
+// pixels [from, to) it shows.
+type pixelSpans struct {
+	from, to []int
+}
+
+// newPixelSpans returns the spans of `n` ViewPort pixels, starting at the screenshot coordinate
+// `view`. When zoomed out (zoom > 1 screenshot pixels per ViewPort pixel) each ViewPort pixel
+// covers the screenshot pixels in its area, otherwise it shows the nearest one.
+func newPixelSpans(n, view int, zoom float64) pixelSpans {
+	spans := pixelSpans{from: make([]int, n), to: make([]int, n)}
+	for ii := 0; ii < n; ii++ {
+		if zoom <= 1 {
+			spans.from[ii] = int(math.Round(float64(ii)*zoom)) + view
+			spans.to[ii] = spans.from[ii] + 1
+			continue
+		}
+		spans.from[ii] = int(math.Floor(float64(ii)*zoom)) + view
+		spans.to[ii] = int(math.Floor(float64(ii+1)*zoom)) + view
+		if spans.to[ii] <= spans.from[ii] {
+			spans.to[ii] = spans.from[ii] + 1
+		}
+	}
+	return spans
+}
+
+// renderCache renders the whole cache, the view of the screenshot shown in the ViewPort, with
+// the overlays of the current operation and the rulers.
+func (vp *ViewPort) renderCache() {
+	if vp.cache == nil {
+		return
+	}
+	glog.V(2).Infof("renderCache(): cache=(%d x %d), zoom=%g, viewX=%d, viewY=%d, viewW=%d, viewH=%d",
+		vp.cache.Rect.Dx(), vp.cache.Rect.Dy(), vp.zoom(), vp.viewX, vp.viewY, vp.viewW, vp.viewH)
+	vp.renderRect(vp.cache.Rect)
+	vp.rendered = renderedView{valid: true, viewX: vp.viewX, viewY: vp.viewY, log2Zoom: vp.Log2Zoom, screenshot: vp.gs.Screenshot}
+	switch vp.currentOperation {
+	case Crop:
+		vp.drawCropOverlay()
+	case Measure:
+		vp.drawMeasureOverlay()
+	}
+	if vp.showRulers() {
+		vp.drawRulers()
+	}
+}
+
+// renderPan updates the cache after the view position changed: the part still visible is
+// shifted, and only the newly exposed strips are rendered. Everything is rendered if anything
+// else changed.
+//
+// When zoomed out the shift may be off by a fraction of a screenshot pixel, so the whole cache
+// should be rendered once the panning ends.
+func (vp *ViewPort) renderPan() {
+	r := vp.rendered
+	overlay := vp.currentOperation == Crop || vp.currentOperation == Measure
+	if !r.valid || r.log2Zoom != vp.Log2Zoom || r.screenshot != vp.gs.Screenshot || overlay || vp.cache == nil {
+		vp.renderCache()
+		return
+	}
+	zoom := vp.zoom()
+	shiftX := int(math.Round(float64(vp.viewX-r.viewX) / zoom))
+	shiftY := int(math.Round(float64(vp.viewY-r.viewY) / zoom))
+	w, h := vp.cache.Rect.Dx(), vp.cache.Rect.Dy()
+	if shiftX == 0 && shiftY == 0 {
+		return
+	}
+	if abs(shiftX) >= w || abs(shiftY) >= h {
+		vp.renderCache()
+		return
+	}
+	shiftCache(vp.cache, shiftX, shiftY)
+
+	// Render the exposed strips: columns on the left or right, rows on the top or bottom.
+	if shiftX > 0 {
+		vp.renderRect(image.Rect(w-shiftX, 0, w, h))
+	} else if shiftX < 0 {
+		vp.renderRect(image.Rect(0, 0, -shiftX, h))
+	}
+	if shiftY > 0 {
+		vp.renderRect(image.Rect(0, h-shiftY, w, h))
+	} else if shiftY < 0 {
+		vp.renderRect(image.Rect(0, 0, w, -shiftY))
+	}
+	if vp.showRulers() {
+		// The rulers were shifted along with the image: render where they moved to, and draw them
+		// again.
+		top, left := vp.rulerBands()
+		shift := image.Pt(shiftX, shiftY)
+		vp.renderRect(top.Sub(shift))
+		vp.renderRect(left.Sub(shift))
+		vp.drawRulers()
+	}
+	vp.rendered.viewX, vp.rendered.viewY = vp.viewX, vp.viewY
+}
+
+// shiftCache moves the content of the image by (-shiftX, -shiftY) pixels: the content at
+// (shiftX, shiftY) moves to (0, 0). The pixels exposed are left as they were.
+func shiftCache(img *image.RGBA, shiftX, shiftY int) {
+	const bytesPerPixel = 4 // RGBA.
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	rowLen := (w - abs(shiftX)) * bytesPerPixel
+	srcX, dstX := shiftX, 0
+	if shiftX < 0 {
+		srcX, dstX = 0, -shiftX
+	}
+	moveRow := func(y int) {
+		srcY := y + shiftY
+		if srcY < 0 || srcY >= h {
+			return
+		}
+		src := img.Pix[srcY*img.Stride+srcX*bytesPerPixel:]
+		dst := img.Pix[y*img.Stride+dstX*bytesPerPixel:]
+		copy(dst[:rowLen], src[:rowLen]) // copy handles overlapping slices.
+	}
+	if shiftY >= 0 {
+		for y := 0; y < h; y++ {
+			moveRow(y)
+		}
+	} else {
+		for y := h - 1; y >= 0; y-- {
+			moveRow(y)
+		}
+	}
+}
+
+// renderRect renders the rectangle of the cache, with its rows split among goroutines.
+func (vp *ViewPort) renderRect(rect image.Rectangle) {
+	rect = rect.Intersect(vp.cache.Rect)
+	if rect.Empty() {
+		return
+	}
+	zoom := vp.zoom()
+	cols := newPixelSpans(vp.cache.Rect.Dx(), vp.viewX, zoom)
+	rows := newPixelSpans(vp.cache.Rect.Dy(), vp.viewY, zoom)
+	grid := vp.Log2Zoom >= minGridLog2Zoom && vp.gs.App.Preferences().BoolWithFallback(PixelGridPreference, true)
+	gridSpace := vp.gridSpace()
+	// Offset of the background pattern, so it moves along with the screenshot.
+	bgX, bgY := int(math.Floor(float64(vp.viewX)/zoom)), int(math.Floor(float64(vp.viewY)/zoom))
+
+	numWorkers := runtime.NumCPU()
+	if numWorkers > rect.Dy() {
+		numWorkers = rect.Dy()
+	}
+	var wg sync.WaitGroup
+	wg.Add(numWorkers)
+	for worker := 0; worker < numWorkers; worker++ {
+		fromY := rect.Min.Y + worker*rect.Dy()/numWorkers
+		toY := rect.Min.Y + (worker+1)*rect.Dy()/numWorkers
+		go func() {
+			defer wg.Done()
+			for y := fromY; y < toY; y++ {
+				for x := rect.Min.X; x < rect.Max.X; x++ {
+					c, inside := averageSpan(vp.gs.Screenshot, cols.from[x], cols.to[x], rows.from[y], rows.to[y])
+					if !inside {
+						c = bgPattern(x+bgX, y+bgY)
+					} else if gridSpace > 0 && ((x > 0 && crossesGrid(cols.from[x-1], cols.from[x], gridSpace)) ||
+						(y > 0 && crossesGrid(rows.from[y-1], rows.from[y], gridSpace))) {
+						c = blendColor(c, gridColor, 0.6)
+					} else if grid && ((x > 0 && cols.from[x] != cols.from[x-1]) || (y > 0 && rows.from[y] != rows.from[y-1])) {
+						c = blendGrid(c)
+					}
+					vp.cache.SetRGBA(x, y, c)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// averageSpan returns the average of the pixels of the image in [fromX, toX) x [fromY, toY),
+// clipped to the image, and false if none is inside it. The image must start at (0, 0).
+func averageSpan(img *image.RGBA, fromX, toX, fromY, toY int) (color.RGBA, bool) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if fromX < 0 {
+		fromX = 0
+	}
+	if fromY < 0 {
+		fromY = 0
+	}
+	if toX > w {
+		toX = w
+	}
+	if toY > h {
+		toY = h
+	}
+	if fromX >= toX || fromY >= toY {
+		return color.RGBA{}, false
+	}
+	if toX-fromX == 1 && toY-fromY == 1 {
+		return img.RGBAAt(fromX, fromY), true
+	}
+	var r, g, b, a uint32
+	for y := fromY; y < toY; y++ {
+		row := img.Pix[y*img.Stride+fromX*4 : y*img.Stride+toX*4]
+		for ii := 0; ii < len(row); ii += 4 {
+			r += uint32(row[ii])
+			g += uint32(row[ii+1])
+			b += uint32(row[ii+2])
+			a += uint32(row[ii+3])
+		}
+	}
+	n := uint32((toX - fromX) * (toY - fromY))
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)}, true
+}
+
+// blendGrid returns the color of the pixel grid drawn over `c`.
+func blendGrid(c color.RGBA) color.RGBA {
+	const gray, alpha = 128, 0.35 // Premultiplied gray, and its opacity.
+	blend := func(v uint8, grayValue float64) uint8 { return uint8(float64(v)*(1-alpha) + grayValue*alpha + 0.5) }
+	return color.RGBA{R: blend(c.R, gray), G: blend(c.G, gray), B: blend(c.B, gray), A: blend(c.A, 255)}
+}
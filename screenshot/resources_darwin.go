@@ -7,5 +7,6 @@ package screenshot
 const (
 	SaveShortcutDesc  = "⌘+S"
 	CopyShortcutDesc  = "⌘+C"
+	PasteShortcutDesc = "⌘+V"
 	DriveShortcutDesc = "⌘+g"
 )
@@ -8,5 +8,6 @@ package screenshot
 const (
 	SaveShortcutDesc  = "ctrl+s"
 	CopyShortcutDesc  = "ctrl+c"
+	PasteShortcutDesc = "ctrl+v"
 	DriveShortcutDesc = "ctrl+g"
 )
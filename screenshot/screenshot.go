@@ -1,12 +1,13 @@
 // Package screenshot implements the screenshot edit window.
 //
-// It's the main part of the application: it may be run after a
-// fork(), if the main program was started as a system tray app.
+// It's the main part of the application: each screenshot is edited in its own
+// window, and the windows of one process share a Session.
 package screenshot
 
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -19,7 +20,10 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/golang/glog"
 	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/filters"
 	"github.com/janpfeifer/goshot/googledrive"
+	"github.com/janpfeifer/goshot/history"
+	"github.com/janpfeifer/goshot/record"
 	"github.com/kbinani/screenshot"
 	"image"
 	"image/color"
@@ -27,18 +31,53 @@ import (
 	"image/png"
 	"path"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type GoShot struct {
 	// Fyne: Application and Window
-	App fyne.App
-	Win fyne.Window // Main window.
+	App     fyne.App
+	Win     fyne.Window // Main window.
+	Session *Session    // Shared with the other windows of the process.
+
+	// Display is the index of the display captured.
+	Display int
+
+	// Sequence number of the screenshot, and Title of the window captured (empty if not a
+	// window). Used in the file names, see ExpandFileNameTemplate.
+	Sequence int
+	Title    string
 
 	// Original screenshot information
 	OriginalScreenshot *image.RGBA
 	ScreenshotTime     time.Time
 
+	// Cursor is the image of the mouse cursor when the screenshot was taken, if it was captured
+	// (X11 only). CursorPosition is where it points to, in the coordinates of OriginalScreenshot,
+	// and CursorHotspot is that point in the Cursor image. It's drawn by cursorFilter, if shown.
+	Cursor         *image.RGBA
+	CursorHotspot  image.Point
+	CursorPosition image.Point
+	cursorFilter   *filters.Image
+
+	// captureBounds is the rectangle of the screen captured in OriginalScreenshot, if its pixels
+	// map to the screen (e.g. not for pasted images). Used by ScrollingCapture.
+	captureBounds image.Rectangle
+
+	// afterRegion is called once a region is selected, see selectRegionThen.
+	afterRegion func()
+
+	// crop is the state of the crop tool, see StartCrop.
+	crop cropTool
+
+	// measure is the state of the Measure operation.
+	measure measureTool
+
+	// frames of the recording, if the screenshot is one (its first frame), see StartRecording.
+	frames []record.Frame
+
 	// Edited screenshot
 	Screenshot *image.RGBA // The edited/composed screenshot
 	CropRect   image.Rectangle
@@ -48,22 +87,67 @@ type GoShot struct {
 	zoomEntry, thicknessEntry *widget.Entry
 	colorSample               *canvas.Rectangle
 	status                    *widget.Label
+	inspector                 *widget.Label
+	cursorCheck               *widget.Check
+	frameSelect               *widget.Select
+	frameMenu                 *fyne.Menu
+	styleSelect               *widget.Select
+	styleMenu                 *fyne.Menu
+	paletteBox                *fyne.Container
 	viewPort                  *ViewPort
 	viewPortScroll            *container.Scroll
 	miniMap                   *MiniMap
 
+	// colorSlot is the color set by the palette, the color picker and the eyedropper.
+	colorSlot colorSlot
+
+	// selectedStyle is the name of the annotation style preset last selected.
+	selectedStyle string
+
+	// restyles of annotations, the last one first undone by UndoLastFilter.
+	restyles []restyleEdit
+
+	// inspectedColor is the last color shown by the pixel inspector or picked by the eyedropper,
+	// if inspectedValid. See CopyInspectedColor.
+	inspectedColor color.NRGBA
+	inspectedValid bool
+
+	// Automatic saving: autoSavePath is set once the screenshot is saved. edited is set when the
+	// image is cropped or transformed (rotated, resized, etc.), which the Filters don't record.
+	autoSaveMu   sync.Mutex
+	autoSavePath string
+	edited       bool
+
+	// shortcuts registered in the window canvas, removed when the window is closed.
+	shortcuts []fyne.Shortcut
+
+	// closed is set when the window is closed, after which no more work is queued to it.
+	// Protected by closedMu, see runOnUI.
+	closedMu sync.Mutex
+	closed   bool
+
 	shortcutsDialog         dialog.Dialog
 	delayedScreenshotDialog dialog.Dialog
 
-	// GoogleDrive manager
-	gDrive          *googledrive.Manager
+	// Number of times the screenshot was shared in GoogleDrive.
 	gDriveNumShared int
+
+	// Upload in progress: cancelUpload is set while an upload is ongoing, protected by uploadMu.
+	uploadBox      *fyne.Container
+	uploadProgress *widget.ProgressBar
+	uploadCancel   *widget.Button
+	uploadMu       sync.Mutex
+	cancelUpload   context.CancelFunc
 }
 
 type ImageFilter interface {
 	// Apply filter, shifted (dx, dy) pixels -- e.g. if a filter draws a circle on
 	// top of the image, it should add (dx, dy) to the circle center.
 	Apply(image image.Image) image.Image
+
+	// Transform moves the filter along with the image, when it's rotated, flipped, resized or
+	// padded: `mapPoint` maps the points of the image, and `scale` is the factor of the sizes.
+	Transform(mapPoint func(image.Point) image.Point, scale float64)
 }
 
 // ApplyFilters will apply `Filters` to the `CropRect` of the original image
@@ -94,7 +178,6 @@ func (gs *GoShot) ApplyFilters(full bool) {
 	}
 
 	if gs.viewPort != nil {
-		gs.viewPort.renderCache()
 		gs.viewPort.Refresh()
 	}
 	if gs.miniMap != nil {
@@ -103,50 +186,139 @@ func (gs *GoShot) ApplyFilters(full bool) {
 	}
 }
 
+// Run takes a screenshot and runs the edit window.
 func Run() {
-	gs := &GoShot{
-		App: app.NewWithID("GoShot"),
-	}
-	if err := gs.MakeScreenshot(); err != nil {
-		glog.Fatalf("Failed to capture screenshot: %s", err)
+	RunWithOptions(CaptureOptions{})
+}
+
+// RunWithOptions takes a screenshot as configured by `opts` and runs the edit window, until
+// it is closed.
+func RunWithOptions(opts CaptureOptions) {
+	s := NewSession(app.NewWithID("GoShot"))
+	if _, err := s.Capture(opts); err != nil {
+		glog.Fatalf("%s", err)
 	}
-	gs.BuildEditWindow()
-	gs.Win.ShowAndRun()
-	gs.miniMap.updateViewPortRect()
-	gs.miniMap.Refresh()
+	s.App.Run()
 }
 
 func (gs *GoShot) MakeScreenshot() error {
-	n := screenshot.NumActiveDisplays()
-	if n != 1 {
-		glog.Warningf("No support for multiple displays yet (should be relatively easy to add), screenshotting first display.")
+	if n := screenshot.NumActiveDisplays(); n > 1 {
+		glog.V(1).Infof("Capturing display %d of %d", gs.Display, n)
 	}
-	bounds := screenshot.GetDisplayBounds(0)
-	var err error
-	gs.Screenshot, err = screenshot.CaptureRect(bounds)
+	bounds := screenshot.GetDisplayBounds(gs.Display)
+	cursor := captureCursor()
+	img, err := screenshot.CaptureRect(bounds)
 	if err != nil {
 		return err
 	}
+	gs.Title = ""
+	gs.setCapture(img)
+	gs.captureBounds = bounds
+	gs.setCursor(cursor, bounds.Min)
+	glog.V(2).Infof("Screenshot captured bounds: %+v\n", bounds)
+	return nil
+}
+
+// setCapture sets the newly captured image as the screenshot to edit, keeps it in the
+// history and saves it automatically, if configured.
+func (gs *GoShot) setCapture(img *image.RGBA) {
+	gs.Screenshot = img
 	gs.OriginalScreenshot = gs.Screenshot
 	gs.ScreenshotTime = time.Now()
 	gs.CropRect = gs.Screenshot.Bounds()
+	gs.captureBounds = image.Rectangle{}
+	gs.frames = nil
+	gs.Cursor, gs.cursorFilter = nil, nil
+	gs.Sequence = nextSequence(gs.App.Preferences())
+	go recordCapture(gs.OriginalScreenshot, gs.ScreenshotTime)
+	gs.resetAutoSave()
+	go gs.autoSave(gs.OriginalScreenshot, true)
+}
 
-	glog.V(2).Infof("Screenshot captured bounds: %+v\n", bounds)
-	return nil
+// recordCapture keeps the screenshot in the history, to be reopened later.
+func recordCapture(img image.Image, t time.Time) {
+	if _, err := history.AddCapture(img, t); err != nil {
+		glog.Errorf("Failed to keep screenshot in history: %v", err)
+	}
+}
+
+// Displays returns the bounds of the active displays, indexed as in CaptureOptions.Display.
+func Displays() []image.Rectangle {
+	displays := make([]image.Rectangle, screenshot.NumActiveDisplays())
+	for ii := range displays {
+		displays[ii] = screenshot.GetDisplayBounds(ii)
+	}
+	return displays
 }
 
-// UndoLastFilter cancels the last filter applied, and regenerates everything.
+// ReplaceScreenshot replaces the image being edited, e.g. with one pasted from the clipboard.
+// The crop and the filters (edits) are reset.
+func (gs *GoShot) ReplaceScreenshot(img image.Image) {
+	rgba := toRGBA(img)
+	gs.OriginalScreenshot = rgba
+	gs.Screenshot = rgba
+	gs.ScreenshotTime = time.Now()
+	gs.CropRect = rgba.Rect
+	gs.captureBounds = image.Rectangle{}
+	gs.frames = nil
+	gs.Cursor, gs.cursorFilter = nil, nil
+	gs.Filters = nil
+	gs.resetAutoSave() // Only saved if edited.
+	gs.refreshScreenshot()
+}
+
+// refreshScreenshot updates the edit window after the original screenshot is replaced.
+func (gs *GoShot) refreshScreenshot() {
+	gs.Win.SetTitle(fmt.Sprintf("GoShot: screenshot @ %s", gs.ScreenshotTime.Format("2006-01-02 15:04:05")))
+	gs.viewPort.viewX, gs.viewPort.viewY = 0, 0
+	gs.updateCursorCheck()
+	gs.ApplyFilters(true)
+	gs.viewPort.postCrop()
+}
+
+// toRGBA returns a copy of the image as RGBA, with its bounds starting at (0, 0).
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Src.Draw(rgba, rgba.Rect, img, bounds.Min)
+	return rgba
+}
+
+// SelectRegion starts the selection of a region of the screenshot with the crop tool: the user
+// drags a rectangle, adjusts it with the handles, and presses Enter to crop the screenshot.
+func (gs *GoShot) SelectRegion() {
+	gs.StartCrop(true)
+	gs.status.SetText("Select region: drag a rectangle, adjust it with the handles, and press Enter")
+}
+
+// selectRegionThen starts the selection of a region, like SelectRegion, and calls `then` once
+// it's selected. `purpose` is shown in the status bar, e.g. "Select region to record".
+func (gs *GoShot) selectRegionThen(purpose string, then func()) {
+	gs.SelectRegion()
+	gs.afterRegion = then
+	gs.status.SetText(purpose + ": drag a rectangle and press Enter")
+}
+
+// UndoLastFilter cancels the last filter applied, or the last restyle of an annotation if it
+// was done after it, and regenerates everything.
 func (gs *GoShot) UndoLastFilter() {
+	if gs.undoRestyle() {
+		return
+	}
 	if len(gs.Filters) > 0 {
+		if gs.Filters[len(gs.Filters)-1] == gs.cursorFilter {
+			gs.cursorFilter = nil
+			gs.updateCursorCheck()
+		}
 		gs.Filters = gs.Filters[:len(gs.Filters)-1]
 		gs.ApplyFilters(true)
 	}
 }
 
-// DefaultName returns a default name to the screenshot, based on date/time it was made.
+// DefaultName returns a default name to the screenshot, from the file name template in the
+// preferences (FileNameTemplatePreference).
 func (gs *GoShot) DefaultName() string {
-	return fmt.Sprintf("Screenshot %s",
-		gs.ScreenshotTime.Format("2006-01-02 15-04-02"))
+	return gs.ExpandFileNameTemplate(FileNameTemplate(gs.App.Preferences()))
 }
 
 // GetColorPreference returns the color set for the given key if it has been set.
@@ -198,7 +370,7 @@ func (gs *GoShot) SaveImage() {
 			gs.App.Preferences().SetString(DefaultPathPreference, defaultPath)
 
 			var contentBuffer bytes.Buffer
-			_ = png.Encode(&contentBuffer, gs.Screenshot)
+			_ = png.Encode(&contentBuffer, gs.exportImage())
 			content := contentBuffer.Bytes()
 			_, err = writer.Write(content)
 			if err != nil {
@@ -224,28 +396,171 @@ func (gs *GoShot) SaveImage() {
 	fileSave.Show()
 }
 
-func (gs *GoShot) CopyImageToClipboard() {
+// CopyImageToClipboard copies the edited screenshot to the clipboard, and reports it in the status bar.
+func (gs *GoShot) CopyImageToClipboard() error {
 	glog.V(2).Info("GoShot.CopyImageToClipboard")
-	err := clipboard.CopyImage(gs.Screenshot)
+	err := clipboard.CopyImage(gs.exportImage())
 	if err != nil {
 		glog.Errorf("Failed to copy to clipboard: %s", err)
 		gs.status.SetText(fmt.Sprintf("Failed to copy to clipboard: %s", err))
 	} else {
 		gs.status.SetText(fmt.Sprintf("Screenshot copied to clipboard"))
 	}
+	return err
 }
 
 const (
-	GoogleDriveTokenPreference = "google_drive_token"
+	GoogleDriveTokenPreference       = "google_drive_token"
+	GoogleDriveFolderPreference      = "google_drive_folder"
+	GoogleDriveSharedDrivePreference = "google_drive_shared_drive"
+	GoogleDrivePermissionPreference  = "google_drive_share_permission" // googledrive.Permission, private if not set.
+	GoogleDriveDomainPreference      = "google_drive_domain"
+	GoogleDriveUsersPreference       = "google_drive_users"
+	GoogleDriveLinkPreference        = "google_drive_link"
 )
 
 var (
 	GoogleDrivePath = []string{"GoShot"}
 )
 
+// legacyGoogleDrivePermissionPreference held the permission in its previous order, where 0 (and
+// so not set) was "anyone with the link". It's migrated to GoogleDrivePermissionPreference.
+const legacyGoogleDrivePermissionPreference = "google_drive_permission"
+
+// legacyGoogleDrivePermissions maps the values of legacyGoogleDrivePermissionPreference.
+var legacyGoogleDrivePermissions = []googledrive.Permission{
+	googledrive.PermissionAnyone, googledrive.PermissionDomain, googledrive.PermissionUsers, googledrive.PermissionPrivate}
+
+// ShareWithGoogleDrive opens the dialog with the sharing options, and if confirmed,
+// uploads the image to Google Drive and copies its URL to the clipboard.
 func (gs *GoShot) ShareWithGoogleDrive() {
 	glog.V(2).Infof("GoShot.ShareWithGoogleDrive")
-	ctx := context.Background()
+	opts := gs.GoogleDriveShareOptions()
+
+	folderEntry := widget.NewEntry()
+	folderEntry.SetPlaceHolder(strings.Join(GoogleDrivePath, "/"))
+	folderEntry.SetText(strings.Join(opts.Path, "/"))
+	sharedDriveEntry := widget.NewEntry()
+	sharedDriveEntry.SetPlaceHolder("My Drive")
+	sharedDriveEntry.SetText(opts.SharedDriveID)
+	domainEntry := widget.NewEntry()
+	domainEntry.SetPlaceHolder("example.com")
+	domainEntry.SetText(opts.Domain)
+	usersEntry := widget.NewEntry()
+	usersEntry.SetPlaceHolder("alice@example.com, bob@example.com")
+	usersEntry.SetText(strings.Join(opts.Users, ", "))
+
+	permissionSelect := widget.NewSelect(googledrive.PermissionNames, func(name string) {
+		domainEntry.Disable()
+		usersEntry.Disable()
+		switch name {
+		case googledrive.PermissionNames[googledrive.PermissionDomain]:
+			domainEntry.Enable()
+		case googledrive.PermissionNames[googledrive.PermissionUsers]:
+			usersEntry.Enable()
+		}
+	})
+	permissionSelect.SetSelectedIndex(int(opts.Permission))
+	linkRadio := widget.NewRadioGroup(googledrive.LinkTypeNames, nil)
+	linkRadio.Required = true
+	linkRadio.SetSelected(googledrive.LinkTypeNames[opts.Link])
+	frameSelect := widget.NewSelect(gs.frameNames(), nil)
+	frameSelect.SetSelected(gs.selectedFrameName())
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Folder", folderEntry),
+		widget.NewFormItem("Shared Drive ID", sharedDriveEntry),
+		widget.NewFormItem("Share with", permissionSelect),
+		widget.NewFormItem("Domain", domainEntry),
+		widget.NewFormItem("Users", usersEntry),
+		widget.NewFormItem("Link", linkRadio),
+		widget.NewFormItem("Frame", frameSelect),
+	}
+	form := dialog.NewForm("Share in Google Drive", "Share", "Cancel", items,
+		func(confirm bool) {
+			if !confirm {
+				gs.status.SetText("Sharing cancelled.")
+				return
+			}
+			opts = googledrive.ShareOptions{
+				Path:          splitAndTrim(folderEntry.Text, "/"),
+				SharedDriveID: strings.TrimSpace(sharedDriveEntry.Text),
+				Permission:    googledrive.Permission(permissionSelect.SelectedIndex()),
+				Domain:        strings.TrimSpace(domainEntry.Text),
+				Users:         splitAndTrim(usersEntry.Text, ","),
+			}
+			if linkRadio.Selected == googledrive.LinkTypeNames[googledrive.DirectLink] {
+				opts.Link = googledrive.DirectLink
+			}
+			gs.SetGoogleDriveShareOptions(opts)
+			gs.SetFramePreset(frameSelect.Selected)
+			gs.shareWithGoogleDrive(context.Background(), opts, nil)
+		}, gs.Win)
+	form.Resize(fyne.NewSize(500, 400))
+	form.Show()
+}
+
+// GoogleDriveShareOptions returns the Google Drive sharing options saved in the preferences.
+func (gs *GoShot) GoogleDriveShareOptions() (opts googledrive.ShareOptions) {
+	prefs := gs.App.Preferences()
+	opts.Path = splitAndTrim(prefs.String(GoogleDriveFolderPreference), "/")
+	opts.SharedDriveID = prefs.String(GoogleDriveSharedDrivePreference)
+	if legacy := prefs.Int(legacyGoogleDrivePermissionPreference); legacy > 0 && legacy < len(legacyGoogleDrivePermissions) {
+		// A value of 0, "anyone with the link", can't be told apart from not set: it's not
+		// migrated, and the image is kept private until the user chooses otherwise.
+		prefs.SetInt(GoogleDrivePermissionPreference, int(legacyGoogleDrivePermissions[legacy]))
+	}
+	prefs.RemoveValue(legacyGoogleDrivePermissionPreference)
+	opts.Permission = googledrive.Permission(prefs.Int(GoogleDrivePermissionPreference))
+	if opts.Permission < 0 || int(opts.Permission) >= len(googledrive.PermissionNames) {
+		opts.Permission = googledrive.PermissionPrivate
+	}
+	opts.Domain = prefs.String(GoogleDriveDomainPreference)
+	opts.Users = splitAndTrim(prefs.String(GoogleDriveUsersPreference), ",")
+	opts.Link = googledrive.LinkType(prefs.Int(GoogleDriveLinkPreference))
+	if opts.Link < 0 || int(opts.Link) >= len(googledrive.LinkTypeNames) {
+		opts.Link = googledrive.WebViewLink
+	}
+	return
+}
+
+// SetGoogleDriveShareOptions saves the Google Drive sharing options in the preferences.
+func (gs *GoShot) SetGoogleDriveShareOptions(opts googledrive.ShareOptions) {
+	prefs := gs.App.Preferences()
+	prefs.SetString(GoogleDriveFolderPreference, strings.Join(opts.Path, "/"))
+	prefs.SetString(GoogleDriveSharedDrivePreference, opts.SharedDriveID)
+	prefs.SetInt(GoogleDrivePermissionPreference, int(opts.Permission))
+	prefs.SetString(GoogleDriveDomainPreference, opts.Domain)
+	prefs.SetString(GoogleDriveUsersPreference, strings.Join(opts.Users, ","))
+	prefs.SetInt(GoogleDriveLinkPreference, int(opts.Link))
+}
+
+// splitAndTrim splits `s` by `sep`, trims spaces of each part, and discards empty parts.
+func splitAndTrim(s, sep string) (parts []string) {
+	for _, part := range strings.Split(s, sep) {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return
+}
+
+// shareWithGoogleDrive uploads the image to Google Drive with the given options, in a separate goroutine.
+// The progress is displayed in the status bar, and the upload can be cancelled with CancelUpload,
+// or by cancelling `ctx`. If `done` is not nil, it is called with the URL of the shared image, or
+// the error, when finished.
+func (gs *GoShot) shareWithGoogleDrive(ctx context.Context, opts googledrive.ShareOptions, done func(url string, err error)) {
+	if done == nil {
+		done = func(string, error) {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if !gs.startUpload(cancel) {
+		cancel()
+		gs.status.SetText("An upload is already in progress, cancel it first.")
+		done("", errors.New("an upload is already in progress"))
+		return
+	}
 
 	gs.status.SetText("Connecting to GoogleDrive ...")
 	fileName := gs.DefaultName()
@@ -255,43 +570,168 @@ func (gs *GoShot) ShareWithGoogleDrive() {
 		// a different name for each.
 		fileName = fmt.Sprintf("%s_%d", fileName, gs.gDriveNumShared)
 	}
+	opts.Progress = func(sent, total int64) {
+		if total <= 0 {
+			return
+		}
+		ratio := float64(sent) / float64(total)
+		gs.uploadProgress.SetValue(ratio)
+		gs.status.SetText(fmt.Sprintf("Uploading to GoogleDrive: %.0f%% of %d KB ...", 100*ratio, total/1024))
+	}
 
 	go func() {
-		if gs.gDrive == nil {
-			// Create googledrive.Manager.
-			token := gs.App.Preferences().String(GoogleDriveTokenPreference)
-			var err error
-			gs.gDrive, err = googledrive.New(ctx, GoogleDrivePath, token,
-				func(token string) { gs.App.Preferences().SetString(GoogleDriveTokenPreference, token) },
-				gs.askForGoogleDriveAuthorization)
-			if err != nil {
-				glog.Errorf("Failed to connect to Google Drive: %s", err)
-				gs.status.SetText(fmt.Sprintf("GoogleDrive failed: %v", err))
-				return
-			}
-		}
+		defer gs.endUpload()
+		url, err := gs.uploadToGoogleDrive(ctx, fileName, opts)
+		done(url, err)
+	}()
+}
 
-		// Sharing the image must happen in a separate goroutine because the UI must
-		// remain interactive, also in order to capture the authorization input
-		// from the user.
-		url, err := gs.gDrive.ShareImage(ctx, fileName, gs.Screenshot)
-		if err != nil {
-			glog.Errorf("Failed to share image in Google Drive: %s", err)
-			gs.status.SetText(fmt.Sprintf("GoogleDrive failed: %v", err))
-			return
-		}
-		glog.Infof("GoogleDrive's shared URL:\t%s", url)
-		err = clipboard.CopyText(url)
-		if err == nil {
-			gs.status.SetText("Image shared in GoogleDrive, URL copied to clipboard.")
-		} else {
-			gs.status.SetText("Image shared in GoogleDrive, but failed to copy to clipboard, see URL and error in the logs.")
-			glog.Errorf("Failed to copy URL to clipboard: %v", err)
+// uploadToGoogleDrive implements shareWithGoogleDrive: it uploads the image, records it in
+// the history and copies its URL to the clipboard.
+func (gs *GoShot) uploadToGoogleDrive(ctx context.Context, fileName string, opts googledrive.ShareOptions) (string, error) {
+	gDrive, err := gs.Session.GoogleDrive(ctx, gs.Win)
+	if err != nil {
+		glog.Errorf("Failed to connect to Google Drive: %s", err)
+		gs.status.SetText(fmt.Sprintf("GoogleDrive failed: %v", err))
+		return "", err
+	}
+
+	// Sharing the image must happen in a separate goroutine because the UI must
+	// remain interactive, also in order to capture the authorization input
+	// from the user.
+	img := gs.exportImage()
+	url, fileID, err := gDrive.ShareImage(ctx, fileName, img, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			glog.Infof("Sharing image in Google Drive cancelled: %v", err)
+			gs.status.SetText("GoogleDrive upload cancelled.")
+			return "", err
 		}
-	}()
+		glog.Errorf("Failed to share image in Google Drive: %s", err)
+		gs.status.SetText(fmt.Sprintf("GoogleDrive failed: %v", err))
+		return "", err
+	}
+	glog.Infof("GoogleDrive's shared URL:\t%s", url)
+	err = history.Add(history.Entry{
+		Time:     time.Now(),
+		Backend:  history.GoogleDrive,
+		RemoteID: fileID,
+		Name:     fileName + ".png",
+		URL:      url,
+	}, img)
+	if err != nil {
+		glog.Errorf("Failed to record shared image in history: %v", err)
+	}
+	err = clipboard.CopyText(url)
+	if err == nil {
+		gs.status.SetText("Image shared in GoogleDrive, URL copied to clipboard.")
+	} else {
+		gs.status.SetText("Image shared in GoogleDrive, but failed to copy to clipboard, see URL and error in the logs.")
+		glog.Errorf("Failed to copy URL to clipboard: %v", err)
+	}
+	return url, nil
+}
+
+// ShareWithGoogleDriveAndWait uploads the image to Google Drive with the options saved in the
+// preferences, without asking, and waits for it to finish. It returns the URL of the shared image.
+// The upload is started in the window's event loop, and it is cancelled if `ctx` is done first.
+func (gs *GoShot) ShareWithGoogleDriveAndWait(ctx context.Context) (string, error) {
+	type result struct {
+		url string
+		err error
+	}
+	resultChan := make(chan result, 1)
+	started := gs.runOnUI(func() {
+		gs.shareWithGoogleDrive(ctx, gs.GoogleDriveShareOptions(), func(url string, err error) {
+			resultChan <- result{url, err}
+		})
+	})
+	if !started {
+		return "", errors.New("the screenshot window was closed")
+	}
+	select {
+	case r := <-resultChan:
+		return r.url, r.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("sharing in Google Drive interrupted: %w", ctx.Err())
+	}
+}
+
+// eventQueuer is implemented by the windows of the Fyne desktop driver: the callbacks queued
+// are run in order, along with the handling of the window's input events.
+type eventQueuer interface {
+	QueueEvent(fn func())
 }
 
-func (gs *GoShot) askForGoogleDriveAuthorization() string {
+// runOnUI runs `fn` in the event loop of the window, so it doesn't run concurrently with the
+// handling of the user's input. It is used for work started from other goroutines, e.g. the
+// control socket. It returns false, without running `fn`, if the window was already closed.
+func (gs *GoShot) runOnUI(fn func()) bool {
+	gs.closedMu.Lock()
+	defer gs.closedMu.Unlock()
+	if gs.closed {
+		return false
+	}
+	if queuer, ok := gs.Win.(eventQueuer); ok {
+		queuer.QueueEvent(fn)
+	} else {
+		go fn()
+	}
+	return true
+}
+
+// startUpload shows the upload progress bar and the cancel button in the status bar. It returns
+// false, and does nothing, if another upload is already in progress.
+func (gs *GoShot) startUpload(cancel context.CancelFunc) bool {
+	gs.uploadMu.Lock()
+	if gs.cancelUpload != nil {
+		gs.uploadMu.Unlock()
+		return false
+	}
+	gs.cancelUpload = cancel
+	gs.uploadMu.Unlock()
+	gs.uploadProgress.SetValue(0)
+	gs.uploadCancel.Enable()
+	gs.uploadBox.Show()
+	return true
+}
+
+// endUpload hides the upload progress bar and releases the upload context.
+func (gs *GoShot) endUpload() {
+	gs.uploadMu.Lock()
+	cancel := gs.cancelUpload
+	gs.cancelUpload = nil
+	gs.uploadMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	gs.uploadBox.Hide()
+}
+
+// CancelUpload interrupts the upload in progress, if any.
+func (gs *GoShot) CancelUpload() {
+	gs.uploadMu.Lock()
+	cancel := gs.cancelUpload
+	gs.uploadMu.Unlock()
+	if cancel == nil {
+		return
+	}
+	glog.V(2).Info("GoShot.CancelUpload")
+	cancel()
+	gs.uploadCancel.Disable()
+	gs.status.SetText("Cancelling upload ...")
+}
+
+// newGoogleDriveManager creates a googledrive.Manager using the token saved in the preferences.
+// If a new authorization is needed, it asks the user for it with a dialog on the given window.
+func newGoogleDriveManager(ctx context.Context, a fyne.App, win fyne.Window) (*googledrive.Manager, error) {
+	token := a.Preferences().String(GoogleDriveTokenPreference)
+	return googledrive.New(ctx, GoogleDrivePath, token,
+		func(token string) { a.Preferences().SetString(GoogleDriveTokenPreference, token) },
+		func() string { return askForGoogleDriveAuthorization(win) })
+}
+
+func askForGoogleDriveAuthorization(win fyne.Window) string {
 	replyChan := make(chan string, 1)
 
 	// Create dialog to get the authorization from the user.
@@ -308,41 +748,98 @@ func (gs *GoShot) askForGoogleDriveAuthorization() string {
 			} else {
 				replyChan <- ""
 			}
-		}, gs.Win)
+		}, win)
 	form.Resize(fyne.NewSize(500, 300))
 	form.Show()
-	gs.Win.Canvas().Focus(textEntry)
+	win.Canvas().Focus(textEntry)
 
 	return <-replyChan
 }
 
+// addShortcut registers the shortcut in the window canvas, until the window is closed.
+func (gs *GoShot) addShortcut(shortcut fyne.Shortcut, handler func(shortcut fyne.Shortcut)) {
+	gs.Win.Canvas().AddShortcut(shortcut, handler)
+	gs.shortcuts = append(gs.shortcuts, shortcut)
+}
+
+// onWindowClosed is called when the edit window is closed: it saves the edits, if configured,
+// and stops everything tied to the window. In long-running sessions the window is kept as
+// spare, so nothing it holds must keep referring to this GoShot.
+func (gs *GoShot) onWindowClosed() {
+	gs.closedMu.Lock()
+	gs.closed = true
+	gs.closedMu.Unlock()
+	gs.autoSaveEdits()
+	gs.CancelUpload()
+	gs.viewPort.Close()
+
+	c := gs.Win.Canvas()
+	for _, shortcut := range gs.shortcuts {
+		c.RemoveShortcut(shortcut)
+	}
+	gs.shortcuts = nil
+	c.SetOnTypedKey(nil)
+	if deskCanvas, ok := c.(desktop.Canvas); ok {
+		deskCanvas.SetOnKeyDown(nil)
+		deskCanvas.SetOnKeyUp(nil)
+	}
+}
+
 // RegisterShortcuts adds all the shortcuts and keys GoShot
 // listens to.
 // When updating here, please update also the `gs.ShowShortcutsPage()`
 // method to reflect the changes.
 func (gs *GoShot) RegisterShortcuts() {
-	gs.Win.Canvas().AddShortcut(
+	gs.addShortcut(
 		&fyne.ShortcutCopy{},
 		func(_ fyne.Shortcut) { gs.CopyImageToClipboard() })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyJ, Modifier: desktop.AltModifier},
-		func(_ fyne.Shortcut) { gs.viewPort.SetOp(CropTopLeft) })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyK, Modifier: desktop.AltModifier},
-		func(_ fyne.Shortcut) { gs.viewPort.SetOp(CropBottomRight) })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyC, Modifier: desktop.AltModifier},
+	gs.addShortcut(
+		&fyne.ShortcutPaste{},
+		func(_ fyne.Shortcut) { gs.PasteImageFromClipboard() })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyJ, Modifier: desktop.AltModifier},
+		func(_ fyne.Shortcut) { gs.StartCrop(false) })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyC, Modifier: desktop.AltModifier},
 		func(_ fyne.Shortcut) { gs.viewPort.SetOp(DrawCircle) })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyT, Modifier: desktop.AltModifier},
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyT, Modifier: desktop.AltModifier},
 		func(_ fyne.Shortcut) { gs.viewPort.SetOp(DrawText) })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyA, Modifier: desktop.AltModifier},
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyA, Modifier: desktop.AltModifier},
 		func(_ fyne.Shortcut) { gs.viewPort.SetOp(DrawArrow) })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: desktop.ControlModifier},
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyE, Modifier: desktop.AltModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.SetOp(Eyedropper) })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyM, Modifier: desktop.AltModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.SetOp(Measure) })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyR, Modifier: desktop.AltModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.SetOp(Restyle) })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyC, Modifier: desktop.ControlModifier | desktop.ShiftModifier},
+		func(_ fyne.Shortcut) { gs.CopyInspectedColor() })
+	for ii, key := range []fyne.KeyName{fyne.Key1, fyne.Key2, fyne.Key3, fyne.Key4, fyne.Key5, fyne.Key6, fyne.Key7, fyne.Key8, fyne.Key9} {
+		ii := ii
+		gs.addShortcut(&desktop.CustomShortcut{KeyName: key, Modifier: desktop.AltModifier},
+			func(_ fyne.Shortcut) { gs.SelectStyle(ii, false) })
+		gs.addShortcut(&desktop.CustomShortcut{KeyName: key, Modifier: desktop.AltModifier | desktop.ShiftModifier},
+			func(_ fyne.Shortcut) { gs.SelectStyle(ii, true) })
+	}
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: desktop.ControlModifier},
 		func(_ fyne.Shortcut) { gs.UndoLastFilter() })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyS, Modifier: desktop.ControlModifier},
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyS, Modifier: desktop.ControlModifier},
 		func(_ fyne.Shortcut) { gs.SaveImage() })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyG, Modifier: desktop.ControlModifier},
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyG, Modifier: desktop.ControlModifier},
 		func(_ fyne.Shortcut) { gs.ShareWithGoogleDrive() })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeySlash, Modifier: desktop.ControlModifier},
+	for _, key := range []fyne.KeyName{fyne.KeyPlus, fyne.KeyEqual} {
+		gs.addShortcut(&desktop.CustomShortcut{KeyName: key, Modifier: desktop.ControlModifier},
+			func(_ fyne.Shortcut) { gs.viewPort.ZoomIn() })
+	}
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyMinus, Modifier: desktop.ControlModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.ZoomOut() })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.Key0, Modifier: desktop.ControlModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.ZoomFit() })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.Key1, Modifier: desktop.ControlModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.ZoomActualSize() })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.Key2, Modifier: desktop.ControlModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.ZoomToSelection() })
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeySlash, Modifier: desktop.ControlModifier},
 		func(_ fyne.Shortcut) { gs.ShowShortcutsPage() })
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeySlash, Modifier: desktop.ControlModifier | desktop.ShiftModifier},
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeySlash, Modifier: desktop.ControlModifier | desktop.ShiftModifier},
 		func(_ fyne.Shortcut) { gs.ShowShortcutsPage() })
 
 	gs.Win.Canvas().SetOnTypedKey(func(ev *fyne.KeyEvent) {
@@ -354,10 +851,31 @@ func (gs *GoShot) RegisterShortcuts() {
 			if gs.shortcutsDialog != nil {
 				gs.shortcutsDialog.Hide()
 			}
+		} else if (ev.Name == fyne.KeyReturn || ev.Name == fyne.KeyEnter) && gs.crop.active {
+			gs.ApplyCrop()
+		} else if (ev.Name == fyne.KeyReturn || ev.Name == fyne.KeyEnter) && gs.viewPort.currentOperation == Measure {
+			gs.AddDimensionLine()
+		} else if gs.viewPort.panKey(ev.Name) {
+			// Panned with the arrow keys.
+		} else if n := swatchKey(ev.Name); n > 0 {
+			gs.SelectSwatch(n - 1)
 		} else {
 			glog.V(2).Infof("KeyTyped: %+v", ev)
 		}
 	})
+	if deskCanvas, ok := gs.Win.Canvas().(desktop.Canvas); ok {
+		// Space+drag pans the view.
+		deskCanvas.SetOnKeyDown(func(ev *fyne.KeyEvent) {
+			if ev.Name == fyne.KeySpace && gs.Win.Canvas().Focused() == nil {
+				gs.viewPort.spaceDown = true
+			}
+		})
+		deskCanvas.SetOnKeyUp(func(ev *fyne.KeyEvent) {
+			if ev.Name == fyne.KeySpace {
+				gs.viewPort.spaceDown = false
+			}
+		})
+	}
 }
 
 func (gs *GoShot) ShowShortcutsPage() {
@@ -381,17 +899,35 @@ func (gs *GoShot) ShowShortcutsPage() {
 			container.NewVScroll(container.NewVBox(
 				titleFn("Image Manipulation"),
 				container.NewGridWithColumns(2,
-					descFn("Crop Top-Left"), shortcutFn("Alt+J"),
-					descFn("Crop Bottom-Right"), shortcutFn("Alt+K"),
+					descFn("Crop"), shortcutFn("Alt+J"),
+					descFn("Apply Crop"), shortcutFn("Enter"),
 					descFn("Draw Circle"), shortcutFn("Alt+C"),
 					descFn("Draw Arrow"), shortcutFn("Alt+A"),
 					descFn("Draw Text"), shortcutFn("Alt+T"),
+					descFn("Eyedropper (Right-Click For Background)"), shortcutFn("Alt+E"),
+					descFn("Measure"), shortcutFn("Alt+M"),
+					descFn("Add Measure As Dimension Line"), shortcutFn("Enter"),
+					descFn("Select Color From Palette"), shortcutFn("1 ... 9"),
+					descFn("Select Annotation Style"), shortcutFn("Alt+1 ... Alt+9"),
+					descFn("Apply Annotation Style To Last Annotation"), shortcutFn("Alt+Shift+1 ... Alt+Shift+9"),
+					descFn("Apply Annotation Style To Clicked Annotation"), shortcutFn("Alt+R"),
 					descFn("Cancel Operation"), shortcutFn("Esc"),
-					descFn("Undo Last Drawing"), shortcutFn("Control+Z"),
+					descFn("Undo Last Drawing Or Restyle"), shortcutFn("Control+Z"),
+				),
+				titleFn("View"),
+				container.NewGridWithColumns(2,
+					descFn("Zoom In / Out"), shortcutFn("Control+Plus / Control+Minus"),
+					descFn("Zoom To Fit Window"), shortcutFn("Control+0"),
+					descFn("Zoom To Actual Size (100%)"), shortcutFn("Control+1"),
+					descFn("Zoom To Selection"), shortcutFn("Control+2"),
+					descFn("Zoom At Mouse Position"), shortcutFn("Mouse Wheel"),
+					descFn("Pan"), shortcutFn("Arrow Keys, Space+Drag"),
 				),
 				titleFn("Sharing Image"),
 				container.NewGridWithColumns(2,
 					descFn("Copy Image To Clipboard"), shortcutFn("Control+C"),
+					descFn("Copy Color Under Mouse (Hex)"), shortcutFn("Control+Shift+C"),
+					descFn("Paste Image From Clipboard"), shortcutFn("Control+V"),
 					descFn("Save Image"), shortcutFn("Control+S"),
 					descFn("Google Drive & Copy URL"), shortcutFn("Control+G"),
 				),
@@ -411,14 +947,20 @@ func (gs *GoShot) ShowShortcutsPage() {
 
 const DelayTimePreference = "DelayTime"
 
+// DelayTime returns the delay of delayed screenshots set in the preferences, 5 seconds by default.
+func DelayTime(prefs fyne.Preferences) time.Duration {
+	secs := prefs.Int(DelayTimePreference)
+	if secs <= 0 {
+		secs = 5
+	}
+	return time.Duration(secs) * time.Second
+}
+
 func (gs *GoShot) DelayedScreenshotForm() {
 	if gs.delayedScreenshotDialog == nil {
 		delayEntry := widget.NewEntry()
 		delayEntry.Validator = validation.NewRegexp(`\d`, "Must contain a number")
-		v := gs.App.Preferences().Int(DelayTimePreference)
-		if v == 0 {
-			v = 5
-		}
+		v := DelayTime(gs.App.Preferences()) / time.Second
 		delayEntry.SetText(strconv.FormatInt(int64(v), 10))
 		gs.delayedScreenshotDialog = dialog.NewForm(
 			"Delayed Screenshot",
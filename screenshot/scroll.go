@@ -0,0 +1,126 @@
+package screenshot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/stitch"
+	"github.com/janpfeifer/goshot/xwindow"
+	"github.com/kbinani/screenshot"
+	"image"
+	"time"
+)
+
+// Scrolling capture modes, for CaptureOptions.Scroll.
+const (
+	ScrollManual = "manual" // The user scrolls the region.
+	ScrollAuto   = "auto"   // GoShot scrolls the region, sending mouse wheel events (X11 only).
+)
+
+// hideDelay is the time given to the edit window to hide, before capturing the screen.
+const hideDelay = 500 * time.Millisecond
+
+// Parameters of the scrolling capture.
+const (
+	scrollFramePeriod  = 250 * time.Millisecond // Time between frames.
+	scrollStartTimeout = 10 * time.Second       // Manual mode: time for the user to start scrolling.
+	scrollIdleTimeout  = 3 * time.Second        // Manual mode: the capture ends if not scrolled for this long.
+	scrollAutoClicks   = 3                      // Auto mode: mouse wheel clicks per frame.
+	scrollAutoIdle     = 3                      // Auto mode: the capture ends after this many unchanged frames.
+	scrollMaxHeight    = 20000                  // Maximum height of the stitched image.
+	scrollMinHeight    = 64                     // Minimum height of the region.
+)
+
+// ScrollingCapture captures the selected region (the CropRect) of the screen repeatedly while
+// it is scrolled down, and replaces the screenshot with the frames stitched into one tall image.
+// The region is scrolled by the user (ScrollManual) or by GoShot (ScrollAuto).
+//
+// It's only available for screenshots of the display, whose pixels map to the screen. The edit
+// window is hidden during the capture, which runs in the background.
+func (gs *GoShot) ScrollingCapture(mode string) {
+	if gs.captureBounds.Empty() {
+		gs.status.SetText("Scrolling capture is only available for screenshots of the display.")
+		return
+	}
+	region := gs.CropRect.Add(gs.captureBounds.Min)
+	if region.Dy() < scrollMinHeight {
+		gs.status.SetText(fmt.Sprintf("Region too small for a scrolling capture, it must be at least %d pixels high.", scrollMinHeight))
+		return
+	}
+	glog.V(1).Infof("Scrolling capture (%s) of %+v", mode, region)
+	gs.Win.Hide()
+	go func() {
+		time.Sleep(hideDelay)
+		img, numFrames, err := scrollCapture(region, mode)
+		// The screenshot is only changed in the window's event loop, along with the user's edits.
+		gs.runOnUI(func() { gs.endScrollingCapture(img, numFrames, err) })
+	}()
+}
+
+// endScrollingCapture shows the edit window again with the stitched image, or the error.
+func (gs *GoShot) endScrollingCapture(img *image.RGBA, numFrames int, err error) {
+	gs.Win.Show()
+	if err != nil {
+		glog.Errorf("Scrolling capture failed: %v", err)
+		gs.status.SetText(fmt.Sprintf("Scrolling capture failed: %v", err))
+		return
+	}
+	gs.Filters = nil
+	gs.setCapture(img)
+	gs.refreshScreenshot()
+	gs.status.SetText(fmt.Sprintf("Scrolling capture: %d frames stitched, %d x %d pixels.",
+		numFrames, img.Rect.Dx(), img.Rect.Dy()))
+}
+
+// scrollCapture captures the region of the screen until it stops scrolling, and returns the
+// stitched image and the number of frames used.
+func scrollCapture(region image.Rectangle, mode string) (*image.RGBA, int, error) {
+	auto := mode == ScrollAuto
+	center := image.Pt((region.Min.X+region.Max.X)/2, (region.Min.Y+region.Max.Y)/2)
+	first, err := screenshot.CaptureRect(region)
+	if err != nil {
+		return nil, 0, err
+	}
+	stitcher := stitch.New(first)
+	numFrames := 1
+	lastChange := time.Now()
+	unchanged := 0
+	for stitcher.Height() < scrollMaxHeight {
+		if auto {
+			if err := xwindow.ScrollDown(center.X, center.Y, scrollAutoClicks); err != nil {
+				return nil, 0, err
+			}
+		}
+		time.Sleep(scrollFramePeriod)
+		frame, err := screenshot.CaptureRect(region)
+		if err != nil {
+			return nil, 0, err
+		}
+		rows, err := stitcher.Add(frame)
+		if errors.Is(err, stitch.ErrNoOverlap) {
+			glog.Warningf("Scrolling capture stopped: scrolled too fast, the frames don't overlap")
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if rows > 0 {
+			numFrames++
+			lastChange = time.Now()
+			unchanged = 0
+			continue
+		}
+		unchanged++
+		if auto && unchanged >= scrollAutoIdle {
+			break
+		}
+		timeout := scrollIdleTimeout
+		if numFrames == 1 {
+			timeout = scrollStartTimeout
+		}
+		if !auto && time.Since(lastChange) > timeout {
+			break
+		}
+	}
+	glog.V(1).Infof("Scrolling capture: %d frames, %d pixels high", numFrames, stitcher.Height())
+	return stitcher.Image(), numFrames, nil
+}
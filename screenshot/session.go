@@ -0,0 +1,334 @@
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/googledrive"
+	"github.com/janpfeifer/goshot/history"
+	"github.com/janpfeifer/goshot/record"
+	"github.com/kbinani/screenshot"
+	"image"
+	_ "image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session holds the state shared by all the windows of one GoShot process: the Fyne application,
+// the Google Drive connection and the last screenshot taken.
+//
+// A long-running session (e.g. in the system tray) opens one edit window per screenshot, and
+// keeps running after they are all closed.
+type Session struct {
+	App fyne.App
+
+	// KeepRunning makes the application keep running after all windows are closed. It
+	// should be set before the first window is created.
+	KeepRunning bool
+
+	mu   sync.Mutex
+	last *GoShot
+
+	// gDriveMu protects gDrive separately, since connecting may wait for the user's authorization.
+	gDriveMu sync.Mutex
+	gDrive   *googledrive.Manager
+
+	// spare is a hidden window kept in long-running sessions: Fyne quits the application when
+	// all its windows are closed, so the last one closed is hidden instead, and reused later.
+	spare fyne.Window
+
+	// onClose holds the functions to call when the windows are closed, in long-running sessions.
+	onClose map[fyne.Window]func()
+
+	// OnRecordingChanged, if set, is called when a recording starts or stops.
+	OnRecordingChanged func(recording bool)
+
+	// Recording in progress: recording is set as soon as it's requested, recorder once it starts.
+	recMu     sync.Mutex
+	recording *GoShot
+	recorder  *record.Recorder
+}
+
+// NewSession creates a new session for the given Fyne application.
+func NewSession(a fyne.App) *Session {
+	history.MaxCaptures = a.Preferences().IntWithFallback(CapturesHistorySizePreference, history.MaxCaptures)
+	return &Session{App: a, onClose: make(map[fyne.Window]func())}
+}
+
+// CaptureOptions configure how a screenshot is taken, and how the edit window starts.
+type CaptureOptions struct {
+	// Delay before taking the screenshot.
+	Delay time.Duration
+
+	// Region: if set, the edit window starts with the crop tool selected, to select
+	// the region of interest of the screenshot.
+	Region bool
+
+	// Display is the index of the display to capture, 0 for the primary one.
+	Display int
+
+	// Window, if set, captures only one window instead of the display: WindowActive or WindowPick.
+	// How the window is presented is configured in the preferences, see WindowStyleFromPreferences.
+	Window string
+
+	// Scroll, if set, starts a scrolling capture (ScrollManual or ScrollAuto) of the region
+	// selected in the edit window, see GoShot.ScrollingCapture.
+	Scroll string
+
+	// Record, if set, records the region selected in the edit window as an animation, see
+	// GoShot.StartRecording.
+	Record bool
+}
+
+// Args returns the options in the format accepted by ParseCaptureOptions.
+func (opts CaptureOptions) Args() (args []string) {
+	if opts.Delay > 0 {
+		args = append(args, fmt.Sprintf("delay=%s", opts.Delay))
+	}
+	if opts.Region {
+		args = append(args, "region")
+	}
+	if opts.Display > 0 {
+		args = append(args, fmt.Sprintf("display=%d", opts.Display))
+	}
+	if opts.Window != "" {
+		args = append(args, fmt.Sprintf("window=%s", opts.Window))
+	}
+	if opts.Scroll != "" {
+		args = append(args, fmt.Sprintf("scroll=%s", opts.Scroll))
+	}
+	if opts.Record {
+		args = append(args, "record")
+	}
+	return
+}
+
+// ParseCaptureOptions parses capture options given as a list of "key=value" or "key" (for
+// booleans, "window" for the active window or "scroll" for a manual scrolling capture), as returned by CaptureOptions.Args.
+func ParseCaptureOptions(args []string) (opts CaptureOptions, err error) {
+	for _, arg := range args {
+		key, value := arg, ""
+		if idx := strings.Index(arg, "="); idx >= 0 {
+			key, value = arg[:idx], arg[idx+1:]
+		}
+		switch key {
+		case "delay":
+			opts.Delay, err = time.ParseDuration(value)
+			if err != nil {
+				return opts, fmt.Errorf("invalid delay %q: %w", value, err)
+			}
+		case "region":
+			opts.Region = true
+		case "record":
+			opts.Record = true
+		case "display":
+			opts.Display, err = strconv.Atoi(value)
+			if err != nil || opts.Display < 0 {
+				return opts, fmt.Errorf("invalid display %q", value)
+			}
+		case "window":
+			if value == "" {
+				value = WindowActive
+			}
+			if value != WindowActive && value != WindowPick {
+				return opts, fmt.Errorf("invalid window capture mode %q, it must be %q or %q", value, WindowActive, WindowPick)
+			}
+			opts.Window = value
+		case "scroll":
+			if value == "" {
+				value = ScrollManual
+			}
+			if value != ScrollManual && value != ScrollAuto {
+				return opts, fmt.Errorf("invalid scrolling capture mode %q, it must be %q or %q", value, ScrollManual, ScrollAuto)
+			}
+			opts.Scroll = value
+		default:
+			return opts, fmt.Errorf("unknown capture option %q", arg)
+		}
+	}
+	return opts, nil
+}
+
+// Capture takes a screenshot as configured by `opts`, and opens an edit window for it.
+// It can be called from any goroutine, and it blocks during the delay, if one is configured.
+func (s *Session) Capture(opts CaptureOptions) (*GoShot, error) {
+	if n := screenshot.NumActiveDisplays(); opts.Display >= n {
+		return nil, fmt.Errorf("display %d not available, there are %d displays", opts.Display, n)
+	}
+	if opts.Delay > 0 {
+		glog.V(1).Infof("Screenshot in %s", opts.Delay)
+		time.Sleep(opts.Delay)
+	}
+	gs := &GoShot{
+		App:     s.App,
+		Session: s,
+		Display: opts.Display,
+	}
+	if opts.Window != "" {
+		win, err := findWindow(opts.Window)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find window to capture: %w", err)
+		}
+		if err := gs.MakeWindowScreenshot(win, WindowStyleFromPreferences(s.App.Preferences())); err != nil {
+			return nil, fmt.Errorf("failed to capture window %q: %w", win.Title, err)
+		}
+	} else if err := gs.MakeScreenshot(); err != nil {
+		return nil, fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+	gs.BuildEditWindow()
+	switch {
+	case opts.Scroll != "":
+		gs.selectRegionThen("Select region to scroll", func() { gs.ScrollingCapture(opts.Scroll) })
+	case opts.Record:
+		gs.selectRegionThen("Select region to record", gs.StartRecording)
+	case opts.Region:
+		gs.SelectRegion()
+	}
+	s.show(gs)
+	return gs, nil
+}
+
+// Open opens the image file in a new edit window.
+func (s *Session) Open(filePath string) (*GoShot, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %q: %w", filePath, err)
+	}
+	gs := &GoShot{
+		App:     s.App,
+		Session: s,
+	}
+	gs.OriginalScreenshot = toRGBA(img)
+	gs.Screenshot = gs.OriginalScreenshot
+	gs.ScreenshotTime = time.Now()
+	gs.CropRect = gs.Screenshot.Rect
+	gs.BuildEditWindow()
+	gs.Win.SetTitle(fmt.Sprintf("GoShot: %s", filepath.Base(filePath)))
+	s.show(gs)
+	return gs, nil
+}
+
+// show shows the edit window, and makes it the last one of the session.
+func (s *Session) show(gs *GoShot) {
+	gs.Win.Show()
+	gs.miniMap.updateViewPortRect()
+	gs.miniMap.Refresh()
+
+	s.mu.Lock()
+	s.last = gs
+	s.mu.Unlock()
+}
+
+// Last returns the last screenshot taken (or image opened) in the session, or nil if its
+// window was closed or none was taken yet.
+func (s *Session) Last() *GoShot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// CopyLast copies the last screenshot to the clipboard: as edited, if its window is still open,
+// otherwise the last one kept in the history.
+func (s *Session) CopyLast() error {
+	if gs := s.Last(); gs != nil {
+		return gs.CopyImageToClipboard()
+	}
+	captures, err := history.LoadCaptures()
+	if err != nil {
+		return err
+	}
+	if len(captures) == 0 {
+		return errors.New("no screenshot taken yet")
+	}
+	f, err := os.Open(captures[0].Path)
+	if err != nil {
+		return fmt.Errorf("failed to open last screenshot: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("failed to decode last screenshot %q: %w", captures[0].Path, err)
+	}
+	return clipboard.CopyImage(img)
+}
+
+// GoogleDrive returns the session's connection to Google Drive, connecting if needed. If a
+// new authorization is needed, it asks the user for it with a dialog on the given window.
+func (s *Session) GoogleDrive(ctx context.Context, win fyne.Window) (*googledrive.Manager, error) {
+	s.gDriveMu.Lock()
+	defer s.gDriveMu.Unlock()
+	if s.gDrive == nil {
+		gDrive, err := newGoogleDriveManager(ctx, s.App, win)
+		if err != nil {
+			return nil, err
+		}
+		s.gDrive = gDrive
+	}
+	return s.gDrive, nil
+}
+
+// newWindow returns a new window for the session: the spare one, if available.
+// Closing the window, by the user or with CloseWindow, follows the session's KeepRunning.
+// `onClose`, if not nil, is called when the window is closed.
+func (s *Session) newWindow(title string, onClose func()) (win fyne.Window) {
+	s.mu.Lock()
+	win, s.spare = s.spare, nil
+	s.mu.Unlock()
+	if win != nil {
+		win.SetTitle(title)
+	} else {
+		win = s.App.NewWindow(title)
+	}
+	if s.KeepRunning {
+		win.SetCloseIntercept(func() { s.CloseWindow(win) })
+		if onClose != nil {
+			s.mu.Lock()
+			s.onClose[win] = onClose
+			s.mu.Unlock()
+		}
+	} else if onClose != nil {
+		win.SetOnClosed(onClose)
+	}
+	return win
+}
+
+// CloseWindow closes the window. In long-running sessions, if there is no spare window, it
+// is hidden and kept as spare instead, so the application keeps running.
+func (s *Session) CloseWindow(win fyne.Window) {
+	s.mu.Lock()
+	onClose := s.onClose[win]
+	delete(s.onClose, win)
+	s.mu.Unlock()
+	if onClose != nil {
+		onClose()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last != nil && s.last.Win == win {
+		s.last = nil
+	}
+	if !s.KeepRunning || s.spare != nil {
+		win.Close()
+		return
+	}
+	glog.V(2).Infof("Keeping window %q as spare.", win.Title())
+	win.Hide()
+	// Release the contents of the window.
+	win.SetMainMenu(nil)
+	win.SetContent(container.NewMax())
+	s.spare = win
+}
@@ -0,0 +1,180 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/history"
+	"github.com/janpfeifer/goshot/resources"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CapturesHistorySizePreference is the number of screenshots kept to be reopened later, e.g. from
+// the system tray "Open recent" menu. It is 0, disabled, by default: see history.MaxCaptures.
+const CapturesHistorySizePreference = "CapturesHistorySize"
+
+// DelaysPreference is the comma-separated list of delays, in seconds, offered in the system tray
+// "Delayed screenshot" menu.
+const DelaysPreference = "Delays"
+
+// DefaultDelays offered in the system tray "Delayed screenshot" menu, if not set in the preferences.
+var DefaultDelays = []time.Duration{3 * time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second}
+
+// Delays returns the delays offered in the system tray "Delayed screenshot" menu.
+func Delays(prefs fyne.Preferences) []time.Duration {
+	value := prefs.String(DelaysPreference)
+	if value == "" {
+		return DefaultDelays
+	}
+	delays, err := parseDelays(value)
+	if err != nil {
+		return DefaultDelays
+	}
+	return delays
+}
+
+// parseDelays parses a comma-separated list of delays in seconds.
+func parseDelays(value string) (delays []time.Duration, err error) {
+	for _, part := range splitAndTrim(value, ",") {
+		secs, err := strconv.Atoi(part)
+		if err != nil || secs <= 0 {
+			return nil, fmt.Errorf("invalid delay %q, it must be a positive number of seconds", part)
+		}
+		delays = append(delays, time.Duration(secs)*time.Second)
+	}
+	return delays, nil
+}
+
+// formatDelays formats the delays as a comma-separated list of seconds, as accepted by parseDelays.
+func formatDelays(delays []time.Duration) string {
+	parts := make([]string, len(delays))
+	for ii, delay := range delays {
+		parts[ii] = strconv.Itoa(int(delay / time.Second))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ShowSettings opens the window with the settings shared by all GoShot windows.
+func (s *Session) ShowSettings() {
+	prefs := s.App.Preferences()
+	win := s.newWindow("GoShot: settings", nil)
+	win.SetIcon(resources.GoShotIconPng)
+
+	numberEntry := func(value int) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.Validator = validation.NewRegexp(`^\d+$`, "Must be a number")
+		entry.SetText(strconv.Itoa(value))
+		return entry
+	}
+	delayEntry := numberEntry(int(DelayTime(prefs) / time.Second))
+	delaysEntry := widget.NewEntry()
+	delaysEntry.Validator = func(value string) error {
+		_, err := parseDelays(value)
+		return err
+	}
+	delaysEntry.SetText(formatDelays(Delays(prefs)))
+	capturesEntry := numberEntry(history.MaxCaptures)
+	clipboardEntry := numberEntry(prefs.IntWithFallback(ClipboardHistorySizePreference, clipboard.HistorySize))
+	templateEntry := widget.NewEntry()
+	templateEntry.Validator = validation.NewRegexp(`\S`, "Must not be empty")
+	templateEntry.SetText(FileNameTemplate(prefs))
+	autoSaveCheck := widget.NewCheck("Save every screenshot, and its edits when closed", nil)
+	autoSaveCheck.SetChecked(prefs.Bool(AutoSavePreference))
+	folderEntry := widget.NewEntry()
+	folderEntry.SetText(prefs.StringWithFallback(AutoSaveFolderPreference, DefaultAutoSaveFolder()))
+	folderButton := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+		dialog.ShowFolderOpen(func(folder fyne.ListableURI, err error) {
+			if err == nil && folder != nil {
+				folderEntry.SetText(folder.Path())
+			}
+		}, win)
+	})
+	keepEntry := numberEntry(prefs.Int(AutoSaveKeepPreference))
+	keepDaysEntry := numberEntry(prefs.Int(AutoSaveKeepDaysPreference))
+	windowStyle := WindowStyleFromPreferences(prefs)
+	decorationsCheck := widget.NewCheck("Include title bar and borders", nil)
+	decorationsCheck.SetChecked(windowStyle.Decorations)
+	paddingEntry := numberEntry(windowStyle.Padding)
+	shadowCheck := widget.NewCheck("Drop shadow", nil)
+	shadowCheck.SetChecked(windowStyle.Shadow)
+	cursorCheck := widget.NewCheck("Show mouse cursor in new screenshots", nil)
+	cursorCheck.SetChecked(prefs.Bool(ShowCursorPreference))
+	fpsEntry := numberEntry(RecordFPS(prefs))
+	maxDurationEntry := numberEntry(int(RecordMaxDuration(prefs) / time.Second))
+	annotationsCheck := widget.NewCheck("Burn annotations into every frame", nil)
+	annotationsCheck.SetChecked(prefs.BoolWithFallback(RecordAnnotationsPreference, true))
+
+	form := &widget.Form{
+		Items: []*widget.FormItem{
+			{Text: "Delayed screenshot (seconds)", Widget: delayEntry},
+			{Text: "System tray delays (seconds)", Widget: delaysEntry,
+				HintText: "Comma-separated, offered in the \"Delayed screenshot\" menu"},
+			{Text: "Recent screenshots kept", Widget: capturesEntry,
+				HintText: "Saved in the configuration folder for the \"Open recent\" menu, 0 to disable"},
+			{Text: "Clipboard history size", Widget: clipboardEntry,
+				HintText: "Images copied, 0 to disable"},
+			{Text: "File name template", Widget: templateEntry,
+				HintText: "Placeholders: {date}, {time}, {display}, {seq} and {title}"},
+			{Text: "Automatic saving", Widget: autoSaveCheck},
+			{Text: "Folder", Widget: container.NewBorder(nil, nil, nil, folderButton, folderEntry)},
+			{Text: "Keep last", Widget: keepEntry,
+				HintText: "Number of screenshots saved automatically kept, 0 for all"},
+			{Text: "Keep days", Widget: keepDaysEntry,
+				HintText: "Days screenshots saved automatically are kept, 0 for ever"},
+			{Text: "Mouse cursor", Widget: cursorCheck,
+				HintText: "It can also be shown, hidden or moved in the edit window"},
+			{Text: "Window capture", Widget: container.NewHBox(decorationsCheck, shadowCheck)},
+			{Text: "Window padding (pixels)", Widget: paddingEntry,
+				HintText: "Transparent margin around captured windows"},
+			{Text: "Recording frames per second", Widget: fpsEntry},
+			{Text: "Maximum recording (seconds)", Widget: maxDurationEntry},
+			{Text: "Saved animations", Widget: annotationsCheck},
+		},
+		OnSubmit: func() {
+			delay, _ := strconv.Atoi(delayEntry.Text)
+			delays, _ := parseDelays(delaysEntry.Text)
+			captures, _ := strconv.Atoi(capturesEntry.Text)
+			clipboardSize, _ := strconv.Atoi(clipboardEntry.Text)
+			history.MaxCaptures = captures
+			if err := history.PruneCaptures(); err != nil {
+				glog.Errorf("Failed to remove screenshots beyond the new history size: %v", err)
+			}
+			clipboard.HistorySize = clipboardSize
+			prefs.SetInt(DelayTimePreference, delay)
+			prefs.SetString(DelaysPreference, formatDelays(delays))
+			prefs.SetInt(CapturesHistorySizePreference, captures)
+			prefs.SetInt(ClipboardHistorySizePreference, clipboardSize)
+			keep, _ := strconv.Atoi(keepEntry.Text)
+			keepDays, _ := strconv.Atoi(keepDaysEntry.Text)
+			prefs.SetString(FileNameTemplatePreference, strings.TrimSpace(templateEntry.Text))
+			prefs.SetBool(AutoSavePreference, autoSaveCheck.Checked)
+			prefs.SetString(AutoSaveFolderPreference, strings.TrimSpace(folderEntry.Text))
+			prefs.SetInt(AutoSaveKeepPreference, keep)
+			prefs.SetInt(AutoSaveKeepDaysPreference, keepDays)
+			padding, _ := strconv.Atoi(paddingEntry.Text)
+			prefs.SetBool(WindowDecorationsPreference, decorationsCheck.Checked)
+			prefs.SetInt(WindowPaddingPreference, padding)
+			prefs.SetBool(WindowShadowPreference, shadowCheck.Checked)
+			prefs.SetBool(ShowCursorPreference, cursorCheck.Checked)
+			fps, _ := strconv.Atoi(fpsEntry.Text)
+			maxDuration, _ := strconv.Atoi(maxDurationEntry.Text)
+			prefs.SetInt(RecordFPSPreference, fps)
+			prefs.SetInt(RecordMaxDurationPreference, maxDuration)
+			prefs.SetBool(RecordAnnotationsPreference, annotationsCheck.Checked)
+			s.CloseWindow(win)
+		},
+		OnCancel:   func() { s.CloseWindow(win) },
+		SubmitText: "Save",
+	}
+	win.SetContent(form)
+	win.Resize(fyne.NewSize(700, 600))
+	win.Show()
+}
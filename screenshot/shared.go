@@ -0,0 +1,204 @@
+package screenshot
+
+import (
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/history"
+	"github.com/janpfeifer/goshot/resources"
+	"net/url"
+)
+
+// SharedWindow lists the images previously shared, from where one can
+// copy again or open their links, or delete the remote files.
+type SharedWindow struct {
+	App     fyne.App
+	Win     fyne.Window
+	Session *Session
+
+	entries []history.Entry
+	list    *widget.List
+	status  *widget.Label
+}
+
+// thumbnailListSize is the size of the thumbnails displayed in the SharedWindow.
+var thumbnailListSize = fyne.NewSize(64, 64)
+
+// RunShared runs the application only with the window of shared images.
+func RunShared() {
+	s := NewSession(app.NewWithID("GoShot"))
+	s.ShowSharedWindow()
+	s.App.Run()
+}
+
+// ShowSharedWindow opens the window with the images previously shared.
+func (gs *GoShot) ShowSharedWindow() {
+	gs.Session.ShowSharedWindow()
+}
+
+// ShowSharedWindow opens the window with the images previously shared.
+func (s *Session) ShowSharedWindow() {
+	sw := NewSharedWindow(s)
+	sw.Win.Show()
+}
+
+// DeleteShared opens the window with the images previously shared, and asks there for the
+// confirmation to delete the given one from its backend, see SharedWindow.DeleteRemote.
+func (s *Session) DeleteShared(entry history.Entry) {
+	sw := NewSharedWindow(s)
+	sw.Win.Show()
+	sw.DeleteRemote(entry)
+}
+
+// NewSharedWindow creates the window with the list of shared images.
+func NewSharedWindow(s *Session) *SharedWindow {
+	sw := &SharedWindow{
+		App:     s.App,
+		Win:     s.newWindow("GoShot: shared images", nil),
+		Session: s,
+		status:  widget.NewLabel(""),
+	}
+	sw.Win.SetIcon(resources.GoShotIconPng)
+
+	sw.list = widget.NewList(
+		func() int { return len(sw.entries) },
+		sw.createItem,
+		sw.updateItem)
+	refresh := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), func() { sw.Reload() })
+	sw.Win.SetContent(container.NewBorder(
+		nil,
+		container.NewBorder(nil, nil, nil, refresh, sw.status),
+		nil, nil,
+		sw.list))
+	sw.Win.Resize(fyne.NewSize(800, 600))
+	sw.Reload()
+	return sw
+}
+
+// Reload re-reads the history of shared images.
+func (sw *SharedWindow) Reload() {
+	entries, err := history.Load()
+	if err != nil {
+		glog.Errorf("Failed to load sharing history: %v", err)
+		sw.status.SetText(fmt.Sprintf("Failed to load sharing history: %v", err))
+		return
+	}
+	sw.entries = entries
+	sw.list.Refresh()
+	sw.status.SetText(fmt.Sprintf("%d shared images.", len(entries)))
+}
+
+// createItem creates the template for one item of the list: thumbnail, description and action buttons.
+func (sw *SharedWindow) createItem() fyne.CanvasObject {
+	thumbnail := canvas.NewImageFromResource(resources.GoShotIconPng)
+	thumbnail.FillMode = canvas.ImageFillContain
+	thumbnail.SetMinSize(thumbnailListSize)
+	name := widget.NewLabel("")
+	name.TextStyle.Bold = true
+	details := widget.NewLabel("")
+	buttons := container.NewHBox(
+		widget.NewButtonWithIcon("Copy link", theme.ContentCopyIcon(), nil),
+		widget.NewButtonWithIcon("Open", theme.ComputerIcon(), nil),
+		widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), nil),
+	)
+	return container.NewHBox(thumbnail, container.NewVBox(name, details), layout.NewSpacer(), buttons)
+}
+
+// updateItem fills the item template with the entry `id`.
+func (sw *SharedWindow) updateItem(id widget.ListItemID, item fyne.CanvasObject) {
+	if id < 0 || id >= len(sw.entries) {
+		return
+	}
+	entry := sw.entries[id]
+	objects := item.(*fyne.Container).Objects
+	thumbnail := objects[0].(*canvas.Image)
+	if entry.Thumbnail != "" {
+		thumbnail.Resource = nil
+		thumbnail.File = entry.Thumbnail
+	} else {
+		thumbnail.File = ""
+		thumbnail.Resource = resources.GoShotIconPng
+	}
+	thumbnail.Refresh()
+	texts := objects[1].(*fyne.Container).Objects
+	texts[0].(*widget.Label).SetText(entry.Name)
+	texts[1].(*widget.Label).SetText(fmt.Sprintf("%s @ %s: %s",
+		entry.Backend, entry.Time.Format("2006-01-02 15:04:05"), entry.URL))
+	buttons := objects[3].(*fyne.Container).Objects
+	buttons[0].(*widget.Button).OnTapped = func() { sw.CopyLink(entry) }
+	buttons[1].(*widget.Button).OnTapped = func() { sw.OpenLink(entry) }
+	buttons[2].(*widget.Button).OnTapped = func() { sw.DeleteRemote(entry) }
+}
+
+// CopyLink copies the URL of the shared image to the clipboard.
+func (sw *SharedWindow) CopyLink(entry history.Entry) {
+	if err := clipboard.CopyText(entry.URL); err != nil {
+		glog.Errorf("Failed to copy URL to clipboard: %v", err)
+		sw.status.SetText(fmt.Sprintf("Failed to copy URL to clipboard: %v", err))
+		return
+	}
+	sw.status.SetText(fmt.Sprintf("Link to %q copied to clipboard.", entry.Name))
+}
+
+// OpenLink opens the URL of the shared image in the browser.
+func (sw *SharedWindow) OpenLink(entry history.Entry) {
+	u, err := url.Parse(entry.URL)
+	if err == nil {
+		err = sw.App.OpenURL(u)
+	}
+	if err != nil {
+		glog.Errorf("Failed to open %q: %v", entry.URL, err)
+		sw.status.SetText(fmt.Sprintf("Failed to open %q: %v", entry.URL, err))
+	}
+}
+
+// remoteDeleters maps the backends to the functions that delete a remote file.
+var remoteDeleters = map[string]func(sw *SharedWindow, ctx context.Context, remoteID string) error{
+	history.GoogleDrive: func(sw *SharedWindow, ctx context.Context, remoteID string) error {
+		gDrive, err := sw.Session.GoogleDrive(ctx, sw.Win)
+		if err != nil {
+			return err
+		}
+		return gDrive.DeleteFile(ctx, remoteID)
+	},
+}
+
+// DeleteRemote asks for confirmation and then deletes the shared file from its backend,
+// and removes it from the history.
+func (sw *SharedWindow) DeleteRemote(entry history.Entry) {
+	deleter, found := remoteDeleters[entry.Backend]
+	if !found {
+		sw.status.SetText(fmt.Sprintf("Don't know how to delete files from %q.", entry.Backend))
+		return
+	}
+	dialog.ShowConfirm("Delete shared image",
+		fmt.Sprintf("Permanently delete %q from %s?\nThe link will stop working.", entry.Name, entry.Backend),
+		func(confirm bool) {
+			if !confirm {
+				return
+			}
+			sw.status.SetText(fmt.Sprintf("Deleting %q from %s ...", entry.Name, entry.Backend))
+			go func() {
+				err := deleter(sw, context.Background(), entry.RemoteID)
+				if err != nil {
+					glog.Errorf("Failed to delete %q from %s: %v", entry.Name, entry.Backend, err)
+					sw.status.SetText(fmt.Sprintf("Failed to delete %q from %s: %v", entry.Name, entry.Backend, err))
+					return
+				}
+				if err = history.Remove(entry); err != nil {
+					glog.Errorf("Failed to remove %q from history: %v", entry.Name, err)
+				}
+				sw.Reload()
+				sw.status.SetText(fmt.Sprintf("Deleted %q from %s.", entry.Name, entry.Backend))
+			}()
+		}, sw.Win)
+}
@@ -0,0 +1,540 @@
+package screenshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/filters"
+	"github.com/janpfeifer/goshot/palette"
+	"image"
+	"image/color"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// StylePresetsPreference holds the annotation style presets, encoded as JSON.
+// DefaultStylePresets are used if not set.
+const StylePresetsPreference = "StylePresets"
+
+// StylePreset is a named look of the annotations. Colors are formatted as "#rrggbb", or
+// "#rrggbbaa" if not opaque, and empty for transparent.
+type StylePreset struct {
+	Name           string  `json:"name"`
+	Stroke         string  `json:"stroke"`
+	Fill           string  `json:"fill,omitempty"`
+	TextBackground string  `json:"textBackground,omitempty"`
+	Thickness      float64 `json:"thickness"`
+	Font           string  `json:"font,omitempty"` // One of filters.FontNames.
+	FontSize       float64 `json:"fontSize"`
+}
+
+// DefaultStylePresets are offered if none were configured.
+var DefaultStylePresets = []StylePreset{
+	{Name: "Warning", Stroke: "#e51c23", Thickness: 6, Font: "Go Bold", FontSize: 20},
+	{Name: "Info", Stroke: "#455ede", Fill: "#455ede33", TextBackground: "#dde3fa", Thickness: 3, Font: "Go Regular", FontSize: 16},
+	{Name: "Note", Stroke: "#ffeb3b", TextBackground: "#000000", Thickness: 3, Font: "Go Bold", FontSize: 16},
+}
+
+// styleColors returns the stroke, fill and text background colors of the preset.
+func (s StylePreset) styleColors() (stroke, fill, textBackground color.NRGBA, err error) {
+	if stroke, err = palette.ParseHex(s.Stroke); err != nil {
+		return
+	}
+	if fill, err = palette.ParseHex(s.Fill); err != nil {
+		return
+	}
+	textBackground, err = palette.ParseHex(s.TextBackground)
+	return
+}
+
+// StylePresets returns the annotation style presets configured in the preferences.
+func StylePresets(prefs fyne.Preferences) []StylePreset {
+	defaults := append([]StylePreset(nil), DefaultStylePresets...) // Copy, since it may be edited.
+	value := prefs.String(StylePresetsPreference)
+	if value == "" {
+		return defaults
+	}
+	var presets []StylePreset
+	if err := json.Unmarshal([]byte(value), &presets); err != nil {
+		glog.Errorf("Invalid style presets in preferences, using the default ones: %v", err)
+		return defaults
+	}
+	return presets
+}
+
+// SetStylePresets saves the annotation style presets in the preferences.
+func SetStylePresets(prefs fyne.Preferences, presets []StylePreset) {
+	value, err := json.Marshal(presets)
+	if err != nil {
+		glog.Errorf("Failed to encode style presets: %v", err)
+		return
+	}
+	prefs.SetString(StylePresetsPreference, string(value))
+}
+
+// SelectStyle sets the colors, thickness, font and font size of new annotations to those of the
+// n-th style preset (starting from 0). If `applyToLast` is set, the style is also applied to the
+// last annotation.
+func (gs *GoShot) SelectStyle(n int, applyToLast bool) {
+	presets := StylePresets(gs.App.Preferences())
+	if n < 0 || n >= len(presets) {
+		return
+	}
+	preset := presets[n]
+	stroke, fill, textBackground, err := preset.styleColors()
+	if err != nil {
+		gs.status.SetText(fmt.Sprintf("Invalid style %q: %v", preset.Name, err))
+		return
+	}
+	vp, prefs := gs.viewPort, gs.App.Preferences()
+	vp.DrawingColor, vp.FillColor, vp.BackgroundColor = stroke, fill, textBackground
+	gs.SetColorPreference(DrawingColorPreference, stroke)
+	gs.SetColorPreference(FillColorPreference, fill)
+	gs.SetColorPreference(BackgroundColorPreference, textBackground)
+	gs.updateColorSample()
+	if preset.Thickness > 0 {
+		vp.Thickness = preset.Thickness
+		prefs.SetFloat(ThicknessPreference, vp.Thickness)
+		gs.thicknessEntry.SetText(strconv.FormatFloat(vp.Thickness, 'f', -1, 64))
+	}
+	vp.Font = preset.Font
+	prefs.SetString(FontPreference, vp.Font)
+	if preset.FontSize > 0 {
+		vp.FontSize = preset.FontSize
+		prefs.SetFloat(FontSizePreference, vp.FontSize)
+	}
+	gs.selectedStyle = preset.Name
+	gs.updateStyleSelection()
+
+	if !applyToLast {
+		gs.status.SetText(fmt.Sprintf("Style %q selected for new annotations.", preset.Name))
+		return
+	}
+	if gs.applyStyleToLast() {
+		gs.status.SetText(fmt.Sprintf("Style %q applied to the last annotation (Control+Z to undo), and selected for new ones.", preset.Name))
+	} else {
+		gs.status.SetText(fmt.Sprintf("Style %q selected: there is no annotation to apply it to.", preset.Name))
+	}
+}
+
+// applyToLastStyle applies the style selected to the last annotation, if there is one.
+func (gs *GoShot) applyToLastStyle() {
+	if gs.applyStyleToLast() {
+		gs.status.SetText("Style applied to the last annotation, use Control+Z to undo.")
+	} else {
+		gs.status.SetText("There is no annotation to apply the style to.")
+	}
+}
+
+// applyStyleToLast applies the current colors, thickness, font and font size to the last
+// annotation (circle, arrow, text or dimension line), and returns whether there was one.
+func (gs *GoShot) applyStyleToLast() bool {
+	for ii := len(gs.Filters) - 1; ii >= 0; ii-- {
+		if isAnnotation(gs.Filters[ii]) {
+			gs.restyle(ii)
+			return true
+		}
+	}
+	return false // No annotation, or only other filters, e.g. the mouse cursor.
+}
+
+// RestyleAt applies the current style to the annotation drawn at `p`, in the coordinates of the
+// original screenshot. If annotations overlap, the top-most one is restyled.
+func (gs *GoShot) RestyleAt(p image.Point) {
+	index := gs.annotationAt(p)
+	if index < 0 {
+		gs.status.SetText("Restyle: there is no annotation there, click on its line or text.")
+		return
+	}
+	gs.restyle(index)
+	gs.status.SetText("Style applied to the annotation, use Control+Z to undo.")
+}
+
+// restylePickDistance is how far, in pixels of the image, a click may be from an annotation to
+// select it for RestyleAt: lines may be only a couple of pixels thick.
+const restylePickDistance = 4
+
+// annotationAt returns the index in Filters of the top-most annotation drawn at `p` or near it,
+// or -1 if there is none.
+func (gs *GoShot) annotationAt(p image.Point) int {
+	// An annotation is at a pixel if it changes the color of the image under it. A uniform
+	// image of an unlikely color is used, so the colors of the screenshot don't matter.
+	probeColor := color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+	probe := image.NewUniform(probeColor)
+	pr, pg, pb, pa := probeColor.RGBA()
+	for ii := len(gs.Filters) - 1; ii >= 0; ii-- {
+		if !isAnnotation(gs.Filters[ii]) {
+			continue
+		}
+		drawn := gs.Filters[ii].Apply(probe)
+		for y := p.Y - restylePickDistance; y <= p.Y+restylePickDistance; y++ {
+			for x := p.X - restylePickDistance; x <= p.X+restylePickDistance; x++ {
+				if r, g, b, a := drawn.At(x, y).RGBA(); r != pr || g != pg || b != pb || a != pa {
+					return ii
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// isAnnotation returns whether the filter is an annotation that can be restyled.
+func isAnnotation(filter ImageFilter) bool {
+	switch filter.(type) {
+	case *filters.Circle, *filters.Arrow, *filters.Text, *filters.DimensionLine:
+		return true
+	}
+	return false
+}
+
+// restyleEdit records the restyle of an annotation, so UndoLastFilter can undo it.
+type restyleEdit struct {
+	// previous and restyled are the annotation before and after the restyle: restyled replaced
+	// previous in GoShot.Filters.
+	previous, restyled ImageFilter
+
+	// numFilters is the number of filters when restyled: the filters added later are undone first.
+	numFilters int
+}
+
+// restyle replaces the annotation at `index` of Filters with a copy drawn with the current
+// colors, thickness, font and font size, and records it to be undone.
+func (gs *GoShot) restyle(index int) {
+	vp := gs.viewPort
+	var restyled ImageFilter
+	switch f := gs.Filters[index].(type) {
+	case *filters.Circle:
+		c := *f
+		c.Color, c.Fill, c.Thickness = vp.DrawingColor, vp.FillColor, vp.Thickness
+		c.SetDim(c.Dim)
+		restyled = &c
+	case *filters.Arrow:
+		a := *f
+		a.Color, a.Thickness = vp.DrawingColor, vp.Thickness
+		a.SetPoints(a.From, a.To)
+		restyled = &a
+	case *filters.Text:
+		t := *f
+		t.Color, t.Background, t.Size = vp.DrawingColor, vp.BackgroundColor, vp.FontSize
+		t.SetFont(vp.Font)
+		restyled = &t
+	case *filters.DimensionLine:
+		d := *f
+		d.Color, d.Background, d.Thickness, d.FontSize, d.Font = vp.DrawingColor, vp.BackgroundColor, vp.Thickness, vp.FontSize, vp.Font
+		d.SetPoints(d.From, d.To)
+		restyled = &d
+	default:
+		return
+	}
+	gs.restyles = append(gs.restyles, restyleEdit{previous: gs.Filters[index], restyled: restyled, numFilters: len(gs.Filters)})
+	gs.Filters[index] = restyled
+	gs.ApplyFilters(true)
+}
+
+// undoRestyle restores the look of the last annotation restyled, if the restyle is the last edit,
+// and returns whether it did.
+func (gs *GoShot) undoRestyle() bool {
+	for len(gs.restyles) > 0 {
+		last := gs.restyles[len(gs.restyles)-1]
+		if len(gs.Filters) > last.numFilters {
+			return false // Filters added after the restyle are undone first.
+		}
+		gs.restyles = gs.restyles[:len(gs.restyles)-1]
+		for ii, filter := range gs.Filters {
+			if filter == last.restyled {
+				gs.Filters[ii] = last.previous
+				gs.ApplyFilters(true)
+				return true
+			}
+		}
+		// The annotation is gone, e.g. the screenshot was replaced: try the restyle before it.
+	}
+	return false
+}
+
+// styleNames returns the names of the style presets.
+func (gs *GoShot) styleNames() []string {
+	presets := StylePresets(gs.App.Preferences())
+	names := make([]string, len(presets))
+	for ii, preset := range presets {
+		names[ii] = preset.Name
+	}
+	return names
+}
+
+// buildStyleSelect creates the toolbar selector of the annotation style.
+func (gs *GoShot) buildStyleSelect() fyne.CanvasObject {
+	gs.styleSelect = widget.NewSelect(nil, func(name string) {
+		if name == gs.selectedStyle {
+			return
+		}
+		for ii, styleName := range gs.styleNames() {
+			if styleName == name {
+				gs.SelectStyle(ii, false)
+			}
+		}
+	})
+	gs.styleSelect.PlaceHolder = "(custom)"
+	gs.updateStyleSelection()
+	return container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("Style:"),
+			widget.NewButtonWithIcon("", theme.SettingsIcon(), func() { gs.EditStylePresets() }),
+			gs.styleSelect),
+		widget.NewButton("Apply style to last annotation", func() { gs.applyToLastStyle() }),
+		widget.NewButton("Apply style to annotation (alt+r)", func() { gs.viewPort.SetOp(Restyle) }),
+	)
+}
+
+// updateStyleSelection updates the toolbar selector and the "Styles" menu with the presets and
+// the one selected.
+func (gs *GoShot) updateStyleSelection() {
+	if gs.styleSelect == nil {
+		return
+	}
+	names := gs.styleNames()
+	gs.styleSelect.Options = names
+	found := false
+	for _, name := range names {
+		found = found || name == gs.selectedStyle
+	}
+	if found {
+		gs.styleSelect.SetSelected(gs.selectedStyle)
+	} else {
+		gs.styleSelect.ClearSelected()
+	}
+
+	gs.styleMenu.Items = nil
+	for ii, name := range names {
+		ii := ii
+		label := name
+		if ii < 9 {
+			label = fmt.Sprintf("%s (alt+%d)", name, ii+1)
+		}
+		item := fyne.NewMenuItem(label, func() { gs.SelectStyle(ii, false) })
+		item.Checked = name == gs.selectedStyle
+		gs.styleMenu.Items = append(gs.styleMenu.Items, item)
+	}
+	gs.styleMenu.Items = append(gs.styleMenu.Items, fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Apply to last annotation", func() { gs.applyToLastStyle() }),
+		fyne.NewMenuItem("Apply to annotation ...", func() { gs.viewPort.SetOp(Restyle) }),
+		fyne.NewMenuItem("Edit styles ...", func() { gs.EditStylePresets() }),
+		fyne.NewMenuItem("Import styles ...", func() { gs.ImportStylePresets() }),
+		fyne.NewMenuItem("Export styles ...", func() { gs.ExportStylePresets() }))
+	if mainMenu := gs.Win.MainMenu(); mainMenu != nil {
+		mainMenu.Refresh()
+	}
+}
+
+// EditStylePresets opens a dialog to create, change and delete the annotation style presets.
+func (gs *GoShot) EditStylePresets() {
+	prefs := gs.App.Preferences()
+	presets := StylePresets(prefs)
+
+	number := func() *widget.Entry {
+		return &widget.Entry{Validator: validation.NewRegexp(`^\d+(\.\d*)?$`, "Must be a number")}
+	}
+	colorEntry := func(placeHolder string) *widget.Entry {
+		entry := &widget.Entry{Validator: func(s string) error { _, err := palette.ParseHex(s); return err }}
+		entry.SetPlaceHolder(placeHolder)
+		return entry
+	}
+	nameEntry := widget.NewEntry()
+	strokeEntry := colorEntry("#rrggbb")
+	fillEntry := colorEntry("Transparent")
+	textBackgroundEntry := colorEntry("Transparent")
+	thicknessEntry := number()
+	fontSelect := widget.NewSelect(filters.FontNames, nil)
+	fontSizeEntry := number()
+
+	show := func(preset StylePreset) {
+		nameEntry.SetText(preset.Name)
+		strokeEntry.SetText(preset.Stroke)
+		fillEntry.SetText(preset.Fill)
+		textBackgroundEntry.SetText(preset.TextBackground)
+		thicknessEntry.SetText(strconv.FormatFloat(preset.Thickness, 'f', -1, 64))
+		fontSelect.SetSelected(preset.Font)
+		if fontSelect.Selected == "" {
+			fontSelect.SetSelected(filters.FontNames[0])
+		}
+		fontSizeEntry.SetText(strconv.FormatFloat(preset.FontSize, 'f', -1, 64))
+	}
+	read := func() (preset StylePreset, err error) {
+		preset = StylePreset{
+			Name:           strings.TrimSpace(nameEntry.Text),
+			Stroke:         strings.TrimSpace(strokeEntry.Text),
+			Fill:           strings.TrimSpace(fillEntry.Text),
+			TextBackground: strings.TrimSpace(textBackgroundEntry.Text),
+			Font:           fontSelect.Selected,
+		}
+		if preset.Name == "" {
+			return preset, fmt.Errorf("the style needs a name")
+		}
+		if _, _, _, err = preset.styleColors(); err != nil {
+			return preset, err
+		}
+		if preset.Thickness, err = strconv.ParseFloat(thicknessEntry.Text, 64); err != nil {
+			return preset, fmt.Errorf("invalid thickness %q", thicknessEntry.Text)
+		}
+		if preset.FontSize, err = strconv.ParseFloat(fontSizeEntry.Text, 64); err != nil {
+			return preset, fmt.Errorf("invalid font size %q", fontSizeEntry.Text)
+		}
+		return preset, nil
+	}
+
+	names := func() []string {
+		names := make([]string, len(presets))
+		for ii, preset := range presets {
+			names[ii] = preset.Name
+		}
+		return names
+	}
+	presetSelect := widget.NewSelect(names(), func(name string) {
+		for _, preset := range presets {
+			if preset.Name == name {
+				show(preset)
+			}
+		}
+	})
+	status := widget.NewLabel("")
+	saveButton := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), func() {
+		preset, err := read()
+		if err != nil {
+			status.SetText(fmt.Sprintf("Not saved: %v", err))
+			return
+		}
+		found := false
+		for ii := range presets {
+			if presets[ii].Name == preset.Name {
+				presets[ii], found = preset, true
+			}
+		}
+		if !found {
+			presets = append(presets, preset)
+		}
+		SetStylePresets(prefs, presets)
+		presetSelect.Options = names()
+		presetSelect.SetSelected(preset.Name)
+		gs.updateStyleSelection()
+		status.SetText(fmt.Sprintf("Style %q saved.", preset.Name))
+	})
+	currentButton := widget.NewButtonWithIcon("From current", theme.ContentPasteIcon(), func() {
+		vp := gs.viewPort
+		hex := func(c color.Color) string {
+			nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+			if nrgba.A == 0 {
+				return ""
+			}
+			return palette.FormatHex(nrgba)
+		}
+		show(StylePreset{
+			Name:           nameEntry.Text,
+			Stroke:         hex(vp.DrawingColor),
+			Fill:           hex(vp.FillColor),
+			TextBackground: hex(vp.BackgroundColor),
+			Thickness:      vp.Thickness,
+			Font:           vp.Font,
+			FontSize:       vp.FontSize,
+		})
+	})
+	deleteButton := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
+		name := strings.TrimSpace(nameEntry.Text)
+		for ii := range presets {
+			if presets[ii].Name == name {
+				presets = append(presets[:ii], presets[ii+1:]...)
+				SetStylePresets(prefs, presets)
+				presetSelect.Options = names()
+				presetSelect.ClearSelected()
+				gs.updateStyleSelection()
+				status.SetText(fmt.Sprintf("Style %q deleted.", name))
+				return
+			}
+		}
+	})
+	if len(presets) > 0 {
+		presetSelect.SetSelected(presets[0].Name)
+		if gs.selectedStyle != "" {
+			presetSelect.SetSelected(gs.selectedStyle)
+		}
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Stroke (#rrggbb[aa])", strokeEntry),
+		widget.NewFormItem("Fill (#rrggbb[aa])", fillEntry),
+		widget.NewFormItem("Text background (#rrggbb[aa])", textBackgroundEntry),
+		widget.NewFormItem("Thickness", thicknessEntry),
+		widget.NewFormItem("Font", fontSelect),
+		widget.NewFormItem("Font size", fontSizeEntry),
+	)
+	content := container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("Style:"), nil, presetSelect),
+		form,
+		container.NewHBox(saveButton, currentButton, deleteButton),
+		status,
+	)
+	d := dialog.NewCustom("Annotation styles", "Close", container.NewVScroll(content), gs.Win)
+	d.Resize(fyne.NewSize(500, 550))
+	d.Show()
+}
+
+// ImportStylePresets opens a file dialog to replace the style presets with those of a JSON file,
+// as saved by ExportStylePresets.
+func (gs *GoShot) ImportStylePresets() {
+	fileOpen := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			gs.status.SetText(fmt.Sprintf("Failed to import styles: %s", err))
+			return
+		}
+		if reader == nil {
+			gs.status.SetText("Import styles cancelled.")
+			return
+		}
+		defer func() { _ = reader.Close() }()
+		gs.App.Preferences().SetString(DefaultPathPreference, path.Dir(reader.URI().Path()))
+		var presets []StylePreset
+		if err := json.NewDecoder(reader).Decode(&presets); err != nil {
+			glog.Errorf("Failed to import styles from %q: %s", reader.URI(), err)
+			gs.status.SetText(fmt.Sprintf("Failed to import styles from %q: %s", reader.URI(), err))
+			return
+		}
+		SetStylePresets(gs.App.Preferences(), presets)
+		gs.updateStyleSelection()
+		gs.status.SetText(fmt.Sprintf("Imported %d styles.", len(presets)))
+	}, gs.Win)
+	fileOpen.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	gs.showFileDialog(fileOpen)
+}
+
+// ExportStylePresets opens a file dialog to save the style presets as JSON, to share them.
+func (gs *GoShot) ExportStylePresets() {
+	fileSave := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			gs.status.SetText(fmt.Sprintf("Failed to export styles: %s", err))
+			return
+		}
+		if writer == nil {
+			gs.status.SetText("Export styles cancelled.")
+			return
+		}
+		defer func() { _ = writer.Close() }()
+		gs.App.Preferences().SetString(DefaultPathPreference, path.Dir(writer.URI().Path()))
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(StylePresets(gs.App.Preferences())); err != nil {
+			glog.Errorf("Failed to export styles to %q: %s", writer.URI(), err)
+			gs.status.SetText(fmt.Sprintf("Failed to export styles to %q: %s", writer.URI(), err))
+			return
+		}
+		gs.status.SetText(fmt.Sprintf("Exported styles to %q", writer.URI()))
+	}, gs.Win)
+	fileSave.SetFileName("goshot_styles.json")
+	gs.showFileDialog(fileSave)
+}
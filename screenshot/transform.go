@@ -0,0 +1,177 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/transform"
+	"image"
+	"image/color"
+	"strconv"
+)
+
+// Preferences of the image operations, the values last used.
+const (
+	// ResizePercentPreference is the percentage used to resize the image, 50 if not set.
+	ResizePercentPreference = "ResizePercent"
+
+	// ResizeMaxWidthPreference is the maximum width used to resize the image, 0 if not set (resize
+	// by percentage).
+	ResizeMaxWidthPreference = "ResizeMaxWidth"
+
+	// PaddingPreference is the number of pixels of padding added around the image, 20 if not set.
+	PaddingPreference = "Padding"
+
+	// PaddingColorPreference is the color of the padding, transparent if not set.
+	PaddingColorPreference = "PaddingColor"
+)
+
+// paddingColors are the colors offered for the padding.
+var paddingColors = []struct {
+	name  string
+	color color.Color
+}{
+	{"Transparent", color.Transparent},
+	{"White", color.White},
+	{"Black", color.Black},
+	{"Drawing color", nil}, // Set to the ViewPort.DrawingColor.
+}
+
+// TransformImage applies the operation to the original screenshot, and to the frames of the
+// recording if it's one. The filters (edits), the crop and the mouse cursor are moved along with
+// the content of the image.
+func (gs *GoShot) TransformImage(t transform.Transform) {
+	if gs.viewPort.currentOperation != NoOp {
+		gs.viewPort.SetOp(NoOp)
+	}
+	glog.V(1).Infof("TransformImage(%s)", t)
+	bounds := gs.OriginalScreenshot.Rect
+	mapPoint := func(p image.Point) image.Point { return t.Point(p, bounds) }
+	scale := t.Scale(bounds)
+
+	gs.OriginalScreenshot = t.Apply(gs.OriginalScreenshot)
+	for ii := range gs.frames {
+		gs.frames[ii].Image = t.Apply(gs.frames[ii].Image)
+	}
+	if _, isPad := t.(transform.Pad); isPad || gs.CropRect == bounds {
+		gs.CropRect = gs.OriginalScreenshot.Rect
+	} else {
+		gs.CropRect = transform.Rect(t, gs.CropRect, bounds).Intersect(gs.OriginalScreenshot.Rect)
+	}
+	for _, filter := range gs.Filters {
+		filter.Transform(mapPoint, scale)
+	}
+	for _, edit := range gs.restyles {
+		edit.previous.Transform(mapPoint, scale) // So undoing a restyle keeps the annotation in place.
+	}
+	if gs.Cursor != nil {
+		gs.CursorPosition = mapPoint(gs.CursorPosition)
+		if gs.cursorFilter != nil {
+			// The cursor points to the same place, instead of having its center moved.
+			gs.cursorFilter.Position = gs.CursorPosition.Sub(gs.CursorHotspot)
+		}
+	}
+	gs.captureBounds = image.Rectangle{} // The pixels no longer map to the screen.
+	gs.setEdited()
+	gs.refreshScreenshot()
+	gs.status.SetText(fmt.Sprintf("Image %s, now %d x %d pixels.", t,
+		gs.OriginalScreenshot.Rect.Dx(), gs.OriginalScreenshot.Rect.Dy()))
+}
+
+// ResizeForm opens a dialog to resize the image by a percentage, or down to a maximum width.
+func (gs *GoShot) ResizeForm() {
+	prefs := gs.App.Preferences()
+	percentEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+(\.\d*)?$`, "Must be a number")}
+	percentEntry.SetText(strconv.FormatFloat(prefs.FloatWithFallback(ResizePercentPreference, 50), 'f', -1, 64))
+	widthEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d*$`, "Must be a number")}
+	widthEntry.SetPlaceHolder("Not set")
+	if maxWidth := prefs.Int(ResizeMaxWidthPreference); maxWidth > 0 {
+		widthEntry.SetText(strconv.Itoa(maxWidth))
+	}
+	bounds := gs.OriginalScreenshot.Rect
+	form := dialog.NewForm(
+		fmt.Sprintf("Resize image (%d x %d)", bounds.Dx(), bounds.Dy()),
+		"Ok", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Scale (%)", percentEntry),
+			widget.NewFormItem("Or maximum width (pixels)", widthEntry),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			if maxWidth, err := strconv.Atoi(widthEntry.Text); err == nil && maxWidth > 0 {
+				prefs.SetInt(ResizeMaxWidthPreference, maxWidth)
+				gs.TransformImage(transform.ResizeToWidth(bounds, maxWidth))
+				return
+			}
+			prefs.SetInt(ResizeMaxWidthPreference, 0)
+			percent, err := strconv.ParseFloat(percentEntry.Text, 64)
+			if err != nil || percent <= 0 {
+				gs.status.SetText(fmt.Sprintf("Invalid scale %q: it must be a positive percentage.", percentEntry.Text))
+				return
+			}
+			prefs.SetFloat(ResizePercentPreference, percent)
+			gs.TransformImage(transform.ResizeByPercent(bounds, percent))
+		}, gs.Win)
+	form.Resize(fyne.NewSize(400, 250))
+	form.Show()
+}
+
+// PaddingForm opens a dialog to add padding around the image, as cropped.
+func (gs *GoShot) PaddingForm() {
+	prefs := gs.App.Preferences()
+	paddingEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+$`, "Must be a number")}
+	paddingEntry.SetText(strconv.Itoa(prefs.IntWithFallback(PaddingPreference, 20)))
+	names := make([]string, len(paddingColors))
+	for ii, c := range paddingColors {
+		names[ii] = c.name
+	}
+	colorSelect := widget.NewSelect(names, nil)
+	colorSelect.SetSelected(prefs.StringWithFallback(PaddingColorPreference, names[0]))
+	form := dialog.NewForm(
+		"Add padding",
+		"Ok", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Padding (pixels)", paddingEntry),
+			widget.NewFormItem("Color", colorSelect),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			padding, err := strconv.Atoi(paddingEntry.Text)
+			if err != nil || padding < 0 {
+				gs.status.SetText(fmt.Sprintf("Invalid padding %q: it must be a number of pixels.", paddingEntry.Text))
+				return
+			}
+			prefs.SetInt(PaddingPreference, padding)
+			prefs.SetString(PaddingColorPreference, colorSelect.Selected)
+			c := color.Color(color.Transparent)
+			for _, pc := range paddingColors {
+				if pc.name == colorSelect.Selected {
+					c = pc.color
+					if c == nil {
+						c = gs.viewPort.DrawingColor
+					}
+				}
+			}
+			gs.TransformImage(gs.paddingTransform(padding, c))
+		}, gs.Win)
+	form.Resize(fyne.NewSize(400, 250))
+	form.Show()
+}
+
+// paddingTransform returns the transform that adds `padding` pixels around the cropped image:
+// the parts of the original screenshot cropped out are removed.
+func (gs *GoShot) paddingTransform(padding int, c color.Color) transform.Pad {
+	bounds, crop := gs.OriginalScreenshot.Rect, gs.CropRect
+	return transform.Pad{
+		Left:   padding - (crop.Min.X - bounds.Min.X),
+		Top:    padding - (crop.Min.Y - bounds.Min.Y),
+		Right:  padding - (bounds.Max.X - crop.Max.X),
+		Bottom: padding - (bounds.Max.Y - crop.Max.Y),
+		Color:  c,
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fyne.io/fyne/v2/data/validation"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/golang/glog"
 	"github.com/janpfeifer/goshot/filters"
@@ -16,6 +17,7 @@ import (
 	"image/color"
 	"math"
 	"strconv"
+	"strings"
 )
 
 // ViewPort is our view port for the image being edited. It's a specialized widget
@@ -40,12 +42,15 @@ type ViewPort struct {
 	Thickness float64
 
 	// DrawingColor is used on all new drawing operation. BackgroundColor is used
-	// for the background of text.
-	DrawingColor, BackgroundColor color.Color
+	// for the background of text, and FillColor for the inside of circles.
+	DrawingColor, BackgroundColor, FillColor color.Color
 
 	// FontSize is the last used font size.
 	FontSize float64
 
+	// Font is the name of the font of new texts, one of filters.FontNames.
+	Font string
+
 	// Are of the screenshot that is visible in the current window: these are the start (viewX, viewY)
 	// and sizes in gs.screenshot pixels -- each may be zoomed in/out when displaying.
 	viewX, viewY, viewW, viewH int
@@ -54,13 +59,16 @@ type ViewPort struct {
 	minSize fyne.Size
 	raster  *canvas.Raster
 
-	cursor                                            *canvas.Image
-	cursorCropTopLeft, cursorCropBottomRight          *canvas.Image
-	cursorDrawCircle, cursorDrawArrow, cursorDrawText *canvas.Image
+	cursor                                                                       *canvas.Image
+	cursorDrawCircle, cursorDrawArrow, cursorDrawText, cursorPick, cursorRestyle *canvas.Image
 
-	mouseIn         bool // Whether the mouse is over ViewPort.
+	mouseIn         bool          // Whether the mouse is over ViewPort.
+	mousePos        fyne.Position // Last position of the mouse over ViewPort, the anchor of the zoom.
 	mouseMoveEvents chan fyne.Position
 
+	// done is closed when the window is closed, to stop the goroutines of the ViewPort.
+	done chan struct{}
+
 	// Cache image for current dimensions/zoom/translation.
 	cache *image.RGBA
 
@@ -69,11 +77,16 @@ type ViewPort struct {
 	dragStart                      fyne.Position
 	dragStartViewX, dragStartViewY int
 	dragSkipTap                    bool // Set at DragEnd(), because the end of the drag also triggers a tap.
+	spaceDown                      bool // Space is pressed: dragging pans the view, whatever the operation.
+	dragPan                        bool // The drag in progress pans the view.
 
 	// Operations
 	currentOperation OperationType
 	currentCircle    *filters.Circle // Circle being dragged, only used when currentOperation==DrawCircle.
 	currentArrow     *filters.Arrow  // Circle being dragged, only used when currentOperation==DrawCircle.
+
+	// rendered is the view the cache was last rendered for, see renderPan.
+	rendered renderedView
 	fyne.ShortcutHandler
 }
 
@@ -81,11 +94,14 @@ type OperationType int
 
 const (
 	NoOp OperationType = iota
-	CropTopLeft
-	CropBottomRight
+	Crop
 	DrawCircle
 	DrawArrow
 	DrawText
+	MoveCursor
+	Eyedropper
+	Measure
+	Restyle
 )
 
 // Ensure ViewPort implements the following interfaces.
@@ -94,6 +110,7 @@ var (
 	_             = fyne.CanvasObject(vpPlaceholder)
 	_             = fyne.Draggable(vpPlaceholder)
 	_             = fyne.Tappable(vpPlaceholder)
+	_             = fyne.SecondaryTappable(vpPlaceholder)
 	_             = desktop.Hoverable(vpPlaceholder)
 )
 
@@ -107,19 +124,22 @@ func NewViewPort(gs *GoShot) (vp *ViewPort) {
 	}
 
 	vp = &ViewPort{
-		gs:                    gs,
-		cursorCropTopLeft:     canvas.NewImageFromResource(resources.CropTopLeft),
-		cursorCropBottomRight: canvas.NewImageFromResource(resources.CropBottomRight),
-		cursorDrawCircle:      canvas.NewImageFromResource(resources.DrawCircle),
-		cursorDrawArrow:       canvas.NewImageFromResource(resources.DrawArrow),
-		cursorDrawText:        canvas.NewImageFromResource(resources.DrawText),
-		mouseMoveEvents:       make(chan fyne.Position, 1000),
+		gs:               gs,
+		cursorDrawCircle: canvas.NewImageFromResource(resources.DrawCircle),
+		cursorDrawArrow:  canvas.NewImageFromResource(resources.DrawArrow),
+		cursorDrawText:   canvas.NewImageFromResource(resources.DrawText),
+		cursorPick:       canvas.NewImageFromResource(theme.ColorPaletteIcon()),
+		cursorRestyle:    canvas.NewImageFromResource(theme.ColorChromaticIcon()),
+		mouseMoveEvents:  make(chan fyne.Position, 1000),
+		done:             make(chan struct{}),
 
 		FontSize:  prefOrFloat(FontSizePreference, 16*float64(gs.Win.Canvas().Scale())),
 		Thickness: prefOrFloat(ThicknessPreference, 3.0),
+		Font:      gs.App.Preferences().StringWithFallback(FontPreference, filters.FontNames[0]),
 
 		DrawingColor:    gs.GetColorPreference(DrawingColorPreference, Red),
 		BackgroundColor: gs.GetColorPreference(BackgroundColorPreference, Transparent),
+		FillColor:       gs.GetColorPreference(FillColorPreference, Transparent),
 	}
 	go vp.consumeMouseMoveEvents()
 	vp.raster = canvas.NewRaster(vp.draw)
@@ -129,6 +149,8 @@ func NewViewPort(gs *GoShot) (vp *ViewPort) {
 const (
 	BackgroundColorPreference = "BackgroundColor"
 	DrawingColorPreference    = "DrawingColor"
+	FillColorPreference       = "FillColor"
+	FontPreference            = "Font"
 	FontSizePreference        = "FontSize"
 	ThicknessPreference       = "Thickness"
 )
@@ -192,34 +214,11 @@ func (vp *ViewPort) PosToPixel(pos fyne.Position) (x, y int) {
 	return
 }
 
+// Scrolled implements fyne.Scrollable: it zooms in or out, anchored at the mouse position.
 func (vp *ViewPort) Scrolled(ev *fyne.ScrollEvent) {
 	glog.V(2).Infof("Scrolled(dx=%f, dy=%f, position=%+v)", ev.Scrolled.DX, ev.Scrolled.DY, ev.Position)
-	size := vp.Size()
-	glog.V(2).Infof("- Size=%+v", vp.Size())
-	w, h := wh(vp.cache)
-	glog.V(2).Infof("- PxSize=(%d, %d)", w, h)
 	pixelX, pixelY := vp.PosToPixel(ev.Position)
-	glog.V(2).Infof("- Pixel position: (%d, %d)", pixelX, pixelY)
-
-	// We want to scroll, but preserve the pixel from the screenshot being viewed at the mouse position.
-	ratioX := ev.Position.X / size.Width
-	ratioY := ev.Position.Y / size.Height
-	screenshotX := int(ratioX*float32(vp.viewW) + float32(vp.viewX) + 0.5)
-	screenshotY := int(ratioY*float32(vp.viewH) + float32(vp.viewY) + 0.5)
-	glog.V(2).Infof("- Screenshot position: (%d, %d)", screenshotX, screenshotY)
-
-	// Update zoom.
-	vp.Log2Zoom += float64(ev.Scrolled.DY) / 50.0
-	vp.gs.zoomEntry.SetText(fmt.Sprintf("%.3g", vp.Log2Zoom))
-
-	// Update geometry.
-	vp.updateViewSize()
-	vp.viewX = screenshotX - int(ratioX*float32(vp.viewW)+0.5)
-	vp.viewY = screenshotY - int(ratioY*float32(vp.viewH)+0.5)
-	vp.Refresh()
-	if vp.gs.miniMap != nil {
-		vp.gs.miniMap.updateViewPortRect()
-	}
+	vp.SetZoom(vp.Log2Zoom+float64(ev.Scrolled.DY)/50.0, image.Pt(pixelX, pixelY))
 }
 
 func (vp *ViewPort) updateViewSize() {
@@ -263,35 +262,6 @@ func (vp *ViewPort) zoom() float64 {
 	return math.Exp2(-vp.Log2Zoom)
 }
 
-func (vp *ViewPort) renderCache() {
-	const bytesPerPixel = 4 // RGBA.
-	w, h := wh(vp.cache)
-	img := vp.gs.Screenshot
-	imgW, imgH := wh(img)
-	zoom := vp.zoom()
-
-	var c color.RGBA
-	glog.V(2).Infof("renderCache(): cache=(w=%d, h=%d, bytes=%d), zoom=%g, viewX=%d, viewY=%d, viewW=%d, viewH=%d",
-		w, h, len(vp.cache.Pix), zoom, vp.viewX, vp.viewY, vp.viewW, vp.viewH)
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			pos := (y*w + x) * bytesPerPixel
-			imgX := int(math.Round(float64(x)*zoom)) + vp.viewX
-			imgY := int(math.Round(float64(y)*zoom)) + vp.viewY
-			if imgX < 0 || imgX >= imgW || imgY < 0 || imgY >= imgH {
-				// Background image.
-				c = bgPattern(x, y)
-			} else {
-				c = img.RGBAAt(imgX, imgY)
-			}
-			vp.cache.Pix[pos] = c.R
-			vp.cache.Pix[pos+1] = c.G
-			vp.cache.Pix[pos+2] = c.B
-			vp.cache.Pix[pos+3] = c.A
-		}
-	}
-}
-
 var (
 	bgDark, bgLight = color.RGBA{R: 58, G: 58, B: 58, A: 0xFF}, color.RGBA{R: 84, G: 84, B: 84, A: 0xFF}
 )
@@ -317,28 +287,40 @@ func (vp *ViewPort) Dragged(ev *fyne.DragEvent) {
 		vp.dragStart = ev.Position
 		vp.dragStartViewX = vp.viewX
 		vp.dragStartViewY = vp.viewY
+		vp.dragPan = vp.spaceDown
 		go vp.consumeDragEvents()
+		if vp.dragPan {
+			return
+		}
 
 		startX, startY := vp.screenshotPos(vp.dragStart)
 		startX += vp.gs.CropRect.Min.X
 		startY += vp.gs.CropRect.Min.Y
+		// New annotations and measurements start at the point snapped to the grid or edges, if configured.
+		snapped := vp.snappedPos(vp.dragStart)
 
 		switch vp.currentOperation {
-		case NoOp, CropTopLeft, CropBottomRight, DrawText:
+		case NoOp, DrawText, MoveCursor, Eyedropper, Restyle:
 			// Drag the image around, nothing to do to start.
+		case Crop:
+			pixelX, pixelY := vp.PosToPixel(vp.dragStart)
+			vp.gs.startCropDrag(image.Pt(startX, startY), image.Pt(pixelX, pixelY))
+		case Measure:
+			vp.gs.startMeasure(snapped)
 		case DrawCircle:
-			glog.V(2).Infof("Tapped(): draw a circle starting at (%d, %d)", startX, startY)
+			glog.V(2).Infof("Tapped(): draw a circle starting at %v", snapped)
 			vp.currentCircle = filters.NewCircle(image.Rectangle{
-				Min: image.Point{X: startX, Y: startY},
-				Max: image.Point{X: startX + 5, Y: startY + 5},
+				Min: snapped,
+				Max: snapped.Add(image.Pt(5, 5)),
 			}, vp.DrawingColor, vp.Thickness)
+			vp.currentCircle.Fill = vp.FillColor
 			vp.gs.Filters = append(vp.gs.Filters, vp.currentCircle)
 			vp.gs.ApplyFilters(false)
 		case DrawArrow:
-			glog.V(2).Infof("Tapped(): draw an arrow starting at (%d, %d)", startX, startY)
+			glog.V(2).Infof("Tapped(): draw an arrow starting at %v", snapped)
 			vp.currentArrow = filters.NewArrow(
-				image.Point{X: startX, Y: startY},
-				image.Point{X: startX + 1, Y: startY + 1},
+				snapped,
+				snapped.Add(image.Pt(1, 1)),
 				vp.DrawingColor, vp.Thickness)
 			vp.gs.Filters = append(vp.gs.Filters, vp.currentArrow)
 			vp.gs.ApplyFilters(false)
@@ -347,7 +329,7 @@ func (vp *ViewPort) Dragged(ev *fyne.DragEvent) {
 		return // No need to process first event.
 	}
 	vp.dragEvents <- ev
-	vp.mouseMoveEvents <- ev.Position // Also emits a mouse move event.
+	vp.sendMouseMove(ev.Position) // Also emits a mouse move event.
 }
 
 func (vp *ViewPort) consumeDragEvents() {
@@ -395,10 +377,19 @@ func (vp *ViewPort) consumeDragEvents() {
 // each time it is called with the latest DragEvent, dropping those that happened in between
 // the previous call.
 func (vp *ViewPort) doDragThrottled(ev *fyne.DragEvent) {
+	if vp.dragPan {
+		vp.dragViewDelta(ev.Position.Subtract(vp.dragStart))
+		return
+	}
 	switch vp.currentOperation {
-	case NoOp, CropTopLeft, CropBottomRight, DrawText:
+	case NoOp, DrawText, MoveCursor, Eyedropper, Restyle:
 		// Drag the image around
 		vp.dragViewDelta(ev.Position.Subtract(vp.dragStart))
+	case Crop:
+		toX, toY := vp.screenshotPos(ev.Position)
+		vp.gs.dragCrop(image.Pt(toX, toY).Add(vp.gs.CropRect.Min))
+	case Measure:
+		vp.gs.dragMeasure(vp.snappedPos(ev.Position))
 	case DrawCircle:
 		vp.dragCircle(ev.Position)
 	case DrawArrow:
@@ -414,7 +405,8 @@ func (vp *ViewPort) dragViewDelta(delta fyne.Position) {
 
 	vp.viewX = vp.dragStartViewX - int(ratioX*float32(vp.viewW)+0.5)
 	vp.viewY = vp.dragStartViewY - int(ratioY*float32(vp.viewH)+0.5)
-	vp.Refresh()
+	vp.renderPan()
+	canvas.Refresh(vp)
 	vp.gs.miniMap.updateViewPortRect()
 }
 
@@ -422,44 +414,41 @@ func (vp *ViewPort) dragCircle(toPos fyne.Position) {
 	if vp.currentCircle == nil {
 		glog.Errorf("dragCircle(): dragCircle event, but none has been started yet!?")
 	}
-	startX, startY := vp.screenshotPos(vp.dragStart)
-	startX += vp.gs.CropRect.Min.X
-	startY += vp.gs.CropRect.Min.Y
-	toX, toY := vp.screenshotPos(toPos)
-	toX += vp.gs.CropRect.Min.X
-	toY += vp.gs.CropRect.Min.Y
 	vp.currentCircle.SetDim(image.Rectangle{
-		Min: image.Point{X: startX, Y: startY},
-		Max: image.Point{X: toX, Y: toY},
+		Min: vp.snappedPos(vp.dragStart),
+		Max: vp.snappedPos(toPos),
 	}.Canon())
 	glog.V(2).Infof("dragCircle(): draw a circle in %+v", vp.currentCircle)
 	vp.gs.ApplyFilters(false)
-	vp.renderCache()
-	vp.Refresh()
 }
 
 func (vp *ViewPort) dragArrow(toPos fyne.Position) {
 	if vp.currentArrow == nil {
 		glog.Errorf("dragArrow(): dragArrow event, but none has been started yet!?")
 	}
-	toX, toY := vp.screenshotPos(toPos)
-	toX += vp.gs.CropRect.Min.X
-	toY += vp.gs.CropRect.Min.Y
-	vp.currentArrow.SetPoints(vp.currentArrow.From, image.Point{X: toX, Y: toY})
+	vp.currentArrow.SetPoints(vp.currentArrow.From, vp.snappedPos(toPos))
 	glog.V(2).Infof("dragArrow(): draw an arrow in %+v", vp.currentArrow)
 	vp.gs.ApplyFilters(false)
-	vp.renderCache()
-	vp.Refresh()
 }
 
 // DragEnd implements fyne.Draggable
 func (vp *ViewPort) DragEnd() {
 	glog.V(2).Infof("DragEnd(), dragEvents=%v", vp.dragEvents != nil)
 	close(vp.dragEvents)
+	if vp.dragPan {
+		vp.dragEvents = nil
+		vp.dragSkipTap = true
+		vp.dragPan = false
+		vp.Refresh() // Panning may have shifted the cache by a fraction of a pixel.
+		return
+	}
 
 	switch vp.currentOperation {
-	case NoOp, CropTopLeft, CropBottomRight, DrawText:
-		// Drag the image around, nothing to do to start.
+	case NoOp, DrawText, MoveCursor, Eyedropper, Restyle:
+		// Panning may have shifted the cache by a fraction of a pixel.
+		vp.Refresh()
+	case Crop, Measure:
+		// Nothing to do.
 	case DrawCircle, DrawArrow:
 		vp.gs.ApplyFilters(true)
 	}
@@ -467,7 +456,7 @@ func (vp *ViewPort) DragEnd() {
 	vp.dragSkipTap = true
 
 	switch vp.currentOperation {
-	case NoOp, CropTopLeft, CropBottomRight, DrawText:
+	case NoOp, Crop, DrawText, MoveCursor, Eyedropper, Measure, Restyle:
 		// Nothing to do
 	case DrawCircle, DrawArrow:
 		vp.currentCircle = nil
@@ -485,6 +474,7 @@ func (vp *ViewPort) DragEnd() {
 // MouseIn implements desktop.Hoverable.
 func (vp *ViewPort) MouseIn(ev *desktop.MouseEvent) {
 	vp.mouseIn = true
+	vp.mousePos = ev.Position
 	if vp.cursor != nil {
 		vp.cursor.Move(ev.Position)
 	}
@@ -492,24 +482,48 @@ func (vp *ViewPort) MouseIn(ev *desktop.MouseEvent) {
 
 // MouseMoved implements desktop.Hoverable.
 func (vp *ViewPort) MouseMoved(ev *desktop.MouseEvent) {
-	if vp.cursor != nil {
-		// Send event to channel, it will only be acted on in
-		// vp.processMouseMoveEvent.
-		vp.mouseMoveEvents <- ev.Position
+	vp.mousePos = ev.Position
+	// Send event to channel, it will only be acted on in
+	// vp.processMouseMoveEvent.
+	vp.sendMouseMove(ev.Position)
+}
+
+// sendMouseMove sends the position to consumeMouseMoveEvents, unless the ViewPort was closed.
+func (vp *ViewPort) sendMouseMove(pos fyne.Position) {
+	select {
+	case vp.mouseMoveEvents <- pos:
+	case <-vp.done:
+	}
+}
+
+// Close stops the goroutines of the ViewPort, when its window is closed.
+func (vp *ViewPort) Close() {
+	if vp.dragEvents != nil {
+		vp.DragEnd()
+	}
+	select {
+	case <-vp.done:
+		// Already closed.
+	default:
+		close(vp.done)
 	}
 }
 
 // MouseOut implements desktop.Hoverable.
 func (vp *ViewPort) MouseOut() {
 	vp.mouseIn = false
+	if vp.gs.inspector != nil {
+		vp.gs.inspector.SetText("")
+	}
 }
 
 // processMouseMoveEvent is the function that actually acts on a
 // mouse movement event.
 func (vp *ViewPort) processMouseMoveEvent(pos fyne.Position) {
+	vp.inspect(pos)
 	if vp.cursor != nil {
 		vp.cursor.Move(pos)
-		vp.Refresh()
+		canvas.Refresh(vp) // The cache is still valid, no need to render it again.
 	}
 }
 
@@ -517,11 +531,13 @@ func (vp *ViewPort) processMouseMoveEvent(pos fyne.Position) {
 // drains the mouse movement events before acting on the
 // last of them.
 func (vp *ViewPort) consumeMouseMoveEvents() {
-	// vp.mouseMoveEvents is only closed if app is exiting.
+	// It returns when the ViewPort is closed, see Close.
 	for {
 		// Wait for something to happen.
-		ev, ok := <-vp.mouseMoveEvents
-		if !ok {
+		var ev fyne.Position
+		select {
+		case ev = <-vp.mouseMoveEvents:
+		case <-vp.done:
 			return
 		}
 
@@ -530,11 +546,7 @@ func (vp *ViewPort) consumeMouseMoveEvents() {
 	mouseMoveEventsLoop:
 		for {
 			select {
-			case newEvent, ok := <-vp.mouseMoveEvents:
-				if !ok {
-					return
-				}
-
+			case newEvent := <-vp.mouseMoveEvents:
 				// New event arrived.
 				consumed++
 				ev = newEvent
@@ -556,6 +568,13 @@ func (vp *ViewPort) SetOp(op OperationType) {
 	if vp.dragEvents != nil {
 		vp.DragEnd()
 	}
+	if vp.currentOperation == Crop && op != Crop {
+		vp.gs.cancelCrop()
+	}
+	if vp.currentOperation == Measure && op != Measure {
+		vp.currentOperation = op
+		vp.gs.endMeasure()
+	}
 	vp.currentOperation = op
 	switch op {
 	case NoOp:
@@ -564,13 +583,12 @@ func (vp *ViewPort) SetOp(op OperationType) {
 			vp.Refresh()
 		}
 
-	case CropTopLeft:
-		vp.cursor = vp.cursorCropTopLeft
-		vp.cursor.Resize(cursorSize)
-
-	case CropBottomRight:
-		vp.cursor = vp.cursorCropBottomRight
-		vp.cursor.Resize(cursorSize)
+	case Crop:
+		// The crop tool draws its own handles.
+		if vp.cursor != nil {
+			vp.cursor = nil
+			vp.Refresh()
+		}
 
 	case DrawCircle:
 		vp.cursor = vp.cursorDrawCircle
@@ -586,6 +604,29 @@ func (vp *ViewPort) SetOp(op OperationType) {
 		vp.cursor = vp.cursorDrawText
 		vp.cursor.Resize(cursorSize)
 		vp.gs.status.SetText("Click to define center location of text.")
+
+	case MoveCursor:
+		vp.cursor = vp.gs.cursorImage()
+		vp.cursor.Resize(cursorSize)
+		vp.gs.status.SetText("Click where the mouse cursor should point to.")
+
+	case Measure:
+		if vp.cursor != nil {
+			vp.cursor = nil
+			vp.Refresh()
+		}
+		vp.gs.status.SetText("Measure: drag between two points to measure the distance.")
+
+	case Eyedropper:
+		vp.cursor = vp.cursorPick
+		vp.cursor.Resize(cursorSize)
+		vp.gs.status.SetText(fmt.Sprintf("Click to pick the %s color, right-click to pick the background color of texts.",
+			strings.ToLower(colorSlotNames[vp.gs.colorSlot])))
+
+	case Restyle:
+		vp.cursor = vp.cursorRestyle
+		vp.cursor.Resize(cursorSize)
+		vp.gs.status.SetText("Click an annotation to apply the selected style to it.")
 	}
 }
 
@@ -614,20 +655,37 @@ func (vp *ViewPort) Tapped(ev *fyne.PointEvent) {
 	switch vp.currentOperation {
 	case NoOp:
 		// Nothing ...
-	case CropTopLeft:
-		vp.cropTopLeft(screenshotX, screenshotY)
-	case CropBottomRight:
-		vp.cropBottomRight(screenshotX, screenshotY)
+	case Crop, Measure:
+		// These tools are only dragged, and stay active until applied or cancelled.
+		return
 	case DrawCircle, DrawArrow:
 		vp.gs.status.SetText("You must drag to draw a arrow/circle.")
 	case DrawText:
-		vp.createTextFilter(absolutePoint)
+		vp.createTextFilter(vp.snappedPos(ev.Position))
+	case MoveCursor:
+		vp.gs.MoveCursor(absolutePoint)
+	case Eyedropper:
+		vp.gs.PickColor(absolutePoint, vp.gs.colorSlot)
+	case Restyle:
+		vp.gs.RestyleAt(absolutePoint)
 	}
 
 	// After a tap
 	vp.SetOp(NoOp)
 }
 
+// TappedSecondary implements fyne.SecondaryTappable: with the eyedropper it picks the background
+// color.
+func (vp *ViewPort) TappedSecondary(ev *fyne.PointEvent) {
+	glog.V(2).Infof("TappedSecondary(pos=%+v, op=%d)", ev.Position, vp.currentOperation)
+	if vp.currentOperation != Eyedropper {
+		return
+	}
+	screenshotX, screenshotY := vp.screenshotPos(ev.Position)
+	vp.gs.PickColor(image.Pt(screenshotX, screenshotY).Add(vp.gs.CropRect.Min), textBackgroundSlot)
+	vp.SetOp(NoOp)
+}
+
 func (vp *ViewPort) createTextFilter(center image.Point) {
 	var form dialog.Dialog
 	textEntry := widget.NewMultiLineEntry()
@@ -635,13 +693,14 @@ func (vp *ViewPort) createTextFilter(center image.Point) {
 	fontSize := widget.NewEntry()
 	fontSize.SetText(fmt.Sprintf("%g", vp.FontSize))
 	fontSize.Validator = validation.NewRegexp(`\d`, "Must contain a number")
+	font := widget.NewSelect(filters.FontNames, nil)
+	font.SetSelected(vp.Font)
 	bgColorRect := canvas.NewRectangle(vp.BackgroundColor)
 	bgColorRect.SetMinSize(fyne.NewSize(200, 20))
 	picker := dialog.NewColorPicker(
 		"Pick a Color", "Select background color for text",
 		func(c color.Color) {
-			vp.BackgroundColor = c
-			vp.gs.SetColorPreference(BackgroundColorPreference, c)
+			vp.gs.SetBackgroundColor(c)
 			bgColorRect.FillColor = vp.BackgroundColor
 			bgColorRect.Refresh()
 			form.Refresh()
@@ -652,7 +711,7 @@ func (vp *ViewPort) createTextFilter(center image.Point) {
 		widget.NewButtonWithIcon("", resources.ColorWheel, func() { picker.Show() }),
 		// No color button
 		widget.NewButtonWithIcon("", resources.Reset, func() {
-			vp.BackgroundColor = Transparent
+			vp.gs.SetBackgroundColor(Transparent)
 			bgColorRect.FillColor = vp.BackgroundColor
 			bgColorRect.Refresh()
 		}),
@@ -660,6 +719,7 @@ func (vp *ViewPort) createTextFilter(center image.Point) {
 	)
 	items := []*widget.FormItem{
 		widget.NewFormItem("Text", textEntry),
+		widget.NewFormItem("Font", font),
 		widget.NewFormItem("Font size", fontSize),
 		widget.NewFormItem("Background", backgroundEntry),
 	}
@@ -674,7 +734,10 @@ func (vp *ViewPort) createTextFilter(center image.Point) {
 				}
 				vp.FontSize = fSize
 				vp.gs.App.Preferences().SetFloat(FontSizePreference, fSize)
+				vp.Font = font.Selected
+				vp.gs.App.Preferences().SetString(FontPreference, vp.Font)
 				textFilter := filters.NewText(textEntry.Text, center, vp.DrawingColor, vp.BackgroundColor, fSize)
+				textFilter.SetFont(vp.Font)
 				vp.gs.Filters = append(vp.gs.Filters, textFilter)
 				vp.gs.ApplyFilters(true)
 				vp.gs.status.SetText("Text drawn, use Control+Z to undo.")
@@ -685,27 +748,12 @@ func (vp *ViewPort) createTextFilter(center image.Point) {
 	vp.gs.Win.Canvas().Focus(textEntry)
 }
 
-// cropTopLeft will crop the screenshot on this position.
-func (vp *ViewPort) cropTopLeft(x, y int) {
-	vp.gs.CropRect.Min = vp.gs.CropRect.Min.Add(image.Point{X: x, Y: y})
-	vp.gs.ApplyFilters(true)
-	vp.viewX, vp.viewY = 0, 0 // Move view to cropped corner.
-	glog.V(2).Infof("cropTopLeft: new cropRect is %+v", vp.gs.CropRect)
-	vp.postCrop()
-}
-
-// cropBottomRight will crop the screenshot on this position.
-func (vp *ViewPort) cropBottomRight(x, y int) {
-	vp.gs.CropRect.Max = vp.gs.CropRect.Max.Sub(
-		image.Point{X: vp.gs.CropRect.Dx() - x, Y: vp.gs.CropRect.Dy() - y})
-	vp.gs.ApplyFilters(true)
-	vp.viewX, vp.viewY = x-vp.viewW, y-vp.viewH // Move view to cropped corner.
-	vp.postCrop()
-}
-
 func (vp *ViewPort) cropReset() {
 	vp.viewX += vp.gs.CropRect.Min.X
 	vp.viewY += vp.gs.CropRect.Min.Y
+	if vp.gs.CropRect != vp.gs.OriginalScreenshot.Rect {
+		vp.gs.setEdited()
+	}
 	vp.gs.CropRect = vp.gs.OriginalScreenshot.Rect
 	vp.gs.ApplyFilters(true)
 	vp.postCrop()
@@ -724,7 +772,6 @@ func (vp *ViewPort) postCrop() {
 	}
 
 	vp.updateViewSize()
-	vp.renderCache()
 	vp.Refresh()
 	vp.gs.miniMap.updateViewPortRect()
 	vp.gs.miniMap.Refresh()
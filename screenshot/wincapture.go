@@ -0,0 +1,119 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/frame"
+	"github.com/janpfeifer/goshot/xwindow"
+	"github.com/kbinani/screenshot"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Window capture modes, for CaptureOptions.Window.
+const (
+	WindowActive = "active" // Capture the active window.
+	WindowPick   = "pick"   // Capture the window the user clicks on.
+)
+
+// Preferences of the window capture style.
+const (
+	// WindowDecorationsPreference includes the window decorations (title bar and borders), true by default.
+	WindowDecorationsPreference = "WindowDecorations"
+
+	// WindowPaddingPreference is the transparent margin, in pixels, added around the window.
+	WindowPaddingPreference = "WindowPadding"
+
+	// WindowShadowPreference adds a drop shadow to the window, in the margin around it.
+	WindowShadowPreference = "WindowShadow"
+)
+
+// shadowSize is the size of the drop shadow, in pixels, and shadowOffset its vertical offset.
+const (
+	shadowSize   = 16
+	shadowOffset = 4
+)
+
+// WindowStyle configures how a captured window is presented.
+type WindowStyle struct {
+	Decorations bool
+	Padding     int
+	Shadow      bool
+}
+
+// WindowStyleFromPreferences returns the WindowStyle set in the preferences.
+func WindowStyleFromPreferences(prefs fyne.Preferences) WindowStyle {
+	return WindowStyle{
+		Decorations: prefs.BoolWithFallback(WindowDecorationsPreference, true),
+		Padding:     prefs.Int(WindowPaddingPreference),
+		Shadow:      prefs.Bool(WindowShadowPreference),
+	}
+}
+
+// findWindow returns the window to capture for the given mode.
+func findWindow(mode string) (xwindow.Window, error) {
+	switch mode {
+	case WindowActive:
+		return xwindow.Active()
+	case WindowPick:
+		return xwindow.Pick()
+	}
+	return xwindow.Window{}, fmt.Errorf("unknown window capture mode %q", mode)
+}
+
+// MakeWindowScreenshot captures the window, styled as configured, as the screenshot to edit. The
+// window title is kept in Title.
+func (gs *GoShot) MakeWindowScreenshot(win xwindow.Window, style WindowStyle) error {
+	rect := win.Bounds
+	if style.Decorations {
+		rect = win.Frame
+	}
+	// Only the visible part of the window can be captured.
+	var screen image.Rectangle
+	for _, display := range Displays() {
+		screen = screen.Union(display)
+	}
+	rect = rect.Intersect(screen)
+	if rect.Empty() {
+		return fmt.Errorf("window %q is not visible", win.Title)
+	}
+	glog.V(2).Infof("Capturing window %q: %+v", win.Title, rect)
+	cursor := captureCursor()
+	img, err := screenshot.CaptureRect(rect)
+	if err != nil {
+		return err
+	}
+	gs.Title = win.Title
+	styled := styleWindow(img, style)
+	gs.setCapture(styled)
+	if styled == img {
+		gs.captureBounds = rect
+	}
+	padding := (styled.Rect.Dx() - img.Rect.Dx()) / 2
+	gs.setCursor(cursor, rect.Min.Sub(image.Pt(padding, padding)))
+	return nil
+}
+
+// styleWindow adds the transparent padding and the drop shadow around the image of the window.
+func styleWindow(img *image.RGBA, style WindowStyle) *image.RGBA {
+	padding := style.Padding
+	if style.Shadow && padding < shadowSize+shadowOffset {
+		padding = shadowSize + shadowOffset
+	}
+	if padding <= 0 {
+		return img
+	}
+	bounds := img.Bounds()
+	styled := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+2*padding, bounds.Dy()+2*padding))
+	windowRect := image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy())
+	if style.Shadow {
+		shadow := image.NewAlpha(styled.Rect)
+		draw.Draw(shadow, windowRect.Add(image.Pt(0, shadowOffset)), image.NewUniform(color.Alpha{A: 128}), image.Point{}, draw.Src)
+		frame.Blur(shadow, shadowSize/2)
+		draw.DrawMask(styled, styled.Rect, image.Black, image.Point{}, shadow, image.Point{}, draw.Over)
+	}
+	draw.Draw(styled, windowRect, img, bounds.Min, draw.Src)
+	return styled
+}
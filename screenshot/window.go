@@ -11,45 +11,102 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
 	"github.com/janpfeifer/goshot/resources"
+	"github.com/janpfeifer/goshot/transform"
+	"image"
 	"image/color"
 	"strconv"
+	"strings"
 )
 
 func (gs *GoShot) BuildEditWindow() {
-	gs.Win = gs.App.NewWindow(fmt.Sprintf("GoShot: screenshot @ %s", gs.ScreenshotTime.Format("2006-01-02 15:04:05")))
+	clipboard.HistorySize = gs.App.Preferences().IntWithFallback(ClipboardHistorySizePreference, clipboard.HistorySize)
+	gs.Win = gs.Session.newWindow(fmt.Sprintf("GoShot: screenshot @ %s", gs.ScreenshotTime.Format("2006-01-02 15:04:05")),
+		gs.onWindowClosed)
 	gs.Win.SetIcon(resources.GoShotIconPng)
 
 	// Build menu.
 	menuFile := fyne.NewMenu("File",
 		fyne.NewMenuItem(fmt.Sprintf("Save (%s)", SaveShortcutDesc), func() { gs.SaveImage() }),
 		fyne.NewMenuItem("Delayed screenshot", func() { gs.DelayedScreenshotForm() }),
+		fyne.NewMenuItem("Scrolling capture of crop", func() { gs.ScrollingCapture(ScrollManual) }),
+		fyne.NewMenuItem("Auto-scrolling capture of crop", func() { gs.ScrollingCapture(ScrollAuto) }),
+		fyne.NewMenuItem("Record crop", func() { gs.StartRecording() }),
+		fyne.NewMenuItem("Save animation", func() { gs.SaveAnimation() }),
+		fyne.NewMenuItem(fmt.Sprintf("Paste image (%s)", PasteShortcutDesc), func() { gs.PasteImageFromClipboard() }),
 	) // Quit is added automatically.
+	if gs.Session.KeepRunning {
+		// Only this window is closed, the application keeps running.
+		closeItem := fyne.NewMenuItem("Close", func() { gs.Session.CloseWindow(gs.Win) })
+		closeItem.IsQuit = true
+		menuFile.Items = append(menuFile.Items, fyne.NewMenuItemSeparator(), closeItem)
+	}
+
+	menuImage := fyne.NewMenu("Image",
+		fyne.NewMenuItem("Rotate right", func() { gs.TransformImage(transform.Rotate{Degrees: 90}) }),
+		fyne.NewMenuItem("Rotate left", func() { gs.TransformImage(transform.Rotate{Degrees: 270}) }),
+		fyne.NewMenuItem("Rotate 180°", func() { gs.TransformImage(transform.Rotate{Degrees: 180}) }),
+		fyne.NewMenuItem("Flip horizontally", func() { gs.TransformImage(transform.Flip{}) }),
+		fyne.NewMenuItem("Flip vertically", func() { gs.TransformImage(transform.Flip{Vertical: true}) }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Resize ...", func() { gs.ResizeForm() }),
+		fyne.NewMenuItem("Add padding ...", func() { gs.PaddingForm() }),
+	)
 
+	gs.frameMenu = fyne.NewMenu("Frame")
+	frameItem := fyne.NewMenuItem("Frame", nil)
+	frameItem.ChildMenu = gs.frameMenu
 	menuShare := fyne.NewMenu("Share",
 		fyne.NewMenuItem(fmt.Sprintf("Copy (%s)", CopyShortcutDesc), func() { gs.CopyImageToClipboard() }),
+		fyne.NewMenuItem("Clipboard history ...", func() { gs.ShowClipboardHistory() }),
 		fyne.NewMenuItem(fmt.Sprintf("GoogleDrive (%s)", DriveShortcutDesc), func() { gs.ShareWithGoogleDrive() }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Shared images ...", func() { gs.ShowSharedWindow() }),
+		fyne.NewMenuItemSeparator(),
+		frameItem,
+	)
+	menuView := fyne.NewMenu("View",
+		fyne.NewMenuItem("Zoom in (ctrl++)", func() { gs.viewPort.ZoomIn() }),
+		fyne.NewMenuItem("Zoom out (ctrl+-)", func() { gs.viewPort.ZoomOut() }),
+		fyne.NewMenuItem("Fit to window (ctrl+0)", func() { gs.viewPort.ZoomFit() }),
+		fyne.NewMenuItem("Actual size, 100% (ctrl+1)", func() { gs.viewPort.ZoomActualSize() }),
+		fyne.NewMenuItem("Zoom to selection (ctrl+2)", func() { gs.viewPort.ZoomToSelection() }),
+		fyne.NewMenuItemSeparator(),
+		gs.preferenceMenuItem("Pixel grid (zoom ≥ 800%)", PixelGridPreference, true),
+		gs.preferenceMenuItem("Rulers", RulersPreference, false),
+		gs.preferenceMenuItem("Grid", GridPreference, false),
+		fyne.NewMenuItem("Grid size ...", func() { gs.GridSizeForm() }),
+		fyne.NewMenuItemSeparator(),
+		gs.preferenceMenuItem("Snap to grid", SnapToGridPreference, false),
+		gs.preferenceMenuItem("Snap to edges", SnapToEdgesPreference, false),
+	)
+	gs.styleMenu = fyne.NewMenu("Styles")
+	stylesItem := fyne.NewMenuItem("Annotation styles", nil)
+	stylesItem.ChildMenu = gs.styleMenu
+	menuPalette := fyne.NewMenu("Palette",
+		fyne.NewMenuItem("Add current color", func() { gs.AddSwatch() }),
+		fyne.NewMenuItem("Import ...", func() { gs.ImportPalette() }),
+		fyne.NewMenuItem("Export as JSON ...", func() { gs.ExportPalette(false) }),
+		fyne.NewMenuItem("Export as GIMP palette ...", func() { gs.ExportPalette(true) }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Reset to default colors", func() { gs.ResetPalette() }),
+		fyne.NewMenuItem("Clear recent colors", func() { gs.ClearRecentColors() }),
+		fyne.NewMenuItemSeparator(),
+		stylesItem,
 	)
 	menuHelp := fyne.NewMenu("Help",
 		fyne.NewMenuItem("Shortcuts (ctrl+?)", func() { gs.ShowShortcutsPage() }),
 	)
-	mainMenu := fyne.NewMainMenu(menuFile, menuShare, menuHelp)
+	mainMenu := fyne.NewMainMenu(menuFile, menuImage, menuView, menuPalette, menuShare, menuHelp)
 	gs.Win.SetMainMenu(mainMenu)
 
 	// Image canvas.
 	gs.viewPort = NewViewPort(gs)
 
 	// Side toolbar.
-	cropTopLeft := widget.NewButtonWithIcon("", resources.CropTopLeft,
-		func() {
-			gs.status.SetText("Click on new top-left corner")
-			gs.viewPort.SetOp(CropTopLeft)
-		})
-	cropBottomRight := widget.NewButtonWithIcon("", resources.CropBottomRight,
-		func() {
-			gs.status.SetText("Click on new bottom-right corner")
-			gs.viewPort.SetOp(CropBottomRight)
-		})
+	cropButton := widget.NewButtonWithIcon("Crop (alt+j)", resources.CropTopLeft,
+		func() { gs.StartCrop(false) })
 	cropReset := widget.NewButtonWithIcon("", resources.Reset, func() {
 		gs.viewPort.cropReset()
 		gs.viewPort.SetOp(NoOp)
@@ -69,21 +126,36 @@ func (gs *GoShot) BuildEditWindow() {
 		}
 	}
 
-	gs.colorSample = canvas.NewRectangle(gs.viewPort.DrawingColor)
+	gs.colorSample = canvas.NewRectangle(gs.slotColor(gs.colorSlot))
 	size1d := theme.IconInlineSize()
 	size := fyne.NewSize(5*size1d, size1d)
 	gs.colorSample.SetMinSize(size)
 	gs.colorSample.Resize(size)
 
+	eyedropperNames := make([]string, len(eyedropperSizes))
+	for ii, size := range eyedropperSizes {
+		eyedropperNames[ii] = formatEyedropperSize(size)
+	}
+	eyedropperSize := widget.NewSelect(eyedropperNames, func(selected string) {
+		if size, err := parseEyedropperSize(selected); err == nil {
+			gs.App.Preferences().SetInt(EyedropperSizePreference, size)
+		}
+	})
+	eyedropperSize.SetSelected(formatEyedropperSize(gs.eyedropperSize()))
+
+	gs.cursorCheck = widget.NewCheck("Mouse cursor", func(checked bool) { gs.ShowCursor(checked) })
+	moveCursor := widget.NewButton("Move", func() {
+		if gs.Cursor != nil {
+			gs.viewPort.SetOp(MoveCursor)
+		}
+	})
+	gs.updateCursorCheck()
+
 	gs.miniMap = NewMiniMap(gs, gs.viewPort)
 	toolBar := container.NewVBox(
 		gs.miniMap,
-		container.NewHBox(
-			widget.NewLabel("Crop:"),
-			cropTopLeft,
-			cropBottomRight,
-			cropReset,
-		),
+		container.NewBorder(nil, nil, nil, cropReset, cropButton),
+		gs.buildCropPanel(),
 		widget.NewButtonWithIcon("Arrow (alt+a)", resources.DrawArrow,
 			func() { gs.viewPort.SetOp(DrawArrow) }),
 		circleButton,
@@ -92,36 +164,53 @@ func (gs *GoShot) BuildEditWindow() {
 			widget.NewButtonWithIcon("", resources.ColorWheel, func() { gs.colorPicker() }),
 			gs.colorSample,
 		),
+		gs.buildPalettePanel(),
+		gs.buildStyleSelect(),
+		container.NewBorder(nil, nil, nil, eyedropperSize,
+			widget.NewButtonWithIcon("Eyedropper (alt+e)", theme.ColorPaletteIcon(),
+				func() { gs.viewPort.SetOp(Eyedropper) })),
 		widget.NewButtonWithIcon("Text (alt+t)", resources.DrawText,
 			func() { gs.viewPort.SetOp(DrawText) }),
+		widget.NewButtonWithIcon("Measure (alt+m)", theme.GridIcon(),
+			func() { gs.viewPort.SetOp(Measure) }),
+		container.NewHBox(gs.cursorCheck, moveCursor),
+		gs.buildFrameSelect(),
 	)
 
 	// Status bar with zoom control.
-	gs.zoomEntry = &widget.Entry{Validator: validation.NewRegexp(`\d`, "Must contain a number")}
-	gs.zoomEntry.SetPlaceHolder("0.0")
-	gs.zoomEntry.OnChanged = func(str string) {
+	gs.zoomEntry = &widget.Entry{Validator: validation.NewRegexp(`^\s*\d+(\.\d*)?\s*%?\s*$`, "Must be a percentage")}
+	gs.zoomEntry.SetText(formatZoom(gs.viewPort.Log2Zoom))
+	gs.zoomEntry.OnSubmitted = func(str string) {
 		glog.V(2).Infof("Zoom level changed to %s", str)
-		val, err := strconv.ParseFloat(str, 64)
-		if err == nil {
-			gs.viewPort.Log2Zoom = val
-			gs.viewPort.updateViewSize()
-			gs.viewPort.Refresh()
+		log2Zoom, err := parseZoom(str)
+		if err != nil {
+			gs.status.SetText(err.Error())
+			return
 		}
+		w, h := gs.viewPort.PixelSize()
+		gs.viewPort.SetZoom(log2Zoom, image.Pt(w/2, h/2))
 	}
-	zoomReset := widget.NewButton("", func() {
-		gs.zoomEntry.SetText("0")
-		gs.viewPort.Log2Zoom = 0
-		gs.viewPort.updateViewSize()
-		gs.viewPort.Refresh()
-	})
+	zoomFit := widget.NewButtonWithIcon("", theme.ViewFullScreenIcon(), func() { gs.viewPort.ZoomFit() })
+	zoomReset := widget.NewButton("", func() { gs.viewPort.ZoomActualSize() })
 	zoomReset.SetIcon(resources.Reset)
 	gs.status = widget.NewLabel(fmt.Sprintf("Image size: %s", gs.Screenshot.Bounds()))
+	gs.inspector = widget.NewLabel("")
+	gs.inspector.TextStyle.Monospace = true
+	copyColor := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() { gs.CopyInspectedColor() })
+
+	// Upload progress and cancel button: only visible during uploads.
+	gs.uploadProgress = widget.NewProgressBar()
+	gs.uploadCancel = widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), func() { gs.CancelUpload() })
+	gs.uploadBox = container.NewHBox(
+		container.NewGridWrap(fyne.NewSize(160, gs.uploadProgress.MinSize().Height), gs.uploadProgress),
+		gs.uploadCancel)
+	gs.uploadBox.Hide()
 
 	statusBar := container.NewBorder(
 		nil,
 		nil,
 		nil,
-		container.NewHBox(widget.NewLabel("Zoom:"), gs.zoomEntry, zoomReset),
+		container.NewHBox(gs.uploadBox, gs.inspector, copyColor, widget.NewLabel("Zoom:"), gs.zoomEntry, zoomFit, zoomReset),
 		gs.status,
 	)
 
@@ -138,25 +227,39 @@ func (gs *GoShot) BuildEditWindow() {
 	gs.Win.Resize(fyne.NewSize(1024.0, 768.0))
 
 	// Register shortcuts.
-	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyQ, Modifier: desktop.ControlModifier},
+	gs.addShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyQ, Modifier: desktop.ControlModifier},
 		func(shortcut fyne.Shortcut) {
 			glog.Infof("Quit requested by shortcut %s", shortcut.ShortcutName())
+			if gs.Session.KeepRunning {
+				gs.Session.CloseWindow(gs.Win)
+				return
+			}
+			gs.autoSaveEdits()
 			gs.App.Quit()
 		})
 
 	gs.RegisterShortcuts()
 }
 
+// preferenceMenuItem returns a checked menu item that toggles the boolean preference, and
+// refreshes the ViewPort.
+func (gs *GoShot) preferenceMenuItem(label, key string, defaultValue bool) *fyne.MenuItem {
+	item := fyne.NewMenuItem(label, nil)
+	item.Checked = gs.App.Preferences().BoolWithFallback(key, defaultValue)
+	item.Action = func() {
+		item.Checked = !item.Checked
+		gs.App.Preferences().SetBool(key, item.Checked)
+		gs.Win.MainMenu().Refresh()
+		gs.viewPort.Refresh()
+	}
+	return item
+}
+
 func (gs *GoShot) colorPicker() {
 	glog.V(2).Infof("colorPicker():")
 	picker := dialog.NewColorPicker(
-		"Pick a Color", "Select color for edits",
-		func(c color.Color) {
-			gs.viewPort.DrawingColor = c
-			gs.SetColorPreference(DrawingColorPreference, c)
-			gs.colorSample.FillColor = c
-			gs.colorSample.Refresh()
-		},
+		"Pick a Color", fmt.Sprintf("Select the %s color", strings.ToLower(colorSlotNames[gs.colorSlot])),
+		func(c color.Color) { gs.SetSlotColor(gs.colorSlot, c) },
 		gs.Win)
 	picker.Show()
 }
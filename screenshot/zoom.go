@@ -0,0 +1,141 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Limits and steps of the zoom, in log2 of the zoom multiplier (see ViewPort.Log2Zoom).
+const (
+	minLog2Zoom  = -6   // 1.5%
+	maxLog2Zoom  = 6    // 6400%
+	log2ZoomStep = 0.5  // Zoom in and out: by a factor of √2.
+	panStep      = 0.1  // Arrow keys: fraction of the view panned.
+	fitMargin    = 0.95 // Fit: fraction of the ViewPort used, to leave a margin around the image.
+)
+
+// SetZoom sets the zoom (Log2Zoom), keeping the screenshot pixel under `anchor` (a ViewPort pixel)
+// in place, and updates the zoom shown.
+func (vp *ViewPort) SetZoom(log2Zoom float64, anchor image.Point) {
+	log2Zoom = math.Max(minLog2Zoom, math.Min(maxLog2Zoom, log2Zoom))
+	screenshotX := int(math.Round(float64(anchor.X)*vp.zoom())) + vp.viewX
+	screenshotY := int(math.Round(float64(anchor.Y)*vp.zoom())) + vp.viewY
+	vp.Log2Zoom = log2Zoom
+	vp.updateViewSize()
+	vp.viewX = screenshotX - int(math.Round(float64(anchor.X)*vp.zoom()))
+	vp.viewY = screenshotY - int(math.Round(float64(anchor.Y)*vp.zoom()))
+	vp.postZoom()
+}
+
+// postZoom refreshes the elements that depend on the zoom or the view position.
+func (vp *ViewPort) postZoom() {
+	vp.updateZoomEntry()
+	vp.Refresh()
+	if vp.gs.miniMap != nil {
+		vp.gs.miniMap.updateViewPortRect()
+	}
+}
+
+// updateZoomEntry shows the zoom as a percentage.
+func (vp *ViewPort) updateZoomEntry() {
+	if vp.gs.zoomEntry != nil {
+		vp.gs.zoomEntry.SetText(formatZoom(vp.Log2Zoom))
+	}
+}
+
+// formatZoom formats the zoom as a percentage.
+func formatZoom(log2Zoom float64) string {
+	percent := 100 * math.Exp2(log2Zoom)
+	if percent < 10 {
+		return fmt.Sprintf("%.1f%%", percent)
+	}
+	return fmt.Sprintf("%.0f%%", percent)
+}
+
+// parseZoom parses a zoom percentage, with or without the "%", and returns its log2 multiplier.
+func parseZoom(text string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "%")), 64)
+	if err != nil || percent <= 0 {
+		return 0, fmt.Errorf("invalid zoom %q, it must be a positive percentage", text)
+	}
+	return math.Log2(percent / 100), nil
+}
+
+// zoomAnchor returns the ViewPort pixel the zoom is anchored at: the mouse position if it's over
+// the ViewPort, or its center.
+func (vp *ViewPort) zoomAnchor() image.Point {
+	if vp.mouseIn {
+		x, y := vp.PosToPixel(vp.mousePos)
+		return image.Pt(x, y)
+	}
+	w, h := vp.PixelSize()
+	return image.Pt(w/2, h/2)
+}
+
+// ZoomIn magnifies the view by a factor of √2.
+func (vp *ViewPort) ZoomIn() { vp.SetZoom(vp.Log2Zoom+log2ZoomStep, vp.zoomAnchor()) }
+
+// ZoomOut reduces the view by a factor of √2.
+func (vp *ViewPort) ZoomOut() { vp.SetZoom(vp.Log2Zoom-log2ZoomStep, vp.zoomAnchor()) }
+
+// ZoomActualSize sets the zoom to 100%, one screenshot pixel per screen pixel.
+func (vp *ViewPort) ZoomActualSize() { vp.SetZoom(0, vp.zoomAnchor()) }
+
+// ZoomFit zooms to fit the whole (cropped) screenshot in the ViewPort.
+func (vp *ViewPort) ZoomFit() {
+	vp.ZoomToRect(vp.gs.Screenshot.Rect)
+}
+
+// ZoomToSelection zooms to fit the rectangle being selected with the crop tool, or the whole
+// (cropped) screenshot if there is no selection.
+func (vp *ViewPort) ZoomToSelection() {
+	if vp.gs.crop.active && !vp.gs.crop.rect.Empty() {
+		vp.ZoomToRect(vp.gs.crop.rect.Sub(vp.gs.CropRect.Min))
+		return
+	}
+	vp.ZoomFit()
+}
+
+// ZoomToRect zooms to fit the rectangle, in the coordinates of the Screenshot, centered in the
+// ViewPort.
+func (vp *ViewPort) ZoomToRect(rect image.Rectangle) {
+	pixelW, pixelH := vp.PixelSize()
+	if rect.Empty() || pixelW == 0 || pixelH == 0 {
+		return
+	}
+	zoom := math.Max(float64(rect.Dx())/(fitMargin*float64(pixelW)), float64(rect.Dy())/(fitMargin*float64(pixelH)))
+	vp.Log2Zoom = math.Max(minLog2Zoom, math.Min(maxLog2Zoom, -math.Log2(zoom)))
+	vp.updateViewSize()
+	vp.viewX = (rect.Min.X+rect.Max.X)/2 - vp.viewW/2
+	vp.viewY = (rect.Min.Y+rect.Max.Y)/2 - vp.viewH/2
+	vp.postZoom()
+}
+
+// Pan moves the view by the fraction of its size given, e.g. (0.1, 0) pans to the right by 10%
+// of the view width.
+func (vp *ViewPort) Pan(fractionX, fractionY float64) {
+	vp.viewX += int(math.Round(fractionX * float64(vp.viewW)))
+	vp.viewY += int(math.Round(fractionY * float64(vp.viewH)))
+	vp.postZoom()
+}
+
+// panKey pans the view with the arrow keys, and returns whether the key was handled.
+func (vp *ViewPort) panKey(key fyne.KeyName) bool {
+	switch key {
+	case fyne.KeyLeft:
+		vp.Pan(-panStep, 0)
+	case fyne.KeyRight:
+		vp.Pan(panStep, 0)
+	case fyne.KeyUp:
+		vp.Pan(0, -panStep)
+	case fyne.KeyDown:
+		vp.Pan(0, panStep)
+	default:
+		return false
+	}
+	return true
+}
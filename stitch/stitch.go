@@ -0,0 +1,146 @@
+// Package stitch joins the successive frames of a scrolling region (e.g. a long web page
+// or log view, captured while it is scrolled down) into one tall image.
+//
+// Consecutive frames are aligned by finding the vertical shift for which their rows of
+// pixels overlap best: rows are compared by their hashes, and a small fraction of mismatches
+// is tolerated, e.g. for a blinking cursor or an animation.
+package stitch
+
+import (
+	"errors"
+	"hash/fnv"
+	"image"
+)
+
+// ErrNoOverlap is returned by Stitcher.Add when the frame doesn't overlap the previous one,
+// e.g. if it was scrolled more than the height of the region.
+var ErrNoOverlap = errors.New("frame doesn't overlap the previous one")
+
+// Stitcher accumulates the frames of a scrolling region.
+type Stitcher struct {
+	// MinOverlap is the minimum number of rows two consecutive frames must share.
+	MinOverlap int
+
+	// Tolerance is the fraction of the overlapping rows allowed to differ.
+	Tolerance float64
+
+	width, height int      // Dimensions of the frames.
+	pix           []uint8  // Pixels of the stitched image, in RGBA.
+	last          []uint64 // Hashes of the rows of the last frame.
+}
+
+// New creates a Stitcher starting with the first frame. All frames must have the same size.
+func New(first *image.RGBA) *Stitcher {
+	bounds := first.Bounds()
+	s := &Stitcher{
+		MinOverlap: 16,
+		Tolerance:  0.05,
+		width:      bounds.Dx(),
+		height:     bounds.Dy(),
+	}
+	s.appendRows(first, 0)
+	s.last = rowHashes(first)
+	return s
+}
+
+// Add aligns the frame with the previous one, and appends its new rows to the stitched image.
+// It returns the number of rows appended: 0 if the frame didn't scroll. If it can't be aligned,
+// it returns ErrNoOverlap and the frame is ignored.
+func (s *Stitcher) Add(frame *image.RGBA) (int, error) {
+	bounds := frame.Bounds()
+	if bounds.Dx() != s.width || bounds.Dy() != s.height {
+		return 0, errors.New("frame size differs from the first frame")
+	}
+	hashes := rowHashes(frame)
+	shift := s.findShift(hashes)
+	if shift < 0 {
+		return 0, ErrNoOverlap
+	}
+	if shift == 0 {
+		// Keep aligning with the last frame that added rows, so small changes don't accumulate.
+		return 0, nil
+	}
+	s.last = hashes
+	s.appendRows(frame, s.height-shift)
+	return shift, nil
+}
+
+// Height returns the height of the stitched image so far.
+func (s *Stitcher) Height() int {
+	return len(s.pix) / (4 * s.width)
+}
+
+// Image returns the stitched image. It shares the pixels with the Stitcher, so it should
+// only be called once all frames are added.
+func (s *Stitcher) Image() *image.RGBA {
+	return &image.RGBA{
+		Pix:    s.pix,
+		Stride: 4 * s.width,
+		Rect:   image.Rect(0, 0, s.width, s.Height()),
+	}
+}
+
+// findShift returns by how many rows the frame with the given row hashes scrolled since the last
+// frame: the shift with the fewest mismatched rows, within the Tolerance, the smallest one in
+// case of a tie. Shift 0 (not scrolled) requires an exact match: otherwise, on sparse content
+// (e.g. mostly blank rows), small scrolls would be taken as unchanged frames. It returns -1 if
+// no shift overlaps.
+func (s *Stitcher) findShift(hashes []uint64) int {
+	if s.mismatches(hashes, 0, 0) == 0 {
+		return 0
+	}
+	best, bestMismatches := -1, 0
+	for shift := 1; shift <= s.height-s.MinOverlap; shift++ {
+		overlap := s.height - shift
+		maxMismatches := int(float64(overlap) * s.Tolerance)
+		if best >= 0 && bestMismatches-1 < maxMismatches {
+			maxMismatches = bestMismatches - 1 // Only better shifts are of interest.
+		}
+		mismatches := s.mismatches(hashes, shift, maxMismatches)
+		if mismatches > maxMismatches {
+			continue
+		}
+		best, bestMismatches = shift, mismatches
+		if mismatches == 0 {
+			break // Exact match, there can't be a better one.
+		}
+	}
+	return best
+}
+
+// mismatches returns the number of rows of the frame with the given row hashes that differ from
+// the last frame, if it scrolled by `shift` rows. It stops counting once `maxMismatches` is exceeded.
+func (s *Stitcher) mismatches(hashes []uint64, shift, maxMismatches int) int {
+	mismatches := 0
+	for row := 0; row < s.height-shift && mismatches <= maxMismatches; row++ {
+		if hashes[row] != s.last[row+shift] {
+			mismatches++
+		}
+	}
+	return mismatches
+}
+
+// appendRows appends the rows of the frame starting at `fromRow` to the stitched image.
+func (s *Stitcher) appendRows(frame *image.RGBA, fromRow int) {
+	bounds := frame.Bounds()
+	for y := bounds.Min.Y + fromRow; y < bounds.Max.Y; y++ {
+		s.pix = append(s.pix, row(frame, y)...)
+	}
+}
+
+// row returns the pixels of row `y` of the image.
+func row(img *image.RGBA, y int) []uint8 {
+	start := img.PixOffset(img.Rect.Min.X, y)
+	return img.Pix[start : start+4*img.Rect.Dx()]
+}
+
+// rowHashes returns the hashes of the rows of the image.
+func rowHashes(img *image.RGBA) []uint64 {
+	hashes := make([]uint64, 0, img.Rect.Dy())
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		h := fnv.New64a()
+		_, _ = h.Write(row(img, y))
+		hashes = append(hashes, h.Sum64())
+	}
+	return hashes
+}
@@ -0,0 +1,123 @@
+package stitch
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+const (
+	pageWidth  = 40
+	frameSize  = 200
+	pageHeight = 800
+)
+
+// sparsePage returns a mostly white page, with a short dark line of varying length every
+// 37 rows, like text lines far apart.
+func sparsePage() *image.RGBA {
+	page := image.NewRGBA(image.Rect(0, 0, pageWidth, pageHeight))
+	for y := 0; y < pageHeight; y++ {
+		for x := 0; x < pageWidth; x++ {
+			c := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			if y%37 == 5 && x < 5+(y/37)%30 {
+				c = color.RGBA{A: 0xff}
+			}
+			page.SetRGBA(x, y, c)
+		}
+	}
+	return page
+}
+
+// densePage returns a page of random pixels, where all rows differ.
+func densePage() *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	page := image.NewRGBA(image.Rect(0, 0, pageWidth, pageHeight))
+	rng.Read(page.Pix)
+	for ii := 3; ii < len(page.Pix); ii += 4 {
+		page.Pix[ii] = 0xff
+	}
+	return page
+}
+
+// frameAt returns the frame showing the page scrolled down to row `y`.
+func frameAt(page *image.RGBA, y int) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, pageWidth, frameSize))
+	copy(frame.Pix, page.Pix[page.PixOffset(0, y):])
+	return frame
+}
+
+func TestStitcher(t *testing.T) {
+	testCases := []struct {
+		name    string
+		page    *image.RGBA
+		step    int
+		wantErr error
+	}{
+		{name: "sparse content, small steps", page: sparsePage(), step: 3},
+		{name: "sparse content, large steps", page: sparsePage(), step: 90},
+		{name: "dense content, 1 row steps", page: densePage(), step: 1},
+		{name: "dense content, large steps", page: densePage(), step: 150},
+		{name: "no overlap", page: densePage(), step: frameSize + 50, wantErr: ErrNoOverlap},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(frameAt(tc.page, 0))
+			y := 0
+			for y < pageHeight-frameSize {
+				y += tc.step
+				if y > pageHeight-frameSize {
+					y = pageHeight - frameSize
+				}
+				rows, err := s.Add(frameAt(tc.page, y))
+				if tc.wantErr != nil {
+					if !errors.Is(err, tc.wantErr) {
+						t.Fatalf("Add(frame at %d): got error %v, wanted %v", y, err, tc.wantErr)
+					}
+					if s.Height() != frameSize {
+						t.Errorf("Height() = %d after an ignored frame, wanted %d", s.Height(), frameSize)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Add(frame at %d) failed: %v", y, err)
+				}
+				if rows == 0 {
+					t.Fatalf("Add(frame at %d) added no rows", y)
+				}
+
+				// A frame that didn't scroll adds nothing.
+				if rows, err := s.Add(frameAt(tc.page, y)); rows != 0 || err != nil {
+					t.Fatalf("Add(same frame at %d) = (%d, %v), wanted (0, nil)", y, rows, err)
+				}
+			}
+			if tc.wantErr != nil {
+				t.Fatalf("Expected error %v, got none", tc.wantErr)
+			}
+			img := s.Image()
+			if img.Rect.Dy() != pageHeight {
+				t.Fatalf("Stitched image is %d rows high, wanted %d", img.Rect.Dy(), pageHeight)
+			}
+			if !bytes.Equal(img.Pix, tc.page.Pix) {
+				t.Errorf("Stitched image differs from the page")
+			}
+		})
+	}
+}
+
+// TestStitcherTolerance checks that a few changed rows, like a blinking cursor, don't prevent the
+// alignment.
+func TestStitcherTolerance(t *testing.T) {
+	page := densePage()
+	s := New(frameAt(page, 0))
+	frame := frameAt(page, 20)
+	for x := 0; x < pageWidth; x++ {
+		frame.SetRGBA(x, 50, color.RGBA{A: 0xff}) // Row that changed.
+	}
+	rows, err := s.Add(frame)
+	if err != nil || rows != 20 {
+		t.Fatalf("Add() = (%d, %v), wanted (20, nil)", rows, err)
+	}
+}
@@ -0,0 +1,127 @@
+package systray
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/janpfeifer/goshot/screenshot"
+	"sort"
+	"time"
+)
+
+// ShareTimeout is the maximum time given to the "share" command to upload the image, including
+// connecting to Google Drive.
+var ShareTimeout = 5 * time.Minute
+
+// Command accepted through the control socket (see package ipc), e.g. from `goshot ctl`.
+type Command struct {
+	Usage, Description string
+	Run                func(args []string) (string, error)
+}
+
+// Commands accepted through the control socket, by name.
+var Commands = map[string]Command{
+	"capture": {
+		Usage:       "capture [delay=<duration>] [region] [display=<index>] [window[=active|pick]] [scroll[=manual|auto]] [record]",
+		Description: "Take a screenshot and open its edit window.",
+		Run: func(args []string) (string, error) {
+			opts, err := screenshot.ParseCaptureOptions(args)
+			if err != nil {
+				return "", err
+			}
+			capture(opts)
+			return "capturing screenshot", nil
+		},
+	},
+	"open": {
+		Usage:       "open <image file>",
+		Description: "Open the image file in an edit window.",
+		Run: func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", errors.New("open takes exactly one image file")
+			}
+			if _, err := session.Open(args[0]); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("opened %q", args[0]), nil
+		},
+	},
+	"copy": {
+		Usage:       "copy",
+		Description: "Copy the last screenshot, as edited if its window is open, to the clipboard.",
+		Run: func(args []string) (string, error) {
+			if len(args) != 0 {
+				return "", errors.New("no arguments expected")
+			}
+			if err := session.CopyLast(); err != nil {
+				return "", err
+			}
+			return "screenshot copied to clipboard", nil
+		},
+	},
+	"share": {
+		Usage:       "share",
+		Description: "Share the last screenshot, as edited, in Google Drive with the saved options, and print its URL.",
+		Run: func(args []string) (string, error) {
+			gs, err := lastScreenshot(args)
+			if err != nil {
+				return "", err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), ShareTimeout)
+			defer cancel()
+			return gs.ShareWithGoogleDriveAndWait(ctx)
+		},
+	},
+	"stop": {
+		Usage:       "stop",
+		Description: "Stop the recording in progress, and open its edit window.",
+		Run: func(args []string) (string, error) {
+			if len(args) != 0 {
+				return "", errors.New("no arguments expected")
+			}
+			if err := session.StopRecording(); err != nil {
+				return "", err
+			}
+			return "recording stopped", nil
+		},
+	},
+	"quit": {
+		Usage:       "quit",
+		Description: "Quit GoShot running in the system tray, closing all its windows.",
+		Run: func(args []string) (string, error) {
+			quitTray()
+			return "quitting", nil
+		},
+	},
+}
+
+// CommandNames returns the names of the Commands, sorted.
+func CommandNames() []string {
+	names := make([]string, 0, len(Commands))
+	for name := range Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handleCommand executes the commands received through the control socket.
+func handleCommand(name string, args []string) (string, error) {
+	cmd, found := Commands[name]
+	if !found {
+		return "", fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.Run(args)
+}
+
+// lastScreenshot returns the last screenshot, for the commands that take no arguments.
+func lastScreenshot(args []string) (*screenshot.GoShot, error) {
+	if len(args) != 0 {
+		return nil, errors.New("no arguments expected")
+	}
+	gs := session.Last()
+	if gs == nil {
+		return nil, errors.New("no screenshot open")
+	}
+	return gs, nil
+}
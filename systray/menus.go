@@ -0,0 +1,183 @@
+//go:build !darwin
+// +build !darwin
+
+package systray
+
+import (
+	"fmt"
+	glst "github.com/getlantern/systray"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/history"
+	"github.com/janpfeifer/goshot/screenshot"
+	"github.com/janpfeifer/goshot/xwindow"
+	"os"
+	"sync"
+	"time"
+)
+
+// addCaptureMenu adds the "Capture" sub-menu, with the capture modes.
+func addCaptureMenu() {
+	mCapture := glst.AddMenuItem("Capture", "Capture modes")
+	mFull := mCapture.AddSubMenuItem("Full display", "Screenshot of the primary display")
+	go handler(mFull, func() { capture(screenshot.CaptureOptions{}) })
+	if displays := screenshot.Displays(); len(displays) > 1 {
+		for ii, bounds := range displays {
+			opts := screenshot.CaptureOptions{Display: ii}
+			mDisplay := mCapture.AddSubMenuItem(
+				fmt.Sprintf("Display %d (%dx%d)", ii, bounds.Dx(), bounds.Dy()),
+				fmt.Sprintf("Screenshot of display %d", ii))
+			go handler(mDisplay, func() { capture(opts) })
+		}
+	}
+	mRegion := mCapture.AddSubMenuItem("Region", "Screenshot and select a region of it")
+	go handler(mRegion, func() { capture(screenshot.CaptureOptions{Region: true}) })
+	mRecord := mCapture.AddSubMenuItem("Record region", "Select a region, and record it as an animation")
+	go handler(mRecord, func() { capture(screenshot.CaptureOptions{Record: true}) })
+	mScroll := mCapture.AddSubMenuItem("Scrolling region", "Select a region, and capture it while you scroll it down")
+	go handler(mScroll, func() { capture(screenshot.CaptureOptions{Scroll: screenshot.ScrollManual}) })
+	mAutoScroll := mCapture.AddSubMenuItem("Auto-scrolling region", "Select a region, and capture it while GoShot scrolls it down")
+	mWindow := mCapture.AddSubMenuItem("Active window", "Screenshot of the active window")
+	mPick := mCapture.AddSubMenuItem("Pick window", "Screenshot of the window clicked next")
+	if xwindow.Supported {
+		go handler(mWindow, func() { capture(screenshot.CaptureOptions{Window: screenshot.WindowActive}) })
+		go handler(mPick, func() { capture(screenshot.CaptureOptions{Window: screenshot.WindowPick}) })
+		go handler(mAutoScroll, func() { capture(screenshot.CaptureOptions{Scroll: screenshot.ScrollAuto}) })
+	} else {
+		mAutoScroll.Disable()
+		mWindow.Disable()
+		mPick.Disable()
+	}
+}
+
+// delayMenu holds the "Delayed screenshot" sub-menu: it has one slot per delay configured in the
+// preferences (screenshot.DelaysPreference). Since menu items can't be removed, slots are hidden
+// when not in use.
+var delayMenu struct {
+	mu       sync.Mutex
+	mDelayed *glst.MenuItem // Top-level item, with the ActionDelayed.
+	slots    []*glst.MenuItem
+	delays   []time.Duration
+}
+
+// addDelayMenu adds the "Delayed screenshot" sub-menu, and keeps it and `mDelayed`, the item
+// of the ActionDelayed, updated with the delays in the preferences.
+func addDelayMenu(mDelayed *glst.MenuItem) {
+	delayMenu.mDelayed = mDelayed
+	mDelays := glst.AddMenuItem("Delayed screenshot", "Take screenshot after a delay")
+	for ii := 0; ii < maxDelayMenuItems; ii++ {
+		slot := mDelays.AddSubMenuItem("", "")
+		slot.Hide()
+		delayMenu.slots = append(delayMenu.slots, slot)
+		idx := ii
+		go handler(slot, func() {
+			delayMenu.mu.Lock()
+			defer delayMenu.mu.Unlock()
+			if idx < len(delayMenu.delays) {
+				capture(screenshot.CaptureOptions{Delay: delayMenu.delays[idx]})
+			}
+		})
+	}
+	refreshDelayMenu()
+	session.App.Preferences().AddChangeListener(refreshDelayMenu)
+}
+
+// refreshDelayMenu updates the "Delayed screenshot" sub-menu and the ActionDelayed item with the
+// delays in the preferences.
+func refreshDelayMenu() {
+	delayMenu.mu.Lock()
+	defer delayMenu.mu.Unlock()
+	delayMenu.mDelayed.SetTitle(fmt.Sprintf("Screenshot in %s", delay()))
+	delayMenu.mDelayed.SetTooltip(fmt.Sprintf("Take screenshot in %s", delay()))
+	delays := screenshot.Delays(session.App.Preferences())
+	if len(delays) > len(delayMenu.slots) {
+		delays = delays[:len(delayMenu.slots)]
+	}
+	delayMenu.delays = delays
+	for ii, slot := range delayMenu.slots {
+		if ii >= len(delays) {
+			slot.Hide()
+			continue
+		}
+		slot.SetTitle(fmt.Sprintf("In %s", delays[ii]))
+		slot.Show()
+	}
+}
+
+// recentMenu holds the "Open recent" sub-menu: it has one slot per recent screenshot, hidden when
+// not in use, like the sharedMenu.
+var recentMenu struct {
+	mu       sync.Mutex
+	slots    []*glst.MenuItem
+	captures []history.Capture
+	modTime  time.Time
+}
+
+func addRecentMenu() {
+	mRecent := glst.AddMenuItem("Open recent", "Open one of the last screenshots")
+	for ii := 0; ii < maxRecentMenuItems; ii++ {
+		slot := mRecent.AddSubMenuItem("", "")
+		slot.Hide()
+		recentMenu.slots = append(recentMenu.slots, slot)
+		idx := ii
+		go handler(slot, func() {
+			recentMenu.mu.Lock()
+			if idx >= len(recentMenu.captures) {
+				recentMenu.mu.Unlock()
+				return
+			}
+			c := recentMenu.captures[idx]
+			recentMenu.mu.Unlock()
+			if _, err := session.Open(c.Path); err != nil {
+				glog.Errorf("Failed to open recent screenshot: %v", err)
+			}
+		})
+	}
+	go func() {
+		for {
+			refreshRecentMenu()
+			time.Sleep(historyPollPeriod)
+		}
+	}()
+}
+
+// refreshRecentMenu reloads the history of screenshots, if it changed, and updates the menu slots.
+func refreshRecentMenu() {
+	indexPath, err := history.CapturesIndexPath()
+	if err != nil {
+		glog.Errorf("Failed to find screenshots history: %v", err)
+		return
+	}
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to check screenshots history: %v", err)
+		}
+		return
+	}
+
+	recentMenu.mu.Lock()
+	defer recentMenu.mu.Unlock()
+	if info.ModTime().Equal(recentMenu.modTime) {
+		return
+	}
+	captures, err := history.LoadCaptures()
+	if err != nil {
+		glog.Errorf("Failed to load screenshots history: %v", err)
+		return
+	}
+	recentMenu.modTime = info.ModTime()
+	if len(captures) > len(recentMenu.slots) {
+		captures = captures[:len(recentMenu.slots)]
+	}
+	recentMenu.captures = captures
+	for ii, slot := range recentMenu.slots {
+		if ii >= len(captures) {
+			slot.Hide()
+			continue
+		}
+		c := captures[ii]
+		slot.SetTitle(fmt.Sprintf("%s (%dx%d)", c.Time.Format("2006-01-02 15:04:05"), c.Width, c.Height))
+		slot.SetTooltip(c.Path)
+		slot.Show()
+	}
+}
@@ -1,74 +1,168 @@
 package systray
 
 import (
-	glst "github.com/getlantern/systray"
+	"fmt"
+	"fyne.io/fyne/v2/app"
 	"github.com/golang/glog"
-	"github.com/janpfeifer/goshot/resources"
-	"os/exec"
+	"github.com/janpfeifer/goshot/hotkey"
+	"github.com/janpfeifer/goshot/ipc"
+	"github.com/janpfeifer/goshot/screenshot"
+	"strings"
+	"sync"
 	"time"
 )
 
 const appIconResID = 7
 
-// PreParseArgs are the program arguments (os.Args()) before
-// running flags.Parse. Notably glog library changes os.Args to remove
-// its flags, but we want those flags to, when ForkExec'ing for
-// the snapshot.
-var PreParseArgs []string
-
-// Run runs program as a system tray. It uses PreParseArgs when
-// ForkExec'ing itself to do the snapshot, so it must be set accordingly.
-func Run() {
-	if len(PreParseArgs) == 0 {
-		glog.Fatal("systray.Run must be run after setting PreParseArgs.")
+// Actions that can be triggered from the menu or by hotkeys.
+const (
+	ActionScreenshot = "screenshot" // Screenshot of the full display.
+	ActionRegion     = "region"     // Screenshot and select a region of it.
+	ActionDelayed    = "delayed"    // Screenshot after the delay, see Delay.
+	ActionWindow     = "window"     // Screenshot of the active window.
+	ActionRecord     = "record"     // Record a region, or stop the recording in progress.
+	ActionRepeat     = "repeat"     // Repeat the last action.
+)
+
+// Delay before the screenshot of the ActionDelayed. If 0, the delay set in the preferences
+// (screenshot.DelayTimePreference) is used.
+var Delay time.Duration
+
+// delay returns the delay before the screenshot of the ActionDelayed.
+func delay() time.Duration {
+	if Delay > 0 {
+		return Delay
 	}
-	glst.Run(onReady, onExit)
+	return screenshot.DelayTime(session.App.Preferences())
 }
 
-func onReady() {
-	glst.SetIcon(resources.GoShotIconIco.Content())
-	// glst.SetTitle("GoShot (SysTray)")
-	glst.SetTooltip("Take screenshot, edit and share!")
-
-	mScreenshot := glst.AddMenuItem("Screenshot", "Take screenshot, edit and share!")
-	mScreenshotIn5 := glst.AddMenuItem("Screenshot in 5s", "Take screenshot in 5 seconds")
-	go handler(mScreenshot, onScreenshot)
-	go handler(mScreenshotIn5, func() {
-		time.Sleep(5 * time.Second)
-		onScreenshot()
-	})
-	mQuit := glst.AddMenuItem("Quit", "Quit the whole app")
-	go func() { <-mQuit.ClickedCh; glst.Quit() }()
-}
+var (
+	// session hosts the edit windows of all screenshots taken.
+	session *screenshot.Session
+
+	// actions maps the action names to their implementation. ActionRepeat is handled by runAction.
+	actions = map[string]func(){
+		ActionScreenshot: func() { capture(screenshot.CaptureOptions{}) },
+		ActionRegion:     func() { capture(screenshot.CaptureOptions{Region: true}) },
+		ActionDelayed:    func() { capture(screenshot.CaptureOptions{Delay: delay()}) },
+		ActionWindow:     func() { capture(screenshot.CaptureOptions{Window: screenshot.WindowActive}) },
+		ActionRecord:     toggleRecording,
+	}
+
+	lastActionMu sync.Mutex
+	lastAction   = ActionScreenshot
+)
 
-func onExit() {
-	glog.Infof("Exiting GoShot system tray app.")
+// runAction runs the action with the given name.
+func runAction(name string) {
+	lastActionMu.Lock()
+	if name == ActionRepeat {
+		name = lastAction
+	} else {
+		lastAction = name
+	}
+	lastActionMu.Unlock()
+	glog.V(1).Infof("Running action %q", name)
+	actions[name]()
 }
 
-func handler(item *glst.MenuItem, onClick func()) {
-	for {
-		_, ok := <-item.ClickedCh
-		if !ok { // Channel closed, return
-			return
+// capture takes a screenshot and opens its edit window, in a separate goroutine.
+func capture(opts screenshot.CaptureOptions) {
+	go func() {
+		if _, err := session.Capture(opts); err != nil {
+			glog.Errorf("%s", err)
 		}
-		onClick()
+	}()
+}
+
+// toggleRecording stops the recording in progress, or starts a new one otherwise.
+func toggleRecording() {
+	if session.Recording() {
+		stopRecording()
+		return
 	}
+	capture(screenshot.CaptureOptions{Record: true})
+}
+
+// stopRecording stops the recording in progress.
+func stopRecording() {
+	if err := session.StopRecording(); err != nil {
+		glog.Errorf("Failed to stop recording: %v", err)
+	}
+}
+
+// hotkeyBinding is a global hotkey and the action it triggers.
+type hotkeyBinding struct {
+	hk     hotkey.Hotkey
+	action string
 }
 
-func onScreenshot() {
-	glog.V(2).Infof("Args: %v", PreParseArgs)
-	args := make([]string, 0, len(PreParseArgs))
-	for _, arg := range PreParseArgs {
-		if arg == "--systray" || arg == "-systray" {
-			// Remove the -systray flag.
+// parseHotkeys parses a comma-separated list of "[action:]hotkey", e.g.
+// "win+control+s,region:win+control+r". The action defaults to ActionScreenshot.
+func parseHotkeys(spec string) (bindings []hotkeyBinding, err error) {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
 			continue
 		}
-		args = append(args, arg)
+		binding := hotkeyBinding{action: ActionScreenshot}
+		if idx := strings.Index(part, ":"); idx >= 0 {
+			binding.action = strings.ToLower(strings.TrimSpace(part[:idx]))
+			part = part[idx+1:]
+			if _, found := actions[binding.action]; !found && binding.action != ActionRepeat {
+				return nil, fmt.Errorf("unknown action %q for hotkey %q", binding.action, part)
+			}
+		}
+		binding.hk, err = hotkey.Parse(part)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, binding)
 	}
+	return bindings, nil
+}
 
-	err := exec.Command(args[0], args[1:]...).Start()
+// Run runs the program as a system tray, in one long-running process: each screenshot is
+// edited in a new window of the same application. It also listens to commands from other
+// GoShot invocations (see package ipc).
+//
+// `hotkeys` is a comma-separated list of global hotkeys to register, each optionally
+// prefixed by the action to trigger, e.g. "win+control+s,region:win+control+r".
+func Run(hotkeys string) {
+	bindings, err := parseHotkeys(hotkeys)
 	if err != nil {
-		glog.Errorf("Command attempted to execute: %v", args)
-		glog.Errorf("Failed to start GoShot to screenshot: %s", err)
+		glog.Fatalf("Invalid hotkeys %q: %v", hotkeys, err)
 	}
+	server, err := ipc.Listen(handleCommand)
+	if err != nil {
+		glog.Fatalf("Can't run in the system tray: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	session = screenshot.NewSession(app.NewWithID("GoShot"))
+	session.KeepRunning = true
+
+	runTray(bindings)
 }
+
+// registerHotkeys registers the global hotkeys, logging the ones that fail.
+func registerHotkeys(bindings []hotkeyBinding) {
+	for _, binding := range bindings {
+		action := binding.action
+		if err := hotkey.Register(binding.hk, func() { runAction(action) }); err != nil {
+			glog.Errorf("Failed to register hotkey %q for %q: %v", binding.hk, action, err)
+		} else {
+			glog.Infof("Hotkey %q triggers %q", binding.hk, action)
+		}
+	}
+}
+
+// Number of items in the "Delayed screenshot", "Open recent" and "Shared" sub-menus.
+const (
+	maxDelayMenuItems  = 10
+	maxRecentMenuItems = 10
+	maxSharedMenuItems = 10
+)
+
+// historyPollPeriod is how often the histories of shared images and screenshots are checked for changes.
+const historyPollPeriod = 5 * time.Second
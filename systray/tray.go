@@ -0,0 +1,190 @@
+//go:build !darwin
+// +build !darwin
+
+package systray
+
+import (
+	"fmt"
+	glst "github.com/getlantern/systray"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/googledrive"
+	"github.com/janpfeifer/goshot/history"
+	"github.com/janpfeifer/goshot/resources"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// runTray runs the system tray and the application. Fyne needs the main thread, so the system
+// tray runs its own event loop in a separate locked thread. On macOS both need the main thread,
+// see tray_darwin.go.
+func runTray(bindings []hotkeyBinding) {
+	go func() {
+		runtime.LockOSThread()
+		glst.Run(func() { onReady(bindings) }, onExit)
+	}()
+	session.App.Run()
+}
+
+// quitTray quits the system tray, and with it the application.
+func quitTray() {
+	glst.Quit()
+}
+
+func onReady(bindings []hotkeyBinding) {
+	glst.SetIcon(resources.GoShotIconIco.Content())
+	// glst.SetTitle("GoShot (SysTray)")
+	glst.SetTooltip("Take screenshot, edit and share!")
+
+	mScreenshot := glst.AddMenuItem("Screenshot", "Take screenshot, edit and share!")
+	mRegion := glst.AddMenuItem("Screenshot region", "Take screenshot and select a region of it")
+	mDelayed := glst.AddMenuItem("", "")
+	go handler(mScreenshot, func() { runAction(ActionScreenshot) })
+	go handler(mRegion, func() { runAction(ActionRegion) })
+	go handler(mDelayed, func() { runAction(ActionDelayed) })
+	addCaptureMenu()
+	addDelayMenu(mDelayed)
+	mStopRecording := glst.AddMenuItem("Stop recording", "Stop the recording in progress")
+	mStopRecording.Hide()
+	go handler(mStopRecording, stopRecording)
+	session.OnRecordingChanged = func(recording bool) {
+		if recording {
+			mStopRecording.Show()
+		} else {
+			mStopRecording.Hide()
+		}
+	}
+	glst.AddSeparator()
+	mCopyLast := glst.AddMenuItem("Copy last screenshot", "Copy the last screenshot to the clipboard")
+	go handler(mCopyLast, func() {
+		if err := session.CopyLast(); err != nil {
+			glog.Errorf("Failed to copy last screenshot: %v", err)
+		}
+	})
+	addRecentMenu()
+	addSharedMenu()
+	glst.AddSeparator()
+	mSettings := glst.AddMenuItem("Settings ...", "Open GoShot settings")
+	go handler(mSettings, func() { session.ShowSettings() })
+	registerHotkeys(bindings)
+	mQuit := glst.AddMenuItem("Quit", "Quit the whole app")
+	go func() { <-mQuit.ClickedCh; glst.Quit() }()
+}
+
+func onExit() {
+	glog.Infof("Exiting GoShot system tray app.")
+	session.App.Quit()
+}
+
+func handler(item *glst.MenuItem, onClick func()) {
+	for {
+		_, ok := <-item.ClickedCh
+		if !ok { // Channel closed, return
+			return
+		}
+		onClick()
+	}
+}
+
+// sharedMenu holds the "Shared" sub-menu: it has one slot per recently shared image. Since menu
+// items can't be removed, slots are hidden when not in use.
+var sharedMenu struct {
+	mu      sync.Mutex
+	slots   []*glst.MenuItem
+	entries []history.Entry
+	modTime time.Time
+}
+
+func addSharedMenu() {
+	mShared := glst.AddMenuItem("Shared", "Images shared recently")
+	mSharedWindow := mShared.AddSubMenuItem("Shared images ...", "Open window with all shared images")
+	go handler(mSharedWindow, func() { session.ShowSharedWindow() })
+	for ii := 0; ii < maxSharedMenuItems; ii++ {
+		slot := mShared.AddSubMenuItem("", "")
+		mCopy := slot.AddSubMenuItem("Copy link", "Copy link to the clipboard")
+		mOpen := slot.AddSubMenuItem("Open link", "Open link in the browser")
+		mDelete := slot.AddSubMenuItem("Delete ...", "Delete the shared image from where it was shared")
+		slot.Hide()
+		sharedMenu.slots = append(sharedMenu.slots, slot)
+
+		idx := ii
+		go handler(mCopy, func() {
+			if entry, ok := sharedEntry(idx); ok {
+				if err := clipboard.CopyText(entry.URL); err != nil {
+					glog.Errorf("Failed to copy URL to clipboard: %v", err)
+				}
+			}
+		})
+		go handler(mOpen, func() {
+			if entry, ok := sharedEntry(idx); ok {
+				if err := googledrive.OpenURL(entry.URL); err != nil {
+					glog.Errorf("Failed to open %q: %v", entry.URL, err)
+				}
+			}
+		})
+		go handler(mDelete, func() {
+			if entry, ok := sharedEntry(idx); ok {
+				session.DeleteShared(entry)
+			}
+		})
+	}
+	go func() {
+		for {
+			refreshSharedMenu()
+			time.Sleep(historyPollPeriod)
+		}
+	}()
+}
+
+// sharedEntry returns the history entry currently displayed in the given slot.
+func sharedEntry(idx int) (entry history.Entry, ok bool) {
+	sharedMenu.mu.Lock()
+	defer sharedMenu.mu.Unlock()
+	if idx >= len(sharedMenu.entries) {
+		return
+	}
+	return sharedMenu.entries[idx], true
+}
+
+// refreshSharedMenu reloads the history of shared images, if it changed, and updates the menu slots.
+func refreshSharedMenu() {
+	indexPath, err := history.IndexPath()
+	if err != nil {
+		glog.Errorf("Failed to find sharing history: %v", err)
+		return
+	}
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to check sharing history: %v", err)
+		}
+		return
+	}
+
+	sharedMenu.mu.Lock()
+	defer sharedMenu.mu.Unlock()
+	if info.ModTime().Equal(sharedMenu.modTime) {
+		return
+	}
+	entries, err := history.Load()
+	if err != nil {
+		glog.Errorf("Failed to load sharing history: %v", err)
+		return
+	}
+	sharedMenu.modTime = info.ModTime()
+	if len(entries) > len(sharedMenu.slots) {
+		entries = entries[:len(sharedMenu.slots)]
+	}
+	sharedMenu.entries = entries
+	for ii, slot := range sharedMenu.slots {
+		if ii >= len(entries) {
+			slot.Hide()
+			continue
+		}
+		slot.SetTitle(fmt.Sprintf("%s (%s)", entries[ii].Name, entries[ii].Backend))
+		slot.SetTooltip(entries[ii].URL)
+		slot.Show()
+	}
+}
@@ -0,0 +1,209 @@
+//go:build darwin
+// +build darwin
+
+package systray
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"github.com/golang/glog"
+	"github.com/janpfeifer/goshot/clipboard"
+	"github.com/janpfeifer/goshot/googledrive"
+	"github.com/janpfeifer/goshot/history"
+	"github.com/janpfeifer/goshot/resources"
+	"github.com/janpfeifer/goshot/screenshot"
+	"github.com/janpfeifer/goshot/xwindow"
+	"os"
+	"sync"
+	"time"
+)
+
+// trayMu serializes the updates of the system tray menu.
+var trayMu sync.Mutex
+
+// runTray runs the system tray and the application. On macOS both the system tray and Fyne need
+// the main thread for their Cocoa event loops, so the system tray is the one of Fyne, driven by
+// its event loop. Its menu is rebuilt whenever its content changes.
+func runTray(bindings []hotkeyBinding) {
+	desk, ok := session.App.(desktop.App)
+	if !ok {
+		glog.Fatalf("Can't run in the system tray: not supported by the Fyne driver")
+	}
+	refresh := func() {
+		trayMu.Lock()
+		defer trayMu.Unlock()
+		desk.SetSystemTrayMenu(trayMenu())
+	}
+	desk.SetSystemTrayIcon(resources.GoShotIconPng)
+	refresh()
+	session.OnRecordingChanged = func(bool) { refresh() }
+	session.App.Preferences().AddChangeListener(refresh)
+	go func() {
+		var sharedModTime, capturesModTime time.Time
+		for {
+			time.Sleep(historyPollPeriod)
+			if modTimeChanged(history.IndexPath, &sharedModTime) ||
+				modTimeChanged(history.CapturesIndexPath, &capturesModTime) {
+				refresh()
+			}
+		}
+	}()
+	registerHotkeys(bindings)
+	session.App.Run()
+	glog.Infof("Exiting GoShot system tray app.")
+}
+
+// quitTray quits the application, and with it the system tray.
+func quitTray() {
+	session.App.Quit()
+}
+
+// modTimeChanged returns whether the modification time of the file changed since `last`, and
+// updates it.
+func modTimeChanged(path func() (string, error), last *time.Time) bool {
+	filePath, err := path()
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+	if info.ModTime().Equal(*last) {
+		return false
+	}
+	*last = info.ModTime()
+	return true
+}
+
+// trayMenu returns the system tray menu, with the same items as in the other platforms. Fyne adds
+// the "Quit" item.
+func trayMenu() *fyne.Menu {
+	items := []*fyne.MenuItem{
+		fyne.NewMenuItem("Screenshot", func() { runAction(ActionScreenshot) }),
+		fyne.NewMenuItem("Screenshot region", func() { runAction(ActionRegion) }),
+		fyne.NewMenuItem(fmt.Sprintf("Screenshot in %s", delay()), func() { runAction(ActionDelayed) }),
+		captureMenuItem(),
+		delayMenuItem(),
+	}
+	if session.Recording() {
+		items = append(items, fyne.NewMenuItem("Stop recording", stopRecording))
+	}
+	items = append(items,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Copy last screenshot", func() {
+			if err := session.CopyLast(); err != nil {
+				glog.Errorf("Failed to copy last screenshot: %v", err)
+			}
+		}),
+		recentMenuItem(),
+		sharedMenuItem(),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Settings ...", func() { session.ShowSettings() }),
+	)
+	return fyne.NewMenu("GoShot", items...)
+}
+
+// subMenu returns an item opening a sub-menu with the given items.
+func subMenu(label string, items ...*fyne.MenuItem) *fyne.MenuItem {
+	item := fyne.NewMenuItem(label, nil)
+	item.ChildMenu = fyne.NewMenu(label, items...)
+	return item
+}
+
+// captureMenuItem returns the "Capture" sub-menu, with the capture modes.
+func captureMenuItem() *fyne.MenuItem {
+	items := []*fyne.MenuItem{
+		fyne.NewMenuItem("Full display", func() { capture(screenshot.CaptureOptions{}) }),
+	}
+	if displays := screenshot.Displays(); len(displays) > 1 {
+		for ii, bounds := range displays {
+			opts := screenshot.CaptureOptions{Display: ii}
+			items = append(items, fyne.NewMenuItem(
+				fmt.Sprintf("Display %d (%dx%d)", ii, bounds.Dx(), bounds.Dy()),
+				func() { capture(opts) }))
+		}
+	}
+	items = append(items,
+		fyne.NewMenuItem("Region", func() { capture(screenshot.CaptureOptions{Region: true}) }),
+		fyne.NewMenuItem("Record region", func() { capture(screenshot.CaptureOptions{Record: true}) }),
+		fyne.NewMenuItem("Scrolling region", func() { capture(screenshot.CaptureOptions{Scroll: screenshot.ScrollManual}) }),
+	)
+	if xwindow.Supported {
+		items = append(items,
+			fyne.NewMenuItem("Auto-scrolling region", func() { capture(screenshot.CaptureOptions{Scroll: screenshot.ScrollAuto}) }),
+			fyne.NewMenuItem("Active window", func() { capture(screenshot.CaptureOptions{Window: screenshot.WindowActive}) }),
+			fyne.NewMenuItem("Pick window", func() { capture(screenshot.CaptureOptions{Window: screenshot.WindowPick}) }),
+		)
+	}
+	return subMenu("Capture", items...)
+}
+
+// delayMenuItem returns the "Delayed screenshot" sub-menu, with the delays in the preferences.
+func delayMenuItem() *fyne.MenuItem {
+	var items []*fyne.MenuItem
+	delays := screenshot.Delays(session.App.Preferences())
+	if len(delays) > maxDelayMenuItems {
+		delays = delays[:maxDelayMenuItems]
+	}
+	for _, d := range delays {
+		opts := screenshot.CaptureOptions{Delay: d}
+		items = append(items, fyne.NewMenuItem(fmt.Sprintf("In %s", d), func() { capture(opts) }))
+	}
+	return subMenu("Delayed screenshot", items...)
+}
+
+// recentMenuItem returns the "Open recent" sub-menu, with the last screenshots.
+func recentMenuItem() *fyne.MenuItem {
+	var items []*fyne.MenuItem
+	captures, err := history.LoadCaptures()
+	if err != nil {
+		glog.Errorf("Failed to load screenshots history: %v", err)
+	}
+	if len(captures) > maxRecentMenuItems {
+		captures = captures[:maxRecentMenuItems]
+	}
+	for _, c := range captures {
+		path := c.Path
+		items = append(items, fyne.NewMenuItem(
+			fmt.Sprintf("%s (%dx%d)", c.Time.Format("2006-01-02 15:04:05"), c.Width, c.Height),
+			func() {
+				if _, err := session.Open(path); err != nil {
+					glog.Errorf("Failed to open recent screenshot: %v", err)
+				}
+			}))
+	}
+	return subMenu("Open recent", items...)
+}
+
+// sharedMenuItem returns the "Shared" sub-menu, with the images shared recently.
+func sharedMenuItem() *fyne.MenuItem {
+	items := []*fyne.MenuItem{
+		fyne.NewMenuItem("Shared images ...", func() { session.ShowSharedWindow() }),
+	}
+	entries, err := history.Load()
+	if err != nil {
+		glog.Errorf("Failed to load sharing history: %v", err)
+	}
+	if len(entries) > maxSharedMenuItems {
+		entries = entries[:maxSharedMenuItems]
+	}
+	for _, entry := range entries {
+		entry := entry
+		items = append(items, subMenu(fmt.Sprintf("%s (%s)", entry.Name, entry.Backend),
+			fyne.NewMenuItem("Copy link", func() {
+				if err := clipboard.CopyText(entry.URL); err != nil {
+					glog.Errorf("Failed to copy URL to clipboard: %v", err)
+				}
+			}),
+			fyne.NewMenuItem("Open link", func() {
+				if err := googledrive.OpenURL(entry.URL); err != nil {
+					glog.Errorf("Failed to open %q: %v", entry.URL, err)
+				}
+			}),
+			fyne.NewMenuItem("Delete ...", func() { session.DeleteShared(entry) }),
+		))
+	}
+	return subMenu("Shared", items...)
+}
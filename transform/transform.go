@@ -0,0 +1,205 @@
+// Package transform implements operations on the whole image: rotate, flip, resize and pad
+// (extend the canvas).
+//
+// Each operation also maps points of the image to the transformed one, so the annotations
+// drawn on the image can be moved along with its content. Points are in the coordinates of the
+// corners of the pixels: (0, 0) is the top-left corner of the image, and (width, height) its
+// bottom-right corner. That way a rectangle is mapped by mapping its corners.
+package transform
+
+import (
+	"fmt"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Transform is an operation on the whole image.
+type Transform interface {
+	// Apply returns the transformed image. The result always starts at (0, 0).
+	Apply(img *image.RGBA) *image.RGBA
+
+	// Point maps a point of an image with the given bounds to the transformed image.
+	Point(p image.Point, bounds image.Rectangle) image.Point
+
+	// Scale is the factor by which the sizes (e.g. the thickness of lines) change, for an
+	// image with the given bounds.
+	Scale(bounds image.Rectangle) float64
+
+	// String describes the operation done, e.g. "rotated 90°".
+	String() string
+}
+
+// Rect maps the rectangle `r` of an image with the given bounds with the transform.
+func Rect(t Transform, r, bounds image.Rectangle) image.Rectangle {
+	return image.Rectangle{Min: t.Point(r.Min, bounds), Max: t.Point(r.Max, bounds)}.Canon()
+}
+
+// Rotate rotates the image clockwise by Degrees, which must be 90, 180 or 270.
+type Rotate struct {
+	Degrees int
+}
+
+// Apply implements Transform.
+func (t Rotate) Apply(img *image.RGBA) *image.RGBA {
+	bounds := img.Rect
+	dst := image.NewRGBA(Rect(t, bounds, bounds))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Map the center of the pixel: its top-left corner is the min of the mapped corners.
+			p := image.Rectangle{
+				Min: t.Point(image.Pt(x, y), bounds),
+				Max: t.Point(image.Pt(x+1, y+1), bounds),
+			}.Canon().Min
+			i, j := img.PixOffset(x, y), dst.PixOffset(p.X, p.Y)
+			copy(dst.Pix[j:j+4], img.Pix[i:i+4])
+		}
+	}
+	return dst
+}
+
+// Point implements Transform.
+func (t Rotate) Point(p image.Point, bounds image.Rectangle) image.Point {
+	p = p.Sub(bounds.Min)
+	w, h := bounds.Dx(), bounds.Dy()
+	switch t.Degrees {
+	case 90:
+		return image.Pt(h-p.Y, p.X)
+	case 180:
+		return image.Pt(w-p.X, h-p.Y)
+	case 270:
+		return image.Pt(p.Y, w-p.X)
+	}
+	return p
+}
+
+// Scale implements Transform.
+func (t Rotate) Scale(image.Rectangle) float64 { return 1 }
+
+// String implements Transform.
+func (t Rotate) String() string { return fmt.Sprintf("rotated %d°", t.Degrees) }
+
+// Flip mirrors the image horizontally (left to right), or vertically if Vertical is set.
+type Flip struct {
+	Vertical bool
+}
+
+// Apply implements Transform.
+func (t Flip) Apply(img *image.RGBA) *image.RGBA {
+	bounds := img.Rect
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			toX, toY := bounds.Dx()-1-x, y
+			if t.Vertical {
+				toX, toY = x, bounds.Dy()-1-y
+			}
+			i, j := img.PixOffset(bounds.Min.X+x, bounds.Min.Y+y), dst.PixOffset(toX, toY)
+			copy(dst.Pix[j:j+4], img.Pix[i:i+4])
+		}
+	}
+	return dst
+}
+
+// Point implements Transform.
+func (t Flip) Point(p image.Point, bounds image.Rectangle) image.Point {
+	p = p.Sub(bounds.Min)
+	if t.Vertical {
+		return image.Pt(p.X, bounds.Dy()-p.Y)
+	}
+	return image.Pt(bounds.Dx()-p.X, p.Y)
+}
+
+// Scale implements Transform.
+func (t Flip) Scale(image.Rectangle) float64 { return 1 }
+
+// String implements Transform.
+func (t Flip) String() string {
+	if t.Vertical {
+		return "flipped vertically"
+	}
+	return "flipped horizontally"
+}
+
+// Resize scales the image to Width x Height pixels, with the Catmull-Rom kernel, which gives
+// sharp results both when reducing and enlarging the image.
+type Resize struct {
+	Width, Height int
+}
+
+// ResizeByPercent returns the Resize that scales an image with the given bounds by `percent`.
+func ResizeByPercent(bounds image.Rectangle, percent float64) Resize {
+	return Resize{
+		Width:  max(1, int(math.Round(float64(bounds.Dx())*percent/100))),
+		Height: max(1, int(math.Round(float64(bounds.Dy())*percent/100))),
+	}
+}
+
+// ResizeToWidth returns the Resize that scales an image with the given bounds down to at most
+// `maxWidth` pixels wide, keeping its aspect ratio. Narrower images are kept as they are.
+func ResizeToWidth(bounds image.Rectangle, maxWidth int) Resize {
+	if bounds.Dx() <= maxWidth {
+		return Resize{Width: bounds.Dx(), Height: bounds.Dy()}
+	}
+	return ResizeByPercent(bounds, 100*float64(maxWidth)/float64(bounds.Dx()))
+}
+
+// Apply implements Transform.
+func (t Resize) Apply(img *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, t.Width, t.Height))
+	draw.CatmullRom.Scale(dst, dst.Rect, img, img.Rect, draw.Src, nil)
+	return dst
+}
+
+// Point implements Transform.
+func (t Resize) Point(p image.Point, bounds image.Rectangle) image.Point {
+	p = p.Sub(bounds.Min)
+	return image.Pt(
+		int(math.Round(float64(p.X*t.Width)/float64(bounds.Dx()))),
+		int(math.Round(float64(p.Y*t.Height)/float64(bounds.Dy()))))
+}
+
+// Scale implements Transform: it's the geometric mean of the horizontal and vertical scales.
+func (t Resize) Scale(bounds image.Rectangle) float64 {
+	return math.Sqrt(float64(t.Width*t.Height) / float64(bounds.Dx()*bounds.Dy()))
+}
+
+// String implements Transform.
+func (t Resize) String() string { return "resized" }
+
+// Pad extends the canvas by the given number of pixels on each side, filled with Color.
+// Negative values remove pixels from that side instead.
+type Pad struct {
+	Left, Top, Right, Bottom int
+	Color                    color.Color
+}
+
+// Apply implements Transform.
+func (t Pad) Apply(img *image.RGBA) *image.RGBA {
+	bounds := img.Rect
+	dst := image.NewRGBA(image.Rect(0, 0, t.Left+bounds.Dx()+t.Right, t.Top+bounds.Dy()+t.Bottom))
+	if t.Color != nil {
+		draw.Src.Draw(dst, dst.Rect, image.NewUniform(t.Color), image.Point{})
+	}
+	draw.Src.Draw(dst, bounds.Sub(bounds.Min).Add(image.Pt(t.Left, t.Top)), img, bounds.Min)
+	return dst
+}
+
+// Point implements Transform.
+func (t Pad) Point(p image.Point, bounds image.Rectangle) image.Point {
+	return p.Sub(bounds.Min).Add(image.Pt(t.Left, t.Top))
+}
+
+// Scale implements Transform.
+func (t Pad) Scale(image.Rectangle) float64 { return 1 }
+
+// String implements Transform.
+func (t Pad) String() string { return "padded" }
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,136 @@
+package transform
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	background = color.RGBA{R: 0x20, G: 0x40, B: 0x60, A: 0xff}
+	marker     = color.RGBA{R: 0xff, A: 0xff}
+	padColor   = color.RGBA{G: 0xff, A: 0xff}
+)
+
+// markedImage returns an image not starting at (0, 0), with the `mark` rectangle in the marker
+// color over the background.
+func markedImage(bounds, mark image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := background
+			if (image.Point{X: x, Y: y}).In(mark) {
+				c = marker
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestTransforms(t *testing.T) {
+	bounds := image.Rect(3, 2, 13, 8) // 10 x 6 pixels.
+	mark := image.Rect(5, 3, 7, 4)    // 2 x 1 pixels, near the top-left corner.
+	testCases := []struct {
+		t Transform
+		// exact is set if the pixels are copied, so only the marked pixels have the marker
+		// color. Otherwise (resize) only the center of the mapped mark is checked.
+		exact  bool
+		mark   image.Rectangle // Where the mark is moved, in the output.
+		bounds image.Rectangle // Of the output.
+		// mapped is the input bounds mapped with Rect, if it's not the output bounds: padding maps
+		// them to where the original image is in the extended canvas.
+		mapped image.Rectangle
+		scale  float64
+	}{
+		{t: Rotate{Degrees: 90}, exact: true, mark: image.Rect(4, 2, 5, 4), bounds: image.Rect(0, 0, 6, 10), scale: 1},
+		{t: Rotate{Degrees: 180}, exact: true, mark: image.Rect(6, 4, 8, 5), bounds: image.Rect(0, 0, 10, 6), scale: 1},
+		{t: Rotate{Degrees: 270}, exact: true, mark: image.Rect(1, 6, 2, 8), bounds: image.Rect(0, 0, 6, 10), scale: 1},
+		{t: Flip{}, exact: true, mark: image.Rect(6, 1, 8, 2), bounds: image.Rect(0, 0, 10, 6), scale: 1},
+		{t: Flip{Vertical: true}, exact: true, mark: image.Rect(2, 4, 4, 5), bounds: image.Rect(0, 0, 10, 6), scale: 1},
+		{t: Resize{Width: 30, Height: 18}, mark: image.Rect(6, 3, 12, 6), bounds: image.Rect(0, 0, 30, 18), scale: 3},
+		{t: Resize{Width: 40, Height: 6}, mark: image.Rect(8, 1, 16, 2), bounds: image.Rect(0, 0, 40, 6), scale: 2},
+		{t: Pad{Left: 2, Top: 1, Right: 3, Bottom: 4, Color: padColor}, exact: true, mark: image.Rect(4, 2, 6, 3),
+			bounds: image.Rect(0, 0, 15, 11), mapped: image.Rect(2, 1, 12, 7), scale: 1},
+		{t: Pad{Left: -1, Top: -1, Right: -2, Bottom: 2, Color: padColor}, exact: true, mark: image.Rect(1, 0, 3, 1),
+			bounds: image.Rect(0, 0, 7, 7), mapped: image.Rect(-1, -1, 9, 5), scale: 1},
+		{t: Pad{Left: 0, Top: 0, Right: 0, Bottom: 0}, exact: true, mark: image.Rect(2, 1, 4, 2), bounds: image.Rect(0, 0, 10, 6), scale: 1},
+	}
+	for _, tc := range testCases {
+		img := markedImage(bounds, mark)
+		got := tc.t.Apply(img)
+		if got.Rect != tc.bounds {
+			t.Errorf("%s: Apply() bounds %v, wanted %v", tc.t, got.Rect, tc.bounds)
+			continue
+		}
+		wantMapped := tc.mapped
+		if wantMapped.Empty() {
+			wantMapped = got.Rect
+		}
+		if r := Rect(tc.t, bounds, bounds); r != wantMapped {
+			t.Errorf("%s: Rect() of the bounds = %v, wanted %v", tc.t, r, wantMapped)
+		}
+		if s := tc.t.Scale(bounds); s != tc.scale {
+			t.Errorf("%s: Scale() = %g, wanted %g", tc.t, s, tc.scale)
+		}
+
+		wantMark := Rect(tc.t, mark, bounds)
+		if wantMark != tc.mark {
+			t.Errorf("%s: Rect() of the mark = %v, wanted %v", tc.t, wantMark, tc.mark)
+		}
+		if !tc.exact {
+			center := image.Pt((wantMark.Min.X+wantMark.Max.X)/2, (wantMark.Min.Y+wantMark.Max.Y)/2)
+			if c := got.RGBAAt(center.X, center.Y); c.R < 0xc0 || c.G > 0x40 {
+				t.Errorf("%s: pixel %v at the center of the mapped mark %v = %v, wanted close to %v",
+					tc.t, center, wantMark, c, marker)
+			}
+			continue
+		}
+		for y := got.Rect.Min.Y; y < got.Rect.Max.Y; y++ {
+			for x := got.Rect.Min.X; x < got.Rect.Max.X; x++ {
+				c := got.RGBAAt(x, y)
+				if (image.Point{X: x, Y: y}).In(wantMark) {
+					if c != marker {
+						t.Errorf("%s: pixel (%d, %d) = %v, wanted the marker moved to %v", tc.t, x, y, c, wantMark)
+					}
+				} else if c == marker {
+					t.Errorf("%s: pixel (%d, %d) has the marker color, outside of %v", tc.t, x, y, wantMark)
+				}
+			}
+		}
+	}
+}
+
+func TestPadColor(t *testing.T) {
+	bounds := image.Rect(3, 2, 13, 8)
+	pad := Pad{Left: 2, Top: 1, Right: -3, Bottom: 0, Color: padColor}
+	got := pad.Apply(markedImage(bounds, image.Rectangle{}))
+	inside := Rect(pad, image.Rect(3, 2, 10, 8), bounds) // The columns kept, after cutting 3 on the right.
+	for y := got.Rect.Min.Y; y < got.Rect.Max.Y; y++ {
+		for x := got.Rect.Min.X; x < got.Rect.Max.X; x++ {
+			want := padColor
+			if (image.Point{X: x, Y: y}).In(inside) {
+				want = background
+			}
+			if c := got.RGBAAt(x, y); c != want {
+				t.Errorf("Pixel (%d, %d) = %v, wanted %v", x, y, c, want)
+			}
+		}
+	}
+}
+
+func TestResizeHelpers(t *testing.T) {
+	bounds := image.Rect(10, 10, 210, 110)
+	if got, want := ResizeByPercent(bounds, 50), (Resize{Width: 100, Height: 50}); got != want {
+		t.Errorf("ResizeByPercent(50) = %+v, wanted %+v", got, want)
+	}
+	if got, want := ResizeByPercent(bounds, 0.1), (Resize{Width: 1, Height: 1}); got != want {
+		t.Errorf("ResizeByPercent(0.1) = %+v, wanted %+v", got, want)
+	}
+	if got, want := ResizeToWidth(bounds, 80), (Resize{Width: 80, Height: 40}); got != want {
+		t.Errorf("ResizeToWidth(80) = %+v, wanted %+v", got, want)
+	}
+	if got, want := ResizeToWidth(bounds, 500), (Resize{Width: 200, Height: 100}); got != want {
+		t.Errorf("ResizeToWidth(500) = %+v, wanted %+v", got, want)
+	}
+}
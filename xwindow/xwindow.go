@@ -0,0 +1,51 @@
+// Package xwindow finds application windows to capture: the active (focused) one, or one
+// picked by the user with a click. It can also scroll them, for scrolling captures, and
+// capture the mouse cursor, which isn't included in screenshots.
+//
+// It is implemented for X11, using the EWMH properties (_NET_ACTIVE_WINDOW, _NET_FRAME_EXTENTS
+// and _NET_WM_NAME) set by the window manager, the XTest extension to scroll and the XFixes
+// extension for the cursor. The libraries of both extensions are loaded at runtime, so they are
+// optional.
+package xwindow
+
+import (
+	"image"
+	"sync"
+)
+
+// errorHandlerMu serializes the temporary replacements of the Xlib error handler, that is
+// process-wide: see LockErrorHandler.
+var errorHandlerMu sync.Mutex
+
+// LockErrorHandler must be held by code that temporarily replaces the Xlib error handler, e.g.
+// to detect failed requests, until it restores the previous one with XSetErrorHandler. Otherwise
+// the errors of another X11 connection of the process may go to the wrong handler.
+func LockErrorHandler() { errorHandlerMu.Lock() }
+
+// UnlockErrorHandler releases LockErrorHandler.
+func UnlockErrorHandler() { errorHandlerMu.Unlock() }
+
+// Window is an application window.
+type Window struct {
+	// Title of the window.
+	Title string
+
+	// Bounds of the window contents, in screen coordinates.
+	Bounds image.Rectangle
+
+	// Frame is Bounds including the decorations (title bar and borders) drawn by the window
+	// manager, in screen coordinates.
+	Frame image.Rectangle
+}
+
+// CursorImage is the image of the mouse cursor, and where it is.
+type CursorImage struct {
+	// Image of the cursor, with premultiplied alpha. Its bounds start at (0, 0).
+	Image *image.RGBA
+
+	// Hotspot is the point of the image that the cursor points to.
+	Hotspot image.Point
+
+	// Position of the hotspot, in screen coordinates.
+	Position image.Point
+}
@@ -0,0 +1,395 @@
+//go:build linux
+// +build linux
+
+package xwindow
+
+// X11 implementation: each call opens its own connection to the X server.
+
+/*
+#cgo pkg-config: x11
+#cgo LDFLAGS: -lX11 -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <X11/Xlib.h>
+#include <X11/Xutil.h>
+#include <X11/cursorfont.h>
+
+static int requestFailed;
+static int (*previousErrorHandler)(Display *, XErrorEvent *);
+
+static int ignoreErrorHandler(Display *dpy, XErrorEvent *ev) {
+	requestFailed = 1;
+	return 0;
+}
+
+// startIgnoringErrors replaces the error handler, until stopIgnoringErrors restores the previous one.
+// It must be called with LockErrorHandler held.
+static void startIgnoringErrors() {
+	requestFailed = 0;
+	previousErrorHandler = XSetErrorHandler(ignoreErrorHandler);
+}
+
+// stopIgnoringErrors returns 1 if any request failed since startIgnoringErrors.
+static int stopIgnoringErrors(Display *dpy) {
+	XSync(dpy, False);
+	XSetErrorHandler(previousErrorHandler);
+	previousErrorHandler = NULL;
+	return requestFailed;
+}
+
+// getCardinals reads up to `max` 32 bits items of the property into `out`, and returns the
+// number of items read.
+static int getCardinals(Display *dpy, Window w, const char *name, long *out, int max) {
+	Atom prop = XInternAtom(dpy, name, True);
+	if (prop == None) {
+		return 0;
+	}
+	Atom type;
+	int format, count = 0;
+	unsigned long n, after;
+	unsigned char *data = NULL;
+	if (XGetWindowProperty(dpy, w, prop, 0, max, False, AnyPropertyType, &type, &format, &n, &after,
+			&data) != Success || data == NULL) {
+		return 0;
+	}
+	if (format == 32) {
+		for (; count < (int)n && count < max; count++) {
+			out[count] = ((long *)data)[count];
+		}
+	}
+	XFree(data);
+	return count;
+}
+
+// hasProperty returns whether the window has the property.
+static int hasProperty(Display *dpy, Window w, Atom prop) {
+	Atom type = None;
+	int format;
+	unsigned long n, after;
+	unsigned char *data = NULL;
+	XGetWindowProperty(dpy, w, prop, 0, 0, False, AnyPropertyType, &type, &format, &n, &after, &data);
+	if (data != NULL) {
+		XFree(data);
+	}
+	return type != None;
+}
+
+// getTitle returns the title of the window (_NET_WM_NAME, or WM_NAME), or NULL. It must be freed with XFree.
+static char *getTitle(Display *dpy, Window w) {
+	Atom prop = XInternAtom(dpy, "_NET_WM_NAME", True);
+	Atom utf8 = XInternAtom(dpy, "UTF8_STRING", True);
+	if (prop != None && utf8 != None) {
+		Atom type;
+		int format;
+		unsigned long n, after;
+		unsigned char *data = NULL;
+		if (XGetWindowProperty(dpy, w, prop, 0, 1024, False, utf8, &type, &format, &n, &after,
+				&data) == Success && data != NULL) {
+			if (n > 0) {
+				return (char *)data;
+			}
+			XFree(data);
+		}
+	}
+	char *name = NULL;
+	if (XFetchName(dpy, w, &name) && name != NULL) {
+		return name;
+	}
+	return NULL;
+}
+
+// clientWindow returns the client window (the one with WM_STATE) in `w` or its descendants, or
+// None if there is none. Window managers reparent the client windows into their frames.
+static Window clientWindow(Display *dpy, Window w, Atom wmState) {
+	if (hasProperty(dpy, w, wmState)) {
+		return w;
+	}
+	Window root, parent, *children = NULL, found = None;
+	unsigned int n;
+	if (!XQueryTree(dpy, w, &root, &parent, &children, &n)) {
+		return None;
+	}
+	for (unsigned int ii = 0; ii < n && found == None; ii++) {
+		found = clientWindow(dpy, children[ii], wmState);
+	}
+	if (children != NULL) {
+		XFree(children);
+	}
+	return found;
+}
+
+// pickWindow grabs the pointer with a crosshair cursor, and returns the client window clicked
+// with the first button. It returns None if another button was clicked, or if the pointer
+// can't be grabbed.
+static Window pickWindow(Display *dpy) {
+	Window root = DefaultRootWindow(dpy);
+	Cursor cursor = XCreateFontCursor(dpy, XC_crosshair);
+	if (XGrabPointer(dpy, root, False, ButtonPressMask, GrabModeSync, GrabModeAsync, root, cursor,
+			CurrentTime) != GrabSuccess) {
+		XFreeCursor(dpy, cursor);
+		return None;
+	}
+	Window picked = None;
+	XEvent ev;
+	XAllowEvents(dpy, SyncPointer, CurrentTime);
+	XWindowEvent(dpy, root, ButtonPressMask, &ev);
+	if (ev.xbutton.button == Button1 && ev.xbutton.subwindow != None) {
+		Atom wmState = XInternAtom(dpy, "WM_STATE", True);
+		picked = ev.xbutton.subwindow;
+		if (wmState != None) {
+			Window client = clientWindow(dpy, picked, wmState);
+			if (client != None) {
+				picked = client;
+			}
+		}
+	}
+	XUngrabPointer(dpy, CurrentTime);
+	XFreeCursor(dpy, cursor);
+	XSync(dpy, False);
+	return picked;
+}
+
+// fakeButtonEvent is XTestFakeButtonEvent, loaded at runtime (see loadXTest) so libXtst is optional.
+typedef int (*fakeButtonEventFn)(Display *, unsigned int, Bool, unsigned long);
+static fakeButtonEventFn fakeButtonEvent;
+
+// loadXTest loads libXtst, and returns 0 if it is not available.
+static int loadXTest() {
+	if (fakeButtonEvent != NULL) {
+		return 1;
+	}
+	void *lib = dlopen("libXtst.so.6", RTLD_LAZY);
+	if (lib == NULL) {
+		return 0;
+	}
+	fakeButtonEvent = (fakeButtonEventFn)dlsym(lib, "XTestFakeButtonEvent");
+	return fakeButtonEvent != NULL;
+}
+
+// scrollDown moves the pointer to (x, y) and sends `clicks` wheel down (button 5) clicks.
+static void scrollDown(Display *dpy, int x, int y, int clicks) {
+	XWarpPointer(dpy, None, DefaultRootWindow(dpy), 0, 0, 0, 0, x, y);
+	for (int ii = 0; ii < clicks; ii++) {
+		fakeButtonEvent(dpy, Button5, True, CurrentTime);
+		fakeButtonEvent(dpy, Button5, False, CurrentTime);
+	}
+	XSync(dpy, False);
+}
+
+// cursorImage is XFixesCursorImage, declared here so the XFixes headers aren't needed to build.
+typedef struct {
+	short x, y;
+	unsigned short width, height;
+	unsigned short xhot, yhot;
+	unsigned long cursor_serial;
+	unsigned long *pixels;
+	Atom atom;
+	const char *name;
+} cursorImage;
+
+// XFixesQueryExtension and XFixesGetCursorImage, loaded at runtime (see loadXFixes) so libXfixes
+// is optional.
+typedef Bool (*queryXFixesFn)(Display *, int *, int *);
+typedef cursorImage *(*getCursorImageFn)(Display *);
+static queryXFixesFn queryXFixes;
+static getCursorImageFn getCursorImage;
+
+// loadXFixes loads libXfixes, and returns 0 if it is not available.
+static int loadXFixes() {
+	if (getCursorImage != NULL) {
+		return 1;
+	}
+	void *lib = dlopen("libXfixes.so.3", RTLD_LAZY);
+	if (lib == NULL) {
+		return 0;
+	}
+	queryXFixes = (queryXFixesFn)dlsym(lib, "XFixesQueryExtension");
+	if (queryXFixes == NULL) {
+		return 0;
+	}
+	getCursorImage = (getCursorImageFn)dlsym(lib, "XFixesGetCursorImage");
+	return getCursorImage != NULL;
+}
+
+// hasXFixes returns whether the X server supports the XFixes extension.
+static int hasXFixes(Display *dpy) {
+	int eventBase, errorBase;
+	return queryXFixes(dpy, &eventBase, &errorBase);
+}
+
+// getCursor returns the image of the cursor, to be freed with XFree, or NULL.
+static cursorImage *getCursor(Display *dpy) {
+	return getCursorImage(dpy);
+}
+
+// getGeometry returns the position of the window in the root window, and its size.
+static int getGeometry(Display *dpy, Window w, int *x, int *y, int *width, int *height) {
+	XWindowAttributes attrs;
+	Window child;
+	if (!XGetWindowAttributes(dpy, w, &attrs)) {
+		return 0;
+	}
+	*width = attrs.width;
+	*height = attrs.height;
+	return XTranslateCoordinates(dpy, w, DefaultRootWindow(dpy), 0, 0, x, y, &child);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"unsafe"
+)
+
+// Supported is true if the platform supports finding windows.
+const Supported = true
+
+// ErrCancelled is returned by Pick if the user cancelled the selection.
+var ErrCancelled = errors.New("window selection cancelled")
+
+// Active returns the active (focused) window.
+func Active() (Window, error) {
+	return withDisplay(func(dpy *C.Display) (C.Window, error) {
+		name := C.CString("_NET_ACTIVE_WINDOW")
+		defer C.free(unsafe.Pointer(name))
+		var id C.long
+		if C.getCardinals(dpy, C.XDefaultRootWindow(dpy), name, &id, 1) != 1 || id == 0 {
+			return 0, errors.New("no active window, or the window manager doesn't support _NET_ACTIVE_WINDOW")
+		}
+		return C.Window(id), nil
+	})
+}
+
+// Pick lets the user pick a window by clicking on it, and returns it. Clicking with any other
+// button than the first one cancels, and returns ErrCancelled.
+func Pick() (Window, error) {
+	return withDisplay(func(dpy *C.Display) (C.Window, error) {
+		w := C.pickWindow(dpy)
+		if w == 0 {
+			return 0, ErrCancelled
+		}
+		return w, nil
+	})
+}
+
+// Cursor returns the image of the mouse cursor, and its position.
+func Cursor() (CursorImage, error) {
+	if os.Getenv("DISPLAY") == "" {
+		return CursorImage{}, errors.New("capturing the mouse cursor is only supported in X11")
+	}
+	if C.loadXFixes() == 0 {
+		return CursorImage{}, errors.New("capturing the mouse cursor requires the XFixes extension library (libXfixes.so.3)")
+	}
+	dpy := C.XOpenDisplay(nil)
+	if dpy == nil {
+		return CursorImage{}, errors.New("cannot open X11 display")
+	}
+	defer C.XCloseDisplay(dpy)
+	if C.hasXFixes(dpy) == 0 {
+		return CursorImage{}, errors.New("X11 server doesn't support the XFixes extension")
+	}
+	ci := C.getCursor(dpy)
+	if ci == nil {
+		return CursorImage{}, errors.New("failed to get the mouse cursor image")
+	}
+	defer C.XFree(unsafe.Pointer(ci))
+
+	// Pixels are premultiplied ARGB, in the lower 32 bits of each unsigned long.
+	width, height := int(ci.width), int(ci.height)
+	pixels := (*[1 << 28]C.ulong)(unsafe.Pointer(ci.pixels))[: width*height : width*height]
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for ii, argb := range pixels {
+		img.Pix[4*ii] = uint8(argb >> 16)
+		img.Pix[4*ii+1] = uint8(argb >> 8)
+		img.Pix[4*ii+2] = uint8(argb)
+		img.Pix[4*ii+3] = uint8(argb >> 24)
+	}
+	return CursorImage{
+		Image:    img,
+		Hotspot:  image.Pt(int(ci.xhot), int(ci.yhot)),
+		Position: image.Pt(int(ci.x), int(ci.y)),
+	}, nil
+}
+
+// ScrollDown moves the pointer to (x, y), in screen coordinates, and scrolls down with `clicks`
+// clicks of the mouse wheel. It requires the XTest extension (libXtst).
+func ScrollDown(x, y, clicks int) error {
+	if os.Getenv("DISPLAY") == "" {
+		return errors.New("scrolling is only supported in X11")
+	}
+	if C.loadXTest() == 0 {
+		return errors.New("scrolling requires the XTest extension library (libXtst.so.6)")
+	}
+	dpy := C.XOpenDisplay(nil)
+	if dpy == nil {
+		return errors.New("cannot open X11 display")
+	}
+	defer C.XCloseDisplay(dpy)
+	C.scrollDown(dpy, C.int(x), C.int(y), C.int(clicks))
+	return nil
+}
+
+// withDisplay opens a connection to the X server, finds the window with `find` and returns its
+// description.
+func withDisplay(find func(dpy *C.Display) (C.Window, error)) (Window, error) {
+	if os.Getenv("DISPLAY") == "" {
+		return Window{}, errors.New("finding windows is only supported in X11")
+	}
+	dpy := C.XOpenDisplay(nil)
+	if dpy == nil {
+		return Window{}, errors.New("cannot open X11 display")
+	}
+	defer C.XCloseDisplay(dpy)
+	w, err := find(dpy)
+	if err != nil {
+		return Window{}, err
+	}
+	return describe(dpy, w)
+}
+
+// describe returns the title and the geometry of the window.
+func describe(dpy *C.Display, w C.Window) (win Window, err error) {
+	LockErrorHandler()
+	C.startIgnoringErrors()
+	var x, y, width, height C.int
+	ok := C.getGeometry(dpy, w, &x, &y, &width, &height)
+	if title := C.getTitle(dpy, w); title != nil {
+		win.Title = C.GoString(title)
+		C.XFree(unsafe.Pointer(title))
+	}
+	var frame, gtkFrame [4]C.long // Left, right, top and bottom extents.
+	hasFrame := cardinals(dpy, w, "_NET_FRAME_EXTENTS", frame[:])
+	hasGtkFrame := cardinals(dpy, w, "_GTK_FRAME_EXTENTS", gtkFrame[:])
+	failed := C.stopIgnoringErrors(dpy) != 0
+	UnlockErrorHandler()
+	if failed || ok == 0 {
+		return Window{}, fmt.Errorf("failed to get geometry of window 0x%x", uint64(w))
+	}
+
+	win.Bounds = image.Rect(int(x), int(y), int(x+width), int(y+height))
+	if hasGtkFrame {
+		// Client-side decorations: the window includes an invisible margin, for its shadow.
+		win.Bounds.Min.X += int(gtkFrame[0])
+		win.Bounds.Max.X -= int(gtkFrame[1])
+		win.Bounds.Min.Y += int(gtkFrame[2])
+		win.Bounds.Max.Y -= int(gtkFrame[3])
+	}
+	win.Frame = win.Bounds
+	if hasFrame {
+		win.Frame.Min.X -= int(frame[0])
+		win.Frame.Max.X += int(frame[1])
+		win.Frame.Min.Y -= int(frame[2])
+		win.Frame.Max.Y += int(frame[3])
+	}
+	return win, nil
+}
+
+// cardinals reads the property with len(out) 32 bits items, and returns whether it was found.
+func cardinals(dpy *C.Display, w C.Window, name string, out []C.long) bool {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	return int(C.getCardinals(dpy, w, cName, &out[0], C.int(len(out)))) == len(out)
+}
@@ -0,0 +1,38 @@
+//go:build !linux
+// +build !linux
+
+package xwindow
+
+// Placeholder implementation that informs about missing capability.
+
+import (
+	"errors"
+)
+
+// Supported is true if the platform supports finding windows.
+const Supported = false
+
+// ErrCancelled is returned by Pick if the user cancelled the selection.
+var ErrCancelled = errors.New("window selection cancelled")
+
+var errNotSupported = errors.New("Capturing windows not implemented in this platform, sorry.")
+
+// Active is not implemented in this platform.
+func Active() (Window, error) {
+	return Window{}, errNotSupported
+}
+
+// Pick is not implemented in this platform.
+func Pick() (Window, error) {
+	return Window{}, errNotSupported
+}
+
+// Cursor is not implemented in this platform.
+func Cursor() (CursorImage, error) {
+	return CursorImage{}, errors.New("Capturing the mouse cursor not implemented in this platform, sorry.")
+}
+
+// ScrollDown is not implemented in this platform.
+func ScrollDown(x, y, clicks int) error {
+	return errNotSupported
+}